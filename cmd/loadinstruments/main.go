@@ -0,0 +1,56 @@
+// Command loadinstruments fills the instrument master from exchange listing files
+//
+//	go run ./cmd/loadinstruments -format nse EQUITY_L.csv
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/instruments"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	format := flag.String("format", "csv", "listing format, one of "+strings.Join(instruments.Formats, "/"))
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: loadinstruments -format <format> <file>...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Same DB_URL as the API, from .env when there is one
+	godotenv.Load()
+	dbURL := os.Getenv("DB_URL")
+	if dbURL == "" {
+		log.Fatal("environment variable DB_URL not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		n, err := instruments.Load(context.Background(), db, *format, f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("error loading %s: %v", path, err)
+		}
+		log.Printf("Loaded %d instruments from %s\n", n, path)
+	}
+}
@@ -0,0 +1,106 @@
+// Command setrole gives a user a role, the way to make the first admin
+//
+//	go run ./cmd/setrole -email someone@example.com -role admin
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/utils"
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+)
+
+func main() {
+	email := flag.String("email", "", "email of the user")
+	role := flag.String("role", "", "role to give, one of "+strings.Join(auth.Roles, "/"))
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: setrole -email <email> -role <role>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *email == "" || !slices.Contains(auth.Roles, *role) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Same DB_URL as the API, from .env when there is one
+	godotenv.Load()
+	dbURL := os.Getenv("DB_URL")
+	if dbURL == "" {
+		log.Fatal("environment variable DB_URL not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Sessions are revoked in redis as well, the API checks every access token there
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		redisURL = "redis://localhost:6379"
+	}
+	redisOpts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rdb := redis.NewClient(redisOpts)
+	defer rdb.Close()
+	auth.Revocations = auth.RedisRevocations{RD: rdb}
+
+	ctx := context.Background()
+	q := database.New(db)
+	user, err := q.GetUserByEmail(ctx, utils.NormalizeEmail(*email))
+	if err != nil {
+		log.Fatalf("error getting user %s: %v", *email, err)
+	}
+	if user.Role == *role {
+		log.Printf("%s is already %s\n", user.Email, *role)
+		return
+	}
+
+	details, _ := json.Marshal(map[string]any{"from": user.Role, "to": *role})
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer tx.Rollback()
+	qtx := q.WithTx(tx)
+	if _, err := qtx.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: *role}); err != nil {
+		log.Fatalf("error setting role: %v", err)
+	}
+	// Audited like a change made through the API, with no one behind it
+	err = qtx.CreateAdminAuditEntry(ctx, database.CreateAdminAuditEntryParams{
+		ActorID:      uuid.Nil,
+		ActorRole:    "cli",
+		Action:       "users.role",
+		TargetUserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		Details:      details,
+	})
+	if err != nil {
+		log.Fatalf("error recording role change: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Like a role change through the API, no token with the old role is left
+	ended, err := auth.EndSessions(ctx, q, user.ID, uuid.Nil)
+	if err != nil {
+		log.Fatalf("%s is now %s but ending their sessions failed: %v", user.Email, *role, err)
+	}
+	log.Printf("%s is now %s, %d sessions ended\n", user.Email, *role, len(ended))
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// API keys look like stk_1a2b3c4d_<secret>, the part before the secret is the prefix users see
+const APIKeyPrefix = "stk_"
+
+// What an API key can be used for. A key only gets the scopes it was made with, tokens
+// from a login can do everything.
+const (
+	ScopePortfolioRead = "portfolio:read"
+	ScopeTrade         = "trade"
+	ScopeExport        = "export"
+)
+
+var (
+	Scopes = []string{ScopePortfolioRead, ScopeTrade, ScopeExport}
+
+	ErrInvalidAPIKey = errors.New("invalid API key")
+)
+
+// APIKey is what an API key stands for once it checks out
+type APIKey struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	Scopes []string
+}
+
+// APIKeyStore looks keys up by their HashToken. Unknown, expired and revoked keys are ErrInvalidAPIKey.
+type APIKeyStore interface {
+	Lookup(ctx context.Context, keyHash string) (APIKey, error)
+}
+
+// APIKeys is set up by config.Load, until then no key is valid
+var APIKeys APIKeyStore = noAPIKeys{}
+
+type noAPIKeys struct{}
+
+func (noAPIKeys) Lookup(context.Context, string) (APIKey, error) { return APIKey{}, ErrInvalidAPIKey }
+
+// MakeAPIKey returns a new key and its prefix, only the key's HashToken is stored
+func MakeAPIKey() (key, prefix string, err error) {
+	b := make([]byte, 4+32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	prefix = APIKeyPrefix + hex.EncodeToString(b[:4])
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(b[4:]), prefix, nil
+}
+
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// Claims for a request made with an API key, there's no session behind it
+func apiKeyClaims(key string) (Claims, error) {
+	apiKey, err := APIKeys.Lookup(context.Background(), HashToken(key))
+	if err != nil {
+		return Claims{}, err
+	}
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: apiKey.UserID.String()},
+		APIKeyID:         apiKey.ID,
+		Scopes:           apiKey.Scopes,
+	}, nil
+}
@@ -1,8 +1,15 @@
 package auth
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -23,24 +30,84 @@ func CheckPasswordHash(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    "stocker",
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-		Subject:   userID.String(),
+// Access tokens are short lived, a client keeps going by swapping its refresh token
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Roles a user can have. support can look at other users and their portfolios, admin can
+// also change them.
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
+var Roles = []string{RoleUser, RoleSupport, RoleAdmin}
+
+// Claims of an access token. SessionID is the refresh token family it came from, so a
+// revoked session takes its access tokens with it. APIKeyID and Scopes are only set when the
+// request was made with an API key.
+type Claims struct {
+	jwt.RegisteredClaims
+	SessionID uuid.UUID `json:"sid"`
+	Role      string    `json:"role,omitempty"`
+	APIKeyID  uuid.UUID `json:"-"`
+	Scopes    []string  `json:"-"`
+}
+
+func (c Claims) UserID() (uuid.UUID, error) {
+	return uuid.Parse(c.Subject)
+}
+
+// HasScope reports whether the request may do what scope covers, logins can do anything
+func (c Claims) HasScope(scope string) bool {
+	return c.APIKeyID == uuid.Nil || slices.Contains(c.Scopes, scope)
+}
+
+// HasRole reports whether the token is for one of roles. Tokens from before roles existed
+// and API keys count as a plain user.
+func (c Claims) HasRole(roles ...string) bool {
+	role := c.Role
+	if role == "" || c.APIKeyID != uuid.Nil {
+		role = RoleUser
+	}
+	return slices.Contains(roles, role)
+}
+
+func MakeJWT(userID, sessionID uuid.UUID, role, tokenSecret string, expiresIn time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "stocker",
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   userID.String(),
+			ID:        uuid.NewString(),
+		},
+		SessionID: sessionID,
+		Role:      role,
 	})
 	return token.SignedString([]byte(tokenSecret))
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	var claims jwt.RegisteredClaims
+// ParseJWT checks the signature and expiry and returns the claims
+func ParseJWT(tokenString, tokenSecret string) (Claims, error) {
+	var claims Claims
 	_, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (any, error) {
 		if t.Method != jwt.SigningMethodHS256 {
 			return nil, errors.New("wrong signing method")
 		}
 		return []byte(tokenSecret), nil
 	})
+	if err != nil {
+		return Claims{}, err
+	}
+	return claims, nil
+}
+
+func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	claims, err := ParseJWT(tokenString, tokenSecret)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
@@ -56,14 +123,49 @@ func GetBearerToken(headers http.Header) (string, error) {
 	return strings.TrimPrefix(authHeader, "Bearer "), nil
 }
 
-func GetUserID(headers http.Header, tokenSecret string) (uuid.UUID, error) {
+// GetClaims validates the bearer token and makes sure it hasn't been revoked. The bearer
+// can also be an API key.
+func GetClaims(headers http.Header, tokenSecret string) (Claims, error) {
 	tokenStr, err := GetBearerToken(headers)
 	if err != nil {
-		return uuid.UUID{}, err
+		return Claims{}, err
+	}
+	if IsAPIKey(tokenStr) {
+		return apiKeyClaims(tokenStr)
+	}
+	claims, err := ParseJWT(tokenStr, tokenSecret)
+	if err != nil {
+		return Claims{}, err
+	}
+	revoked, err := Revocations.IsRevoked(context.Background(), tokenKey(claims.ID), sessionKey(claims.SessionID))
+	if err != nil {
+		return Claims{}, fmt.Errorf("can't check if token is revoked: %w", err)
+	}
+	if revoked {
+		return Claims{}, ErrTokenRevoked
 	}
-	userId, err := ValidateJWT(tokenStr, tokenSecret)
+	return claims, nil
+}
+
+func GetUserID(headers http.Header, tokenSecret string) (uuid.UUID, error) {
+	claims, err := GetClaims(headers, tokenSecret)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
-	return userId, nil
+	return claims.UserID()
+}
+
+// MakeToken returns a random opaque token for refresh tokens and emailed links, only its HashToken is stored
+func MakeToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Tokens are random enough that a plain SHA-256 is as good as bcrypt and can be looked up
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// RevocationList remembers revoked access tokens and sessions until their tokens would have
+// expired anyway, GetUserID checks every token against it
+type RevocationList interface {
+	Revoke(ctx context.Context, key string, ttl time.Duration) error
+	IsRevoked(ctx context.Context, keys ...string) (bool, error)
+}
+
+// Revocations is set up by config.Load, until then nothing counts as revoked
+var Revocations RevocationList = noRevocations{}
+
+type noRevocations struct{}
+
+func (noRevocations) Revoke(context.Context, string, time.Duration) error { return nil }
+func (noRevocations) IsRevoked(context.Context, ...string) (bool, error)  { return false, nil }
+
+// RedisRevocations keeps a key per revoked token or session that expires with it
+type RedisRevocations struct {
+	RD *redis.Client
+}
+
+func (r RedisRevocations) Revoke(ctx context.Context, key string, ttl time.Duration) error {
+	return r.RD.Set(ctx, "revoked:"+key, 1, ttl).Err()
+}
+
+func (r RedisRevocations) IsRevoked(ctx context.Context, keys ...string) (bool, error) {
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = "revoked:" + key
+	}
+	n, err := r.RD.Exists(ctx, redisKeys...).Result()
+	return n > 0, err
+}
+
+// RevokeToken makes one access token unusable for the rest of its life
+func RevokeToken(ctx context.Context, claims Claims) error {
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+	return Revocations.Revoke(ctx, tokenKey(claims.ID), ttl)
+}
+
+// RevokeSession makes every access token issued for the session unusable. Refresh tokens are
+// revoked in the database, this covers the access tokens already handed out.
+func RevokeSession(ctx context.Context, sessionID uuid.UUID) error {
+	return Revocations.Revoke(ctx, sessionKey(sessionID), AccessTokenTTL)
+}
+
+func tokenKey(id string) string {
+	return "jti:" + id
+}
+
+func sessionKey(id uuid.UUID) string {
+	return "sid:" + id.String()
+}
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/google/uuid"
+)
+
+// EndSessions ends every session of the user but keep, all of them when keep is uuid.Nil. Their
+// refresh tokens stop working and so do the access tokens already out. Returns the sessions
+// that were ended.
+func EndSessions(ctx context.Context, q *database.Queries, userID, keep uuid.UUID) ([]uuid.UUID, error) {
+	ended, err := q.RevokeOtherSessions(ctx, database.RevokeOtherSessionsParams{UserID: userID, ID: keep})
+	if err != nil {
+		return nil, err
+	}
+	for _, sessionID := range ended {
+		if _, err := q.RevokeRefreshTokenFamily(ctx, database.RevokeRefreshTokenFamilyParams{
+			UserID:   userID,
+			FamilyID: sessionID,
+		}); err != nil {
+			return nil, err
+		}
+		if err := RevokeSession(ctx, sessionID); err != nil {
+			return nil, err
+		}
+	}
+	return ended, nil
+}
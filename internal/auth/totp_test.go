@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// The SHA1 seed from RFC 6238 Appendix B, base32 encoded the way secrets are stored
+var rfcSecret = base32NoPadding.EncodeToString([]byte("12345678901234567890"))
+
+// RFC 6238 Appendix B SHA1 vectors, the last 6 of their 8 digits
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCode(t *testing.T) {
+	key := []byte("12345678901234567890")
+	for _, tt := range rfcVectors {
+		if got := totpCode(key, tt.unix/totpPeriod); got != tt.code {
+			t.Errorf("code at %d is %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	for _, tt := range rfcVectors {
+		at := time.Unix(tt.unix, 0)
+		step, ok := ValidateTOTP(rfcSecret, tt.code, at)
+		if !ok || step != tt.unix/totpPeriod {
+			t.Errorf("code %s at %d: got step %d, %v, want %d", tt.code, tt.unix, step, ok, tt.unix/totpPeriod)
+		}
+	}
+}
+
+// A code stays good while the clock is one step either side of its own, and the step returned
+// is always the code's so a replay of it can be refused
+func TestValidateTOTPSkew(t *testing.T) {
+	// 1111111109 is the last second of step 37037036
+	const unix, code, codeStep = 1111111109, "081804", 37037036
+
+	tests := []struct {
+		name   string
+		offset time.Duration
+		ok     bool
+	}{
+		{"same step", 0, true},
+		{"start of the step", -29 * time.Second, true},
+		{"start of the step after", 1 * time.Second, true},
+		{"end of the step after", 30 * time.Second, true},
+		{"two steps after", 31 * time.Second, false},
+		{"end of the step before", -30 * time.Second, true},
+		{"start of the step before", -59 * time.Second, true},
+		{"two steps before", -60 * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(rfcSecret, code, time.Unix(unix, 0).Add(tt.offset))
+			if ok != tt.ok {
+				t.Fatalf("accepted %v, want %v", ok, tt.ok)
+			}
+			if ok && step != codeStep {
+				t.Errorf("step %d, want %d", step, codeStep)
+			}
+		})
+	}
+}
+
+// UseTOTPStep only takes steps after the last one used, so the step has to be the matching code's
+// and not the clock's: otherwise a code from the step ahead could be followed by an older one
+func TestValidateTOTPReturnsCodeStep(t *testing.T) {
+	key := []byte("12345678901234567890")
+	at := time.Unix(1111111111, 0)
+	now := at.Unix() / totpPeriod
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		got, ok := ValidateTOTP(rfcSecret, totpCode(key, step), at)
+		if !ok || got != step {
+			t.Errorf("code for step %d: got step %d, %v", step, got, ok)
+		}
+	}
+}
+
+func TestValidateTOTPRejects(t *testing.T) {
+	at := time.Unix(59, 0)
+	tests := []struct {
+		name, secret, code string
+	}{
+		{"wrong code", rfcSecret, "287083"},
+		{"too short", rfcSecret, "28708"},
+		{"all 8 digits", rfcSecret, "94287082"},
+		{"bad secret", "not base32!", "287082"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if step, ok := ValidateTOTP(tt.secret, tt.code, at); ok || step != 0 {
+				t.Errorf("got step %d, %v, want rejected", step, ok)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPLowercaseSecret(t *testing.T) {
+	lower := []byte(rfcSecret)
+	for i, c := range lower {
+		if c >= 'A' && c <= 'Z' {
+			lower[i] = c + 'a' - 'A'
+		}
+	}
+	if _, ok := ValidateTOTP(string(lower), "287082", time.Unix(59, 0)); !ok {
+		t.Error("lowercase secret rejected")
+	}
+}
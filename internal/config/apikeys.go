@@ -0,0 +1,48 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+)
+
+// dbAPIKeys is the auth.APIKeyStore, keys live in the api_keys table
+type dbAPIKeys struct {
+	db *database.Queries
+}
+
+func (s dbAPIKeys) Lookup(ctx context.Context, keyHash string) (auth.APIKey, error) {
+	key, err := s.db.GetAPIKeyByHash(ctx, keyHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.APIKey{}, auth.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return auth.APIKey{}, err
+	}
+	if key.RevokedAt.Valid || (key.ExpiresAt.Valid && time.Now().After(key.ExpiresAt.Time)) {
+		return auth.APIKey{}, auth.ErrInvalidAPIKey
+	}
+	// Keys stop working while their owner's account is disabled
+	user, err := s.db.GetUserByID(ctx, key.UserID)
+	if err != nil {
+		return auth.APIKey{}, err
+	}
+	if user.DisabledAt.Valid {
+		return auth.APIKey{}, auth.ErrInvalidAPIKey
+	}
+
+	// Only for showing the user, a failed write shouldn't fail the request
+	if err := s.db.TouchAPIKey(ctx, key.ID); err != nil {
+		log.Printf("Error updating API key last use: %v\n", err)
+	}
+	return auth.APIKey{
+		ID:     key.ID,
+		UserID: key.UserID,
+		Scopes: key.Scopes,
+	}, nil
+}
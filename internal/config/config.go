@@ -6,17 +6,31 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/notify"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/oidc"
 	"github.com/joho/godotenv"
 	"github.com/redis/go-redis/v9"
 )
 
 type APIConfig struct {
+	Conn      *sql.DB
 	DB        *database.Queries
 	RD        *redis.Client
 	JWTSecret string
+	Notifier  notify.Notifier
+	// Where the frontend lives, emailed links point at it. Empty means emails carry bare tokens.
+	AppURL string
+	// REQUIRE_VERIFIED_EMAIL=true keeps users who haven't verified their email from trading,
+	// they can still log in and look around
+	RequireVerifiedEmail bool
+	// Single sign-on through an OpenID Connect provider, nil when OIDC_ISSUER isn't set
+	OIDC *oidc.Provider
 }
 
 func Load() *APIConfig {
@@ -45,19 +59,88 @@ func Load() *APIConfig {
 	if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
 		log.Printf("Error creating consumer group: %v", err)
 	}
+	err = rdb.XGroupCreateMkStream(context.Background(), notify.Stream, notify.Group, "0").Err()
+	if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
+		log.Printf("Error creating notifications consumer group: %v", err)
+	}
 	fmt.Println("Redis Stream Created successfully!")
 
+	// Logged out and revoked sessions are shared by every instance through redis
+	auth.Revocations = auth.RedisRevocations{RD: rdb}
+
 	dbQueries := database.New(db)
+	auth.APIKeys = dbAPIKeys{db: dbQueries}
+	appURL := strings.TrimSuffix(os.Getenv("APP_URL"), "/")
 	cfg := &APIConfig{
-		DB:        dbQueries,
-		RD:        rdb,
-		JWTSecret: mustGetEnv("JWT_SECRET"),
+		Conn:                 db,
+		DB:                   dbQueries,
+		RD:                   rdb,
+		JWTSecret:            mustGetEnv("JWT_SECRET"),
+		Notifier:             loadNotifier(),
+		AppURL:               appURL,
+		RequireVerifiedEmail: os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true",
+		OIDC:                 loadOIDC(appURL),
 	}
 	fmt.Println("Redis Client Connected Successfully.")
 	fmt.Println("Postgres Database Connected Successfully.")
 	return cfg
 }
 
+// SMTP is optional, without SMTP_HOST emails are only logged
+func loadNotifier() notify.Notifier {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		log.Println("SMTP_HOST not set, notifications will be logged instead of sent")
+		return notify.LogNotifier{}
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	return notify.NewSMTPNotifier(notify.SMTPConfig{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     mustGetEnv("SMTP_FROM"),
+	})
+}
+
+// Single sign-on is optional, without OIDC_ISSUER only passwords work
+func loadOIDC(appURL string) *oidc.Provider {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil
+	}
+
+	// The provider sends the browser back to the frontend, which hands the code to the API
+	redirectURL := os.Getenv("OIDC_REDIRECT_URL")
+	if redirectURL == "" && appURL != "" {
+		redirectURL = appURL + "/oidc/callback"
+	}
+	if redirectURL == "" {
+		log.Fatal("OIDC_ISSUER is set but neither OIDC_REDIRECT_URL nor APP_URL is")
+	}
+
+	scopes := strings.Fields(os.Getenv("OIDC_SCOPES"))
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	if !slices.Contains(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	return oidc.New(oidc.Config{
+		Issuer:       issuer,
+		ClientID:     mustGetEnv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+	})
+}
+
 func mustGetEnv(key string) string {
 	val := os.Getenv(key)
 	if val == "" {
@@ -108,6 +191,15 @@ func (cfg *APIConfig) CheckRateLimit(ctx context.Context, ipAddr, action string)
 	case "login":
 		timeWindowInSeconds = 600
 		limit = 5
+	case "password_forgot", "password_reset", "password_change", "verification_resend":
+		timeWindowInSeconds = 600
+		limit = 5
+	case "oidc":
+		timeWindowInSeconds = 600
+		limit = 10
+	case "totp":
+		timeWindowInSeconds = 300
+		limit = 5
 	default:
 		timeWindowInSeconds = 3600
 		limit = 100
@@ -1,10 +1,11 @@
 package config
 
 import (
-	"database/sql"
+	"slices"
 	"time"
 
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/shopspring/decimal"
 )
 
 type YahooFinanceResponse struct {
@@ -23,16 +24,20 @@ type YahooResult struct {
 }
 
 type YahooMeta struct {
-	Currency            string  `json:"currency"`
-	Symbol              string  `json:"symbol"`
-	ExchangeName        string  `json:"exchangeName"`
-	InstrumentType      string  `json:"instrumentType"`
-	RegularMarketTime   int64   `json:"regularMarketTime"`
-	RegularMarketPrice  float64 `json:"regularMarketPrice"`
-	PreviousClose       float64 `json:"previousClose"`
-	RegularMarketVolume int64   `json:"regularMarketVolume"`
-	LongName            string  `json:"longName"`
-	ShortName           string  `json:"shortName"`
+	Currency             string          `json:"currency"`
+	Symbol               string          `json:"symbol"`
+	ExchangeName         string          `json:"exchangeName"`
+	InstrumentType       string          `json:"instrumentType"`
+	RegularMarketTime    int64           `json:"regularMarketTime"`
+	RegularMarketPrice   decimal.Decimal `json:"regularMarketPrice"`
+	PreviousClose        decimal.Decimal `json:"previousClose"`
+	RegularMarketVolume  int64           `json:"regularMarketVolume"`
+	RegularMarketDayHigh decimal.Decimal `json:"regularMarketDayHigh"`
+	RegularMarketDayLow  decimal.Decimal `json:"regularMarketDayLow"`
+	FiftyTwoWeekHigh     decimal.Decimal `json:"fiftyTwoWeekHigh"`
+	FiftyTwoWeekLow      decimal.Decimal `json:"fiftyTwoWeekLow"`
+	LongName             string          `json:"longName"`
+	ShortName            string          `json:"shortName"`
 }
 
 type YahooIndicators struct {
@@ -49,10 +54,76 @@ type YahooQuote struct {
 
 func (yr *YahooResult) ToStock() database.Stock {
 	return database.Stock{
-		Symbol:        yr.Meta.Symbol,
-		CompanyName:   yr.Meta.LongName,
-		CurrentPrice:  yr.Meta.RegularMarketPrice,
-		PreviousClose: sql.NullFloat64{Float64: yr.Meta.PreviousClose, Valid: true},
-		UpdatedAt:     time.Now(),
+		Symbol:            yr.Meta.Symbol,
+		CompanyName:       yr.Meta.LongName,
+		CurrentPrice:      yr.Meta.RegularMarketPrice,
+		PreviousClose:     decimal.NullDecimal{Decimal: yr.Meta.PreviousClose, Valid: true},
+		UpdatedAt:         time.Now(),
+		QuantityPrecision: yr.Meta.QuantityPrecision(),
+		AssetClass:        yr.Meta.AssetClass(),
+		DayHigh:           nonZero(yr.Meta.RegularMarketDayHigh),
+		DayLow:            nonZero(yr.Meta.RegularMarketDayLow),
+		Volume:            yr.Meta.RegularMarketVolume,
+		FiftyTwoWeekHigh:  nonZero(yr.Meta.FiftyTwoWeekHigh),
+		FiftyTwoWeekLow:   nonZero(yr.Meta.FiftyTwoWeekLow),
 	}
 }
+
+// Yahoo leaves out what it doesn't have for an instrument, funds have no day range
+func nonZero(d decimal.Decimal) decimal.NullDecimal {
+	return decimal.NullDecimal{Decimal: d, Valid: !d.IsZero()}
+}
+
+// Asset classes as Yahoo's instrumentType names them
+const (
+	AssetEquity     = "EQUITY"
+	AssetETF        = "ETF"
+	AssetMutualFund = "MUTUALFUND"
+	AssetCrypto     = "CRYPTOCURRENCY"
+	AssetIndex      = "INDEX"
+)
+
+var AssetClasses = []string{AssetEquity, AssetETF, AssetMutualFund, AssetCrypto, AssetIndex}
+
+// Anything we don't know how to treat differently is polled and traded like an equity
+func (ym *YahooMeta) AssetClass() string {
+	if slices.Contains(AssetClasses, ym.InstrumentType) {
+		return ym.InstrumentType
+	}
+	return AssetEquity
+}
+
+// Default number of decimal places a quantity may have, US brokers allow fractional shares
+// and crypto and fund units are fractional everywhere
+func (ym *YahooMeta) QuantityPrecision() int32 {
+	switch {
+	case ym.AssetClass() == AssetCrypto:
+		return 8
+	case ym.AssetClass() == AssetMutualFund:
+		return 3
+	case ym.Currency == "USD":
+		return 6
+	}
+	return 0
+}
+
+// Yahoo's exchange codes under the names the listing files use
+var yahooExchanges = map[string]string{
+	"NSI": "NSE",
+	"BSE": "BSE",
+	"NMS": "NASDAQ",
+	"NGM": "NASDAQ",
+	"NCM": "NASDAQ",
+	"NYQ": "NYSE",
+	"ASE": "NYSEAMERICAN",
+	"PCX": "NYSEARCA",
+	"BTS": "BATS",
+	"CCC": "CRYPTO",
+}
+
+func (ym *YahooMeta) Exchange() string {
+	if exchange, ok := yahooExchanges[ym.ExchangeName]; ok {
+		return exchange
+	}
+	return ym.ExchangeName
+}
@@ -0,0 +1,593 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Where RequireRole leaves the caller's claims for the handlers after it
+const claimsKey = "claims"
+
+var errNotAllowedForRole = errors.New("not allowed for this role")
+
+// RequireRole lets a request through only when its access token has one of roles. API keys
+// never do, they count as a plain user.
+func RequireRole(cfg *config.APIConfig, roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// An earlier RequireRole on the group has checked the token already
+		value, ok := ctx.Get(claimsKey)
+		claims, _ := value.(auth.Claims)
+		if !ok {
+			var err error
+			claims, err = auth.GetClaims(ctx.Request.Header, cfg.JWTSecret)
+			if err != nil {
+				respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+				ctx.Abort()
+				return
+			}
+		}
+		if !claims.HasRole(roles...) {
+			respondWithError(ctx, http.StatusForbidden, "Not allowed", errNotAllowedForRole)
+			ctx.Abort()
+			return
+		}
+		ctx.Set(claimsKey, claims)
+		ctx.Next()
+	}
+}
+
+// Records what the caller did in the admin audit log. Reads go through cfg.DB, changes pass
+// their transaction so the change and its record land together.
+func recordAdminAction(ctx *gin.Context, q *database.Queries, action string, target uuid.UUID, details map[string]any) error {
+	claims := ctx.MustGet(claimsKey).(auth.Claims)
+	actorId, err := claims.UserID()
+	if err != nil {
+		return err
+	}
+	if details == nil {
+		details = map[string]any{}
+	}
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	return q.CreateAdminAuditEntry(ctx, database.CreateAdminAuditEntryParams{
+		ActorID:      actorId,
+		ActorRole:    claims.Role,
+		Action:       action,
+		TargetUserID: uuid.NullUUID{UUID: target, Valid: target != uuid.Nil},
+		Details:      detailsJSON,
+		Ip:           ctx.ClientIP(),
+	})
+}
+
+// The admin doing the request, so they can't lock themselves out
+func adminID(ctx *gin.Context) uuid.UUID {
+	actorId, _ := ctx.MustGet(claimsKey).(auth.Claims).UserID()
+	return actorId
+}
+
+type adminUserRes struct {
+	ID            uuid.UUID  `json:"id"`
+	Email         string     `json:"email"`
+	Name          string     `json:"name"`
+	Role          string     `json:"role"`
+	EmailVerified bool       `json:"email_verified"`
+	CreatedAt     time.Time  `json:"created_at"`
+	DisabledAt    *time.Time `json:"disabled_at"`
+}
+
+func toAdminUserRes(user database.User) adminUserRes {
+	res := adminUserRes{
+		ID:            user.ID,
+		Email:         user.Email,
+		Name:          user.Name,
+		Role:          user.Role,
+		EmailVerified: user.VerifiedAt.Valid,
+		CreatedAt:     user.CreatedAt,
+	}
+	if user.DisabledAt.Valid {
+		res.DisabledAt = &user.DisabledAt.Time
+	}
+	return res
+}
+
+// Looks up the user in the :id param, responding itself when there's no such user
+func adminTargetUser(ctx *gin.Context, cfg *config.APIConfig) (database.User, bool) {
+	userId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid user id", err)
+		return database.User{}, false
+	}
+	user, err := cfg.DB.GetUserByID(ctx, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(ctx, http.StatusNotFound, "User not found", nil)
+		return database.User{}, false
+	}
+	if err != nil {
+		respondWithError(ctx, 500, "error getting user", err)
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// AdminListUsers searches users by email or name, newest first. ?q= can also be a user id.
+func AdminListUsers(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization and role checked by RequireRole
+		req := struct {
+			Query  string `form:"q"`
+			Role   string `form:"role"`
+			Limit  int32  `form:"limit"`
+			Offset int32  `form:"offset"`
+		}{Limit: 20}
+		if err := ctx.ShouldBind(&req); err != nil {
+			respondWithError(ctx, 400, "error parsing query", err)
+			return
+		}
+		if req.Limit < 1 || req.Limit > 100 || req.Offset < 0 {
+			respondWithError(ctx, 400, "limit must be 1 to 100 and offset at least 0", nil)
+			return
+		}
+		filter := database.CountUsersParams{
+			Pattern: likeEscaper.Replace(strings.TrimSpace(req.Query)),
+		}
+		if req.Role != "" {
+			if !slices.Contains(auth.Roles, req.Role) {
+				respondWithError(ctx, 400, "Invalid role", fmt.Errorf("must be one of %s", strings.Join(auth.Roles, "/")))
+				return
+			}
+			filter.Role = sql.NullString{String: req.Role, Valid: true}
+		}
+
+		err := recordAdminAction(ctx, cfg.DB, "users.search", uuid.Nil, map[string]any{"q": req.Query, "role": req.Role})
+		if err != nil {
+			respondWithError(ctx, 500, "error recording admin action", err)
+			return
+		}
+
+		results := []adminUserRes{}
+		if userId, err := uuid.Parse(strings.TrimSpace(req.Query)); err == nil {
+			user, err := cfg.DB.GetUserByID(ctx, userId)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				respondWithError(ctx, 500, "error getting user", err)
+				return
+			}
+			if err == nil && (!filter.Role.Valid || user.Role == filter.Role.String) {
+				results = append(results, toAdminUserRes(user))
+			}
+			ctx.JSON(200, gin.H{
+				"results":     results,
+				"total_count": len(results),
+				"next_offset": nil,
+			})
+			return
+		}
+
+		users, err := cfg.DB.SearchUsers(ctx, database.SearchUsersParams{
+			Pattern:    filter.Pattern,
+			Role:       filter.Role,
+			PageSize:   req.Limit,
+			PageOffset: req.Offset,
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error searching users", err)
+			return
+		}
+		total, err := cfg.DB.CountUsers(ctx, filter)
+		if err != nil {
+			respondWithError(ctx, 500, "error counting users", err)
+			return
+		}
+
+		var nextOffset *int32
+		if next := req.Offset + int32(len(users)); int64(next) < total {
+			nextOffset = &next
+		}
+		for _, user := range users {
+			results = append(results, toAdminUserRes(user))
+		}
+
+		ctx.JSON(200, gin.H{
+			"results":     results,
+			"total_count": total,
+			"next_offset": nextOffset,
+		})
+	}
+}
+
+// AdminGetUser shows one user along with how their account is secured
+func AdminGetUser(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization and role checked by RequireRole
+		user, ok := adminTargetUser(ctx, cfg)
+		if !ok {
+			return
+		}
+
+		twoFactor, err := twoFactorEnabled(ctx, cfg, user.ID)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting two-factor status", err)
+			return
+		}
+		sessions, err := cfg.DB.GetActiveSessionsForUser(ctx, database.GetActiveSessionsForUserParams{
+			UserID:    user.ID,
+			ExpiresAt: time.Now().UTC(),
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error getting sessions", err)
+			return
+		}
+		apiKeys, err := cfg.DB.GetAPIKeysForUser(ctx, user.ID)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting API keys", err)
+			return
+		}
+
+		if err := recordAdminAction(ctx, cfg.DB, "users.view", user.ID, nil); err != nil {
+			respondWithError(ctx, 500, "error recording admin action", err)
+			return
+		}
+
+		ctx.JSON(200, struct {
+			adminUserRes
+			TwoFactorEnabled bool `json:"two_factor_enabled"`
+			ActiveSessions   int  `json:"active_sessions"`
+			APIKeys          int  `json:"api_keys"`
+		}{
+			adminUserRes:     toAdminUserRes(user),
+			TwoFactorEnabled: twoFactor,
+			ActiveSessions:   len(sessions),
+			APIKeys:          len(apiKeys),
+		})
+	}
+}
+
+// AdminGetUserPortfolio shows a user's portfolio and holdings as they would see them. There's
+// no admin route that changes a portfolio.
+func AdminGetUserPortfolio(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization and role checked by RequireRole
+		user, ok := adminTargetUser(ctx, cfg)
+		if !ok {
+			return
+		}
+
+		var portfolio *PortfolioRes
+		res, err := GetPortfolio(ctx, cfg, user.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(ctx, 500, "error getting portfolio", err)
+			return
+		}
+		if err == nil {
+			portfolio = &res
+		}
+		holdings, err := GetHoldings(ctx, cfg, user.ID)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting holdings", err)
+			return
+		}
+		if holdings == nil {
+			holdings = []holdingRes{}
+		}
+
+		if err := recordAdminAction(ctx, cfg.DB, "users.portfolio.view", user.ID, nil); err != nil {
+			respondWithError(ctx, 500, "error recording admin action", err)
+			return
+		}
+
+		ctx.JSON(200, gin.H{
+			"user":      toAdminUserRes(user),
+			"portfolio": portfolio,
+			"holdings":  holdings,
+		})
+	}
+}
+
+// AdminDisableUser stops a user logging in and ends all their sessions and API keys with
+// it, an optional reason goes in the audit log
+func AdminDisableUser(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization and role checked by RequireRole
+		user, ok := adminTargetUser(ctx, cfg)
+		if !ok {
+			return
+		}
+		if user.ID == adminID(ctx) {
+			respondWithError(ctx, http.StatusBadRequest, "You can't disable your own account", nil)
+			return
+		}
+
+		req := struct {
+			Reason string `json:"reason"`
+		}{}
+		if ctx.Request.ContentLength != 0 {
+			if err := ctx.ShouldBindJSON(&req); err != nil {
+				respondWithError(ctx, http.StatusBadRequest, "Invalid request body", err)
+				return
+			}
+		}
+
+		err := withTx(ctx, cfg, func(q *database.Queries) error {
+			n, err := q.DisableUser(ctx, user.ID)
+			if err != nil || n == 0 {
+				return err
+			}
+			return recordAdminAction(ctx, q, "users.disable", user.ID, map[string]any{"reason": req.Reason})
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error disabling user", err)
+			return
+		}
+		if _, err := endOtherSessions(ctx, cfg, user.ID, uuid.Nil); err != nil {
+			respondWithError(ctx, 500, "error ending sessions", err)
+			return
+		}
+
+		if !user.DisabledAt.Valid {
+			user.DisabledAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		}
+		ctx.JSON(200, toAdminUserRes(user))
+	}
+}
+
+// AdminEnableUser lets a disabled user log in again
+func AdminEnableUser(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization and role checked by RequireRole
+		user, ok := adminTargetUser(ctx, cfg)
+		if !ok {
+			return
+		}
+
+		err := withTx(ctx, cfg, func(q *database.Queries) error {
+			n, err := q.EnableUser(ctx, user.ID)
+			if err != nil || n == 0 {
+				return err
+			}
+			return recordAdminAction(ctx, q, "users.enable", user.ID, nil)
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error enabling user", err)
+			return
+		}
+
+		user.DisabledAt = sql.NullTime{}
+		ctx.JSON(200, toAdminUserRes(user))
+	}
+}
+
+// AdminSetUserRole changes what a user may do. Their sessions end so no access token with
+// the old role is left.
+func AdminSetUserRole(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization and role checked by RequireRole
+		user, ok := adminTargetUser(ctx, cfg)
+		if !ok {
+			return
+		}
+		if user.ID == adminID(ctx) {
+			respondWithError(ctx, http.StatusBadRequest, "You can't change your own role", nil)
+			return
+		}
+
+		req := struct {
+			Role string `json:"role"`
+		}{}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+		if !slices.Contains(auth.Roles, req.Role) {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid role", fmt.Errorf("must be one of %s", strings.Join(auth.Roles, "/")))
+			return
+		}
+		if req.Role == user.Role {
+			ctx.JSON(200, toAdminUserRes(user))
+			return
+		}
+
+		err := withTx(ctx, cfg, func(q *database.Queries) error {
+			if _, err := q.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: req.Role}); err != nil {
+				return err
+			}
+			return recordAdminAction(ctx, q, "users.role", user.ID, map[string]any{"from": user.Role, "to": req.Role})
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error changing role", err)
+			return
+		}
+		if _, err := endOtherSessions(ctx, cfg, user.ID, uuid.Nil); err != nil {
+			respondWithError(ctx, 500, "error ending sessions", err)
+			return
+		}
+
+		user.Role = req.Role
+		ctx.JSON(200, toAdminUserRes(user))
+	}
+}
+
+// AdminSetUserMargin sets how far a user may short: margin_requirement and max_short_value.
+// Missing fields keep their value and a max_short_value of 0 removes the cap. Whether the user
+// shorts at all stays their own choice.
+func AdminSetUserMargin(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization and role checked by RequireRole
+		user, ok := adminTargetUser(ctx, cfg)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			MarginRequirement *decimal.Decimal `json:"margin_requirement"`
+			MaxShortValue     *decimal.Decimal `json:"max_short_value"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+		if req.MarginRequirement != nil && req.MarginRequirement.LessThan(decimal.NewFromInt(1)) {
+			respondWithError(ctx, http.StatusBadRequest, "margin_requirement must be >= 1", nil)
+			return
+		}
+		if req.MaxShortValue != nil && req.MaxShortValue.Sign() < 0 {
+			respondWithError(ctx, http.StatusBadRequest, "max_short_value can't be negative", nil)
+			return
+		}
+
+		var account database.MarginAccount
+		err := withTx(ctx, cfg, func(q *database.Queries) error {
+			before, err := getMarginAccount(ctx, q, user.ID)
+			if err != nil {
+				return err
+			}
+			params := database.SetMarginLimitsParams{
+				UserID:            user.ID,
+				MarginRequirement: before.MarginRequirement,
+				MaxShortValue:     before.MaxShortValue,
+			}
+			if req.MarginRequirement != nil {
+				params.MarginRequirement = *req.MarginRequirement
+			}
+			if req.MaxShortValue != nil {
+				params.MaxShortValue = decimal.NullDecimal{Decimal: *req.MaxShortValue, Valid: req.MaxShortValue.Sign() > 0}
+			}
+			if account, err = q.SetMarginLimits(ctx, params); err != nil {
+				return err
+			}
+			return recordAdminAction(ctx, q, "users.margin", user.ID, map[string]any{
+				"from": map[string]any{"margin_requirement": before.MarginRequirement, "max_short_value": before.MaxShortValue},
+				"to":   map[string]any{"margin_requirement": account.MarginRequirement, "max_short_value": account.MaxShortValue},
+			})
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error updating margin limits", err)
+			return
+		}
+		res, err := buildMarginRes(ctx, cfg, account)
+		if err != nil {
+			respondWithError(ctx, 500, "error computing margin", err)
+			return
+		}
+
+		ctx.JSON(200, res)
+	}
+}
+
+type adminAuditRes struct {
+	ID           uuid.UUID       `json:"id"`
+	ActorID      uuid.UUID       `json:"actor_id"`
+	ActorRole    string          `json:"actor_role"`
+	Action       string          `json:"action"`
+	TargetUserID *uuid.UUID      `json:"target_user_id"`
+	Details      json.RawMessage `json:"details"`
+	IP           string          `json:"ip"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// AdminGetAuditLog lists admin actions newest first, ?user_id= narrows it to one user
+func AdminGetAuditLog(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization and role checked by RequireRole
+		req := struct {
+			UserID string `form:"user_id"`
+			Limit  int32  `form:"limit"`
+			Offset int32  `form:"offset"`
+		}{Limit: 50}
+		if err := ctx.ShouldBind(&req); err != nil {
+			respondWithError(ctx, 400, "error parsing query", err)
+			return
+		}
+		if req.Limit < 1 || req.Limit > 200 || req.Offset < 0 {
+			respondWithError(ctx, 400, "limit must be 1 to 200 and offset at least 0", nil)
+			return
+		}
+		var target uuid.NullUUID
+		if req.UserID != "" {
+			userId, err := uuid.Parse(req.UserID)
+			if err != nil {
+				respondWithError(ctx, 400, "Invalid user_id", err)
+				return
+			}
+			target = uuid.NullUUID{UUID: userId, Valid: true}
+		}
+
+		entries, err := cfg.DB.GetAdminAuditEntries(ctx, database.GetAdminAuditEntriesParams{
+			TargetUserID: target,
+			PageSize:     req.Limit,
+			PageOffset:   req.Offset,
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error getting audit log", err)
+			return
+		}
+
+		results := []adminAuditRes{}
+		for _, entry := range entries {
+			res := adminAuditRes{
+				ID:        entry.ID,
+				ActorID:   entry.ActorID,
+				ActorRole: entry.ActorRole,
+				Action:    entry.Action,
+				Details:   entry.Details,
+				IP:        entry.Ip,
+				CreatedAt: entry.CreatedAt,
+			}
+			if entry.TargetUserID.Valid {
+				res.TargetUserID = &entry.TargetUserID.UUID
+			}
+			results = append(results, res)
+		}
+		var nextOffset *int32
+		if len(entries) == int(req.Limit) {
+			next := req.Offset + req.Limit
+			nextOffset = &next
+		}
+
+		ctx.JSON(200, gin.H{
+			"results":     results,
+			"next_offset": nextOffset,
+		})
+	}
+}
+
+// DeleteUsers wipes every user, only ever on a dev platform
+func DeleteUsers(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization and role checked by RequireRole
+		if os.Getenv("PLATFORM") != "dev" {
+			ctx.Writer.WriteHeader(403)
+			return
+		}
+
+		// The audit log has no foreign keys to users so the record of this survives it
+		err := withTx(ctx, cfg, func(q *database.Queries) error {
+			if err := q.DeleteAllUsers(ctx); err != nil {
+				return err
+			}
+			return recordAdminAction(ctx, q, "users.reset", uuid.Nil, nil)
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error deleting all users", err)
+			return
+		}
+		log.Println("All Users Deleted Successfully.")
+
+		ctx.Writer.WriteHeader(200)
+	}
+}
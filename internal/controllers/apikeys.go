@@ -0,0 +1,276 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/notify"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const maxAPIKeys = 20
+
+var errAPIKeyNotAllowed = errors.New("API keys can't be used for this route")
+
+// Routes an API key can be used for and the scope each one needs, anything else takes a login.
+// An empty scope means any key will do. Account settings, sessions and keys themselves are
+// left out on purpose so a leaked key can't take the account over.
+var apiKeyRouteScopes = map[string]string{
+	"GET /api/stocks":         "",
+	"GET /api/stocks/search":  "",
+	"GET /api/stocks/:symbol": "",
+
+	"GET /api/portfolio":             auth.ScopePortfolioRead,
+	"GET /api/holdings":              auth.ScopePortfolioRead,
+	"GET /api/transactions":          auth.ScopePortfolioRead,
+	"GET /api/orders":                auth.ScopePortfolioRead,
+	"GET /api/plans":                 auth.ScopePortfolioRead,
+	"GET /api/plans/:id/executions":  auth.ScopePortfolioRead,
+	"GET /api/cash":                  auth.ScopePortfolioRead,
+	"GET /api/margin":                auth.ScopePortfolioRead,
+	"GET /api/assets":                auth.ScopePortfolioRead,
+	"GET /api/assets/:id/valuations": auth.ScopePortfolioRead,
+	"GET /api/digests/latest":        auth.ScopePortfolioRead,
+	"GET /api/imports/profiles":      auth.ScopePortfolioRead,
+
+	"POST /api/transactions":          auth.ScopeTrade,
+	"POST /api/orders":                auth.ScopeTrade,
+	"DELETE /api/orders/:id":          auth.ScopeTrade,
+	"POST /api/plans":                 auth.ScopeTrade,
+	"POST /api/plans/:id/pause":       auth.ScopeTrade,
+	"POST /api/plans/:id/resume":      auth.ScopeTrade,
+	"DELETE /api/plans/:id":           auth.ScopeTrade,
+	"POST /api/imports":               auth.ScopeTrade,
+	"POST /api/cash":                  auth.ScopeTrade,
+	"POST /api/assets":                auth.ScopeTrade,
+	"POST /api/assets/:id/valuations": auth.ScopeTrade,
+	"DELETE /api/assets/:id":          auth.ScopeTrade,
+
+	"GET /api/exports/transactions":  auth.ScopeExport,
+	"GET /api/exports/holdings":      auth.ScopeExport,
+	"GET /api/reports/capital-gains": auth.ScopeExport,
+	"GET /api/statements":            auth.ScopeExport,
+	"GET /api/statements/:month":     auth.ScopeExport,
+}
+
+// APIKeyScopes runs before every route. Requests made with an API key only get through to
+// the routes in apiKeyRouteScopes the key has the scope for, the handlers then take the key
+// like any bearer token.
+func APIKeyScopes(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		token, err := auth.GetBearerToken(ctx.Request.Header)
+		if err != nil || !auth.IsAPIKey(token) || ctx.FullPath() == "" {
+			ctx.Next()
+			return
+		}
+
+		scope, ok := apiKeyRouteScopes[ctx.Request.Method+" "+ctx.FullPath()]
+		if !ok {
+			respondWithError(ctx, http.StatusForbidden, "Log in to use this route", errAPIKeyNotAllowed)
+			ctx.Abort()
+			return
+		}
+		claims, err := auth.GetClaims(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			ctx.Abort()
+			return
+		}
+		if scope != "" && !claims.HasScope(scope) {
+			respondWithError(ctx, http.StatusForbidden, "API key is missing the "+scope+" scope", nil)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+type apiKeyRes struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	// The whole key, only in the response that created it
+	Key string `json:"key,omitempty"`
+}
+
+func toAPIKeyRes(key database.ApiKey) apiKeyRes {
+	res := apiKeyRes{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if key.ExpiresAt.Valid {
+		res.ExpiresAt = &key.ExpiresAt.Time
+	}
+	if key.LastUsedAt.Valid {
+		res.LastUsedAt = &key.LastUsedAt.Time
+	}
+	return res
+}
+
+// CreateAPIKey makes a named key with the scopes asked for. The key is in the response and
+// can't be seen again after.
+func CreateAPIKey(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		var req struct {
+			Name      string     `json:"name"`
+			Scopes    []string   `json:"scopes"`
+			ExpiresAt *time.Time `json:"expires_at"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len(req.Name) > 100 {
+			respondWithError(ctx, http.StatusBadRequest, "Name is required and at most 100 characters", nil)
+			return
+		}
+		if len(req.Scopes) == 0 {
+			respondWithError(ctx, http.StatusBadRequest, "At least one scope is required", nil)
+			return
+		}
+		for _, scope := range req.Scopes {
+			if !slices.Contains(auth.Scopes, scope) {
+				respondWithError(ctx, http.StatusBadRequest, "Invalid scope", fmt.Errorf("%q must be one of %s", scope, strings.Join(auth.Scopes, "/")))
+				return
+			}
+		}
+		slices.Sort(req.Scopes)
+		req.Scopes = slices.Compact(req.Scopes)
+		var expiresAt sql.NullTime
+		if req.ExpiresAt != nil {
+			if !req.ExpiresAt.After(time.Now()) {
+				respondWithError(ctx, http.StatusBadRequest, "expires_at must be in the future", nil)
+				return
+			}
+			expiresAt = sql.NullTime{Time: req.ExpiresAt.UTC(), Valid: true}
+		}
+
+		existing, err := cfg.DB.GetAPIKeysForUser(ctx, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting API keys", err)
+			return
+		}
+		if len(existing) >= maxAPIKeys {
+			respondWithError(ctx, http.StatusConflict, fmt.Sprintf("At most %d API keys, revoke one first", maxAPIKeys), nil)
+			return
+		}
+
+		key, prefix, err := auth.MakeAPIKey()
+		if err != nil {
+			respondWithError(ctx, 500, "error making API key", err)
+			return
+		}
+		apiKey, err := cfg.DB.CreateAPIKey(ctx, database.CreateAPIKeyParams{
+			UserID:    userId,
+			Name:      req.Name,
+			Prefix:    prefix,
+			KeyHash:   auth.HashToken(key),
+			Scopes:    req.Scopes,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error creating API key", err)
+			return
+		}
+
+		err = notify.Enqueue(ctx, cfg.RD, notify.Message{
+			UserID:   userId,
+			Kind:     notify.KindSecurity,
+			Template: "api_key_created",
+			Subject:  "A new API key was created",
+			Data: map[string]any{
+				"KeyName":   apiKey.Name,
+				"Prefix":    apiKey.Prefix,
+				"Scopes":    strings.Join(apiKey.Scopes, ", "),
+				"Time":      time.Now().UTC().Format(time.RFC1123),
+				"IP":        ctx.ClientIP(),
+				"UserAgent": ctx.Request.UserAgent(),
+			},
+		})
+		if err != nil {
+			log.Printf("Error queueing API key notification: %v\n", err)
+		}
+
+		res := toAPIKeyRes(apiKey)
+		res.Key = key
+		ctx.JSON(http.StatusCreated, res)
+	}
+}
+
+// GetAPIKeys lists the keys that haven't been revoked, expired ones included
+func GetAPIKeys(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		keys, err := cfg.DB.GetAPIKeysForUser(ctx, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting API keys", err)
+			return
+		}
+
+		res := []apiKeyRes{}
+		for _, key := range keys {
+			res = append(res, toAPIKeyRes(key))
+		}
+		ctx.JSON(200, res)
+	}
+}
+
+// DeleteAPIKey revokes a key, requests made with it fail from then on
+func DeleteAPIKey(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		keyId, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid API key id", err)
+			return
+		}
+
+		n, err := cfg.DB.RevokeAPIKey(ctx, database.RevokeAPIKeyParams{ID: keyId, UserID: userId})
+		if err != nil {
+			respondWithError(ctx, 500, "error revoking API key", err)
+			return
+		}
+		if n == 0 {
+			respondWithError(ctx, 404, "API key not found", nil)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Things the price feed can't quote, valued by hand
+var manualAssetTypes = []string{"FIXED_DEPOSIT", "BOND", "PRIVATE_EQUITY", "REAL_ESTATE", "OTHER"}
+
+type compoundingRule struct {
+	months, days   int
+	periodsPerYear int32
+}
+
+var compoundingRules = map[string]compoundingRule{
+	"ANNUAL":    {months: 12, periodsPerYear: 1},
+	"QUARTERLY": {months: 3, periodsPerYear: 4},
+	"MONTHLY":   {months: 1, periodsPerYear: 12},
+	"DAILY":     {days: 1, periodsPerYear: 365},
+}
+
+type manualAssetRes struct {
+	database.ManualAsset
+	CurrentValue           decimal.Decimal `json:"current_value"`
+	ProfitOrLoss           decimal.Decimal `json:"pnl"`
+	ProfitOrLossPercentage decimal.Decimal `json:"pnl_percentage"`
+}
+
+func CreateManualAsset(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		// Parse request, value defaults to the cost basis and valued_on to today
+		var req struct {
+			Name         string           `json:"name"`
+			AssetType    string           `json:"asset_type"`
+			CostBasis    decimal.Decimal  `json:"cost_basis"`
+			Value        *decimal.Decimal `json:"value"`
+			ValuedOn     string           `json:"valued_on"`
+			InterestRate *decimal.Decimal `json:"interest_rate"`
+			Compounding  string           `json:"compounding"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+		req.AssetType = strings.ToUpper(req.AssetType)
+		req.Compounding = strings.ToUpper(req.Compounding)
+		if strings.TrimSpace(req.Name) == "" || !slices.Contains(manualAssetTypes, req.AssetType) {
+			respondWithError(ctx, http.StatusBadRequest, "name is required and asset_type must be FIXED_DEPOSIT/BOND/PRIVATE_EQUITY/REAL_ESTATE/OTHER", nil)
+			return
+		}
+		if req.CostBasis.Sign() < 0 {
+			respondWithError(ctx, http.StatusBadRequest, "cost_basis can't be negative", nil)
+			return
+		}
+		value := req.CostBasis
+		if req.Value != nil {
+			value = *req.Value
+		}
+		if value.Sign() < 0 {
+			respondWithError(ctx, http.StatusBadRequest, "value can't be negative", nil)
+			return
+		}
+		valuedOn, ok := parseValuationDate(ctx, req.ValuedOn)
+		if !ok {
+			return
+		}
+
+		// Accrual is optional but needs both a rate and a rule
+		var interestRate decimal.NullDecimal
+		var compounding sql.NullString
+		if req.InterestRate != nil || req.Compounding != "" {
+			if _, ok := compoundingRules[req.Compounding]; !ok || req.InterestRate == nil || req.InterestRate.Sign() < 0 {
+				respondWithError(ctx, http.StatusBadRequest, "interest_rate >= 0 needs compounding ANNUAL/QUARTERLY/MONTHLY/DAILY", nil)
+				return
+			}
+			interestRate = decimal.NullDecimal{Decimal: *req.InterestRate, Valid: true}
+			compounding = sql.NullString{String: req.Compounding, Valid: true}
+		}
+
+		// The asset and its first valuation commit together
+		var asset database.ManualAsset
+		err = withTx(ctx, cfg, func(q *database.Queries) error {
+			var err error
+			asset, err = q.CreateManualAsset(ctx, database.CreateManualAssetParams{
+				UserID:       userId,
+				Name:         req.Name,
+				AssetType:    req.AssetType,
+				CostBasis:    req.CostBasis,
+				Value:        value,
+				ValuedOn:     valuedOn,
+				InterestRate: interestRate,
+				Compounding:  compounding,
+			})
+			if err != nil {
+				return err
+			}
+			_, err = q.CreateManualAssetValuation(ctx, database.CreateManualAssetValuationParams{
+				AssetID:  asset.ID,
+				Value:    value,
+				ValuedOn: valuedOn,
+			})
+			return err
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error creating asset", err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, buildManualAssetRes(asset, time.Now()))
+	}
+}
+
+func GetManualAssets(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		assets, err := cfg.DB.GetManualAssetsForUser(ctx, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting assets", err)
+			return
+		}
+
+		now := time.Now()
+		res := make([]manualAssetRes, 0, len(assets))
+		for _, asset := range assets {
+			res = append(res, buildManualAssetRes(asset, now))
+		}
+		ctx.JSON(200, res)
+	}
+}
+
+func GetManualAssetValuations(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		asset, ok := manualAssetFromRequest(ctx, cfg)
+		if !ok {
+			return
+		}
+
+		valuations, err := cfg.DB.GetManualAssetValuations(ctx, asset.ID)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting valuations", err)
+			return
+		}
+		ctx.JSON(200, valuations)
+	}
+}
+
+// AddManualAssetValuation records a value on a date, the latest one becomes the asset's value
+// and accrual restarts from it
+func AddManualAssetValuation(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		asset, ok := manualAssetFromRequest(ctx, cfg)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Value    decimal.Decimal `json:"value"`
+			ValuedOn string          `json:"valued_on"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+		if req.Value.Sign() < 0 {
+			respondWithError(ctx, http.StatusBadRequest, "value can't be negative", nil)
+			return
+		}
+		valuedOn, ok := parseValuationDate(ctx, req.ValuedOn)
+		if !ok {
+			return
+		}
+
+		var valuation database.ManualAssetValuation
+		err := withTx(ctx, cfg, func(q *database.Queries) error {
+			var err error
+			valuation, err = q.CreateManualAssetValuation(ctx, database.CreateManualAssetValuationParams{
+				AssetID:  asset.ID,
+				Value:    req.Value,
+				ValuedOn: valuedOn,
+			})
+			if err != nil {
+				return err
+			}
+			// Back-dated valuations only go into the history
+			return q.SetManualAssetLatestValue(ctx, database.SetManualAssetLatestValueParams{
+				ID:       asset.ID,
+				Value:    req.Value,
+				ValuedOn: valuedOn,
+			})
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error recording valuation", err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, valuation)
+	}
+}
+
+func DeleteManualAsset(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		asset, ok := manualAssetFromRequest(ctx, cfg)
+		if !ok {
+			return
+		}
+
+		if _, err := cfg.DB.DeleteManualAsset(ctx, database.DeleteManualAssetParams{
+			ID:     asset.ID,
+			UserID: asset.UserID,
+		}); err != nil {
+			respondWithError(ctx, 500, "error deleting asset", err)
+			return
+		}
+		ctx.JSON(200, gin.H{"message": "Asset deleted"})
+	}
+}
+
+// Loads the caller's asset from the :id param, responding itself on failure
+func manualAssetFromRequest(ctx *gin.Context, cfg *config.APIConfig) (database.ManualAsset, bool) {
+	// Authorization required for this route
+	userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+	if err != nil {
+		respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+		return database.ManualAsset{}, false
+	}
+
+	assetId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid asset id", err)
+		return database.ManualAsset{}, false
+	}
+
+	asset, err := cfg.DB.GetManualAssetForUser(ctx, database.GetManualAssetForUserParams{
+		ID:     assetId,
+		UserID: userId,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(ctx, 404, "No asset with this id", err)
+			return database.ManualAsset{}, false
+		}
+		respondWithError(ctx, 500, "error getting asset", err)
+		return database.ManualAsset{}, false
+	}
+	return asset, true
+}
+
+// Empty means today, future dates aren't valuations
+func parseValuationDate(ctx *gin.Context, s string) (time.Time, bool) {
+	today := dateOnly(time.Now())
+	if s == "" {
+		return today, true
+	}
+	day, err := time.Parse(time.DateOnly, s)
+	if err != nil || day.After(today) {
+		respondWithError(ctx, http.StatusBadRequest, "valued_on must be YYYY-MM-DD and not in the future", err)
+		return time.Time{}, false
+	}
+	return day, true
+}
+
+func buildManualAssetRes(asset database.ManualAsset, now time.Time) manualAssetRes {
+	value := manualAssetValue(asset, now)
+	pnl := value.Sub(asset.CostBasis)
+	return manualAssetRes{
+		ManualAsset:            asset,
+		CurrentValue:           value,
+		ProfitOrLoss:           pnl,
+		ProfitOrLossPercentage: utils.Percentage(pnl, asset.CostBasis),
+	}
+}
+
+// Latest valuation grown by the asset's accrual rule up to now
+func manualAssetValue(asset database.ManualAsset, now time.Time) decimal.Decimal {
+	rule, ok := compoundingRules[asset.Compounding.String]
+	if !asset.InterestRate.Valid || !ok {
+		return asset.Value
+	}
+
+	// Whole periods since the valuation, stepping from the valuation date so month ends don't drift
+	today := dateOnly(now)
+	var periods int32
+	for !asset.ValuedOn.AddDate(0, rule.months*int(periods+1), rule.days*int(periods+1)).After(today) {
+		periods++
+	}
+	lastPeriod := asset.ValuedOn.AddDate(0, rule.months*int(periods), rule.days*int(periods))
+	days := int(today.Sub(lastPeriod).Hours() / 24)
+
+	return utils.Accrue(asset.Value, asset.InterestRate.Decimal, rule.periodsPerYear, periods, days)
+}
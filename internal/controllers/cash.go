@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	deposit    = "DEPOSIT"
+	withdrawal = "WITHDRAWAL"
+	dividend   = "DIVIDEND"
+	fee        = "FEE"
+)
+
+var ErrInsufficientCash = errors.New("not enough cash")
+
+func GetCash(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		balance, err := cfg.DB.GetCashBalance(ctx, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting cash balance", err)
+			return
+		}
+
+		movements, err := cfg.DB.GetCashMovementsForUser(ctx, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting cash movements", err)
+			return
+		}
+
+		ctx.JSON(200, gin.H{
+			"balance":   balance,
+			"movements": movements,
+		})
+	}
+}
+
+// CreateCashMovement records deposits, withdrawals, dividends and fees, trades settle on their own
+func CreateCashMovement(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Applying rate limiter to limit cash movements
+		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "transaction") {
+			respondWithError(ctx, http.StatusTooManyRequests, "Wait for some time!", nil)
+			return
+		}
+
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		// Parse request, amount is always positive and the type decides the sign
+		var req struct {
+			Type   string          `json:"type"`
+			Amount decimal.Decimal `json:"amount"`
+			Note   string          `json:"note"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+		if req.Amount.Sign() <= 0 || !utils.FitsPrecision(req.Amount, utils.MoneyScale) {
+			respondWithError(ctx, http.StatusBadRequest, "Amount must be > 0 with at most 8 decimal places", nil)
+			return
+		}
+
+		amount := req.Amount
+		switch req.Type {
+		case deposit, dividend:
+		case withdrawal, fee:
+			amount = req.Amount.Neg()
+		default:
+			respondWithError(ctx, http.StatusBadRequest, "type must be DEPOSIT/WITHDRAWAL/DIVIDEND/FEE", nil)
+			return
+		}
+
+		var movement database.CashMovement
+		err = withTx(ctx, cfg, func(q *database.Queries) error {
+			// No overdrafts on withdrawals, cash backing short positions stays put. Locked like
+			// a trade so a short can't go through between the check and the withdrawal.
+			if req.Type == withdrawal {
+				if err := q.LockUserForTrade(ctx, userId); err != nil {
+					return err
+				}
+				balance, err := q.GetCashBalance(ctx, userId)
+				if err != nil {
+					return err
+				}
+				collateral, err := requiredCollateral(ctx, q, userId)
+				if err != nil {
+					return err
+				}
+				if balance.Sub(collateral).LessThan(req.Amount) {
+					return ErrInsufficientCash
+				}
+			}
+
+			var err error
+			movement, err = q.CreateCashMovement(ctx, database.CreateCashMovementParams{
+				UserID: userId,
+				Type:   req.Type,
+				Amount: amount,
+				Note:   req.Note,
+			})
+			return err
+		})
+		if errors.Is(err, ErrInsufficientCash) {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid withdrawal", err)
+			return
+		}
+		if err != nil {
+			respondWithError(ctx, 500, "error recording cash movement", err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, movement)
+	}
+}
@@ -7,29 +7,110 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 const (
-	buy      = "BUY"
-	sell     = "SELL"
-	YahooAPI = "https://query1.finance.yahoo.com/v8/finance/chart/"
+	buy        = "BUY"
+	sell       = "SELL"
+	sellShort  = "SELL_SHORT"
+	buyToCover = "BUY_TO_COVER"
+	YahooAPI   = "https://query1.finance.yahoo.com/v8/finance/chart/"
 )
 
+var (
+	ErrStockNotOwned       = errors.New("can't sell a stock you don't own")
+	ErrInsufficientHolding = errors.New("can't sell more than you hold")
+	ErrPositionIsShort     = errors.New("position is short, use BUY_TO_COVER")
+	ErrPositionIsLong      = errors.New("sell the long position before selling short")
+	ErrNoShortPosition     = errors.New("no short position to cover")
+	ErrInsufficientCover   = errors.New("can't cover more than is shorted")
+)
+
+// Reports whether err is the user's fault rather than ours
+func IsTradeError(err error) bool {
+	for _, tradeErr := range []error{
+		ErrStockNotOwned, ErrInsufficientHolding, ErrPositionIsShort, ErrPositionIsLong,
+		ErrNoShortPosition, ErrInsufficientCover, ErrShortingDisabled, ErrInsufficientMargin,
+	} {
+		if errors.Is(err, tradeErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// How long a symbol Yahoo doesn't know is rejected without asking again
+const unknownSymbolTTL = time.Hour
+
+var ErrUnknownSymbol = errors.New("unknown symbol")
+
+func unknownSymbolKey(symbol string) string {
+	return "unknown-symbol:" + symbol
+}
+
+// Whether symbol is of a kind the instrument master is loaded with, NSE and BSE equities and
+// US listed stocks and ETFs. Indices, currencies and futures, crypto pairs, mutual funds and
+// other exchanges aren't in any listing file the loader reads.
+func masterCovers(symbol string) bool {
+	base, suffix := symbol, ""
+	if i := strings.LastIndex(symbol, "."); i > 0 {
+		base, suffix = symbol[:i], symbol[i:]
+	}
+	switch {
+	case strings.HasPrefix(symbol, "^") || strings.Contains(symbol, "="):
+		return false
+	case strings.HasPrefix(symbol, "0P"):
+		// Morningstar ids, which is how Yahoo quotes Indian mutual funds
+		return false
+	case suffix == ".NS" || suffix == ".BO":
+		return true
+	case suffix != "":
+		return false
+	}
+	// BTC-USD and the like, US share classes only ever have a letter after the dash
+	if _, quote, ok := strings.Cut(base, "-"); ok && len(quote) >= 3 {
+		return false
+	}
+	// Five letter symbols ending in X are mutual funds, which aren't listed on an exchange
+	return !(len(base) == 5 && strings.HasSuffix(base, "X"))
+}
+
+// Responds to a getOrFetchStock error, the caller's fault for bad or unknown symbols
+func respondWithStockError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, utils.ErrInvalidSymbol):
+		respondWithError(ctx, http.StatusBadRequest, "Invalid symbol", err)
+	case errors.Is(err, ErrUnknownSymbol):
+		respondWithError(ctx, http.StatusNotFound, "Unknown symbol", err)
+	default:
+		respondWithError(ctx, http.StatusInternalServerError, "Failed to resolve stock info", err)
+	}
+}
+
 func respondWithError(ctx *gin.Context, statusCode int, errorString string, err error) {
 	ctx.JSON(statusCode, gin.H{
 		"Error": fmt.Sprintf("%s: %v\n", errorString, err),
 	})
 }
 
-// Helper to get stock from DB or Yahoo
+// Helper to get stock from DB or Yahoo, symbol can be written any way NormalizeSymbol takes
 func getOrFetchStock(ctx context.Context, cfg *config.APIConfig, symbol string) (database.Stock, error) {
+	symbol, err := utils.NormalizeSymbol(symbol)
+	if err != nil {
+		return database.Stock{}, err
+	}
+
 	// Fetch from cache
 	stockJSON, err := cfg.RD.Get(ctx, "stock:"+symbol).Result()
 	if err == nil {
@@ -52,23 +133,60 @@ func getOrFetchStock(ctx context.Context, cfg *config.APIConfig, symbol string)
 		return database.Stock{}, err
 	}
 
+	// What the listing files cover has to be in the master. Anything else still gets one try
+	// at Yahoo, a miss is remembered so typos don't keep calling out.
+	_, err = cfg.DB.GetInstrument(ctx, symbol)
+	if errors.Is(err, sql.ErrNoRows) {
+		if masterCovers(symbol) {
+			return database.Stock{}, fmt.Errorf("%w: %s isn't in the instrument master", ErrUnknownSymbol, symbol)
+		}
+		if n, _ := cfg.RD.Exists(ctx, unknownSymbolKey(symbol)).Result(); n > 0 {
+			return database.Stock{}, fmt.Errorf("%w: %s", ErrUnknownSymbol, symbol)
+		}
+	} else if err != nil {
+		return database.Stock{}, err
+	}
+
 	// Fetch from Yahoo if not in DB
 	var client http.Client
-	stonkFromYahoo, err := FetchFromYahoo(symbol, &client)
+	yahooResult, err := fetchYahooResult(symbol, &client)
+	if errors.Is(err, ErrUnknownSymbol) {
+		cfg.RD.Set(ctx, unknownSymbolKey(symbol), 1, unknownSymbolTTL)
+	}
 	if err != nil {
 		return database.Stock{}, err
 	}
+	stonkFromYahoo := yahooResult.ToStock()
 
 	created, err := cfg.DB.CreateNewStockOrUpdateExisting(ctx, database.CreateNewStockOrUpdateExistingParams{
-		Symbol:        stonkFromYahoo.Symbol,
-		CompanyName:   stonkFromYahoo.CompanyName,
-		CurrentPrice:  stonkFromYahoo.CurrentPrice,
-		PreviousClose: stonkFromYahoo.PreviousClose,
+		Symbol:            stonkFromYahoo.Symbol,
+		CompanyName:       stonkFromYahoo.CompanyName,
+		CurrentPrice:      stonkFromYahoo.CurrentPrice,
+		PreviousClose:     stonkFromYahoo.PreviousClose,
+		QuantityPrecision: stonkFromYahoo.QuantityPrecision,
+		AssetClass:        stonkFromYahoo.AssetClass,
+		DayHigh:           stonkFromYahoo.DayHigh,
+		DayLow:            stonkFromYahoo.DayLow,
+		Volume:            stonkFromYahoo.Volume,
+		FiftyTwoWeekHigh:  stonkFromYahoo.FiftyTwoWeekHigh,
+		FiftyTwoWeekLow:   stonkFromYahoo.FiftyTwoWeekLow,
 	})
 	if err != nil {
 		return database.Stock{}, err
 	}
 
+	// Searchable from now on even if no listing file has it
+	err = cfg.DB.AddInstrument(ctx, database.AddInstrumentParams{
+		Symbol:     created.Symbol,
+		Name:       created.CompanyName,
+		Exchange:   yahooResult.Meta.Exchange(),
+		Currency:   yahooResult.Meta.Currency,
+		AssetClass: created.AssetClass,
+	})
+	if err != nil {
+		log.Printf("Error adding instrument %s: %v\n", created.Symbol, err)
+	}
+
 	// warm the cache after creating
 	if stockJSON, err := json.Marshal(created); err == nil {
 		cfg.RD.Set(ctx, "stock:"+created.Symbol, stockJSON, 30*time.Second)
@@ -79,10 +197,18 @@ func getOrFetchStock(ctx context.Context, cfg *config.APIConfig, symbol string)
 
 // Util to fetch stock data from free YahooAPI
 func FetchFromYahoo(symbol string, client *http.Client) (database.Stock, error) {
+	yahooResult, err := fetchYahooResult(symbol, client)
+	if err != nil {
+		return database.Stock{}, err
+	}
+	return yahooResult.ToStock(), nil
+}
+
+func fetchYahooResult(symbol string, client *http.Client) (config.YahooResult, error) {
 	var resp config.YahooFinanceResponse
 	reqToStockAPI, err := http.NewRequest("GET", YahooAPI+symbol, nil)
 	if err != nil {
-		return database.Stock{}, err
+		return config.YahooResult{}, err
 	}
 
 	// Adding headers to avoid 429 from YahooAPI
@@ -91,37 +217,199 @@ func FetchFromYahoo(symbol string, client *http.Client) (database.Stock, error)
 
 	yahooRes, err := client.Do(reqToStockAPI)
 	if err != nil {
-		return database.Stock{}, err
+		return config.YahooResult{}, err
 	}
 	defer yahooRes.Body.Close()
+	if yahooRes.StatusCode == http.StatusNotFound {
+		return config.YahooResult{}, fmt.Errorf("%w: %s", ErrUnknownSymbol, symbol)
+	}
 	if yahooRes.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(yahooRes.Body)
-		return database.Stock{}, fmt.Errorf("yahoo api error: %s - %s", yahooRes.Status, string(body))
+		return config.YahooResult{}, fmt.Errorf("yahoo api error: %s - %s", yahooRes.Status, string(body))
 	}
 	if err := json.NewDecoder(yahooRes.Body).Decode(&resp); err != nil {
-		return database.Stock{}, err
+		return config.YahooResult{}, err
 	}
 
 	if len(resp.Chart.Result) == 0 {
-		return database.Stock{}, fmt.Errorf("no results in Yahoo response")
+		return config.YahooResult{}, fmt.Errorf("%w: %s", ErrUnknownSymbol, symbol)
 	}
-	yahooResult := resp.Chart.Result[0]
-	return yahooResult.ToStock(), nil
+	return resp.Chart.Result[0], nil
+}
+
+// Rejects quantities with more decimal places than the stock allows
+func checkQuantityPrecision(quantity decimal.Decimal, stonk database.Stock) error {
+	if !utils.FitsPrecision(quantity, stonk.QuantityPrecision) {
+		return fmt.Errorf("%s quantities allow at most %d decimal places", stonk.Symbol, stonk.QuantityPrecision)
+	}
+	return nil
+}
+
+// Runs fn inside a DB transaction, committing only if it returns nil
+func withTx(ctx context.Context, cfg *config.APIConfig, fn func(q *database.Queries) error) error {
+	tx, err := cfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(cfg.DB.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type TradeResult struct {
+	Transaction database.Transaction
+	Holding     database.Holding
+	SoldOut     bool
+}
+
+// Where a trade came from, zero for trades made now through the API
+type tradeOrigin struct {
+	TradedAt   sql.NullTime
+	ExternalID string
+}
+
+// ExecuteTransaction records a BUY/SELL/SELL_SHORT/BUY_TO_COVER at the given price and updates the user's holding.
+// Takes the queries of the DB transaction to run in, the locks it takes last until that ends.
+func ExecuteTransaction(ctx context.Context, q *database.Queries, userId uuid.UUID, symbol, txnType string, quantity, price decimal.Decimal) (TradeResult, error) {
+	return executeTransaction(ctx, q, userId, symbol, txnType, quantity, price, tradeOrigin{})
+}
+
+func executeTransaction(ctx context.Context, q *database.Queries, userId uuid.UUID, symbol, txnType string, quantity, price decimal.Decimal, origin tradeOrigin) (TradeResult, error) {
+	// One trade of the user's at a time, the holding is written back whole and the margin
+	// check adds up all their shorts, neither may change under us until this commits
+	if err := q.LockUserForTrade(ctx, userId); err != nil {
+		return TradeResult{}, err
+	}
+	currHolding, err := q.GetHoldingByStockSymbolForUpdate(ctx, database.GetHoldingByStockSymbolForUpdateParams{
+		UserID:      userId,
+		StockSymbol: symbol,
+	})
+	isNewHolding := errors.Is(err, sql.ErrNoRows)
+	if err != nil && !isNewHolding {
+		return TradeResult{}, err
+	}
+	isShort := currHolding.Quantity.Sign() < 0
+
+	// Compute transaction outcome
+	var newQuantity, totalInvested, newAvg, totalAmount decimal.Decimal
+	switch txnType {
+	case buy:
+		if isShort {
+			return TradeResult{}, ErrPositionIsShort
+		}
+		newQuantity, totalInvested, newAvg, _, _, totalAmount =
+			utils.HandleBuyTransaction(quantity, currHolding.Quantity, currHolding.TotalInvested, price)
+	case sell:
+		if isNewHolding {
+			return TradeResult{}, ErrStockNotOwned
+		}
+		if isShort {
+			return TradeResult{}, ErrPositionIsShort
+		}
+		if quantity.GreaterThan(currHolding.Quantity) {
+			return TradeResult{}, ErrInsufficientHolding
+		}
+		newQuantity, totalInvested, newAvg, _, _, totalAmount =
+			utils.HandleSellTransaction(quantity, currHolding.Quantity, currHolding.TotalInvested, price)
+	case sellShort:
+		if !isNewHolding && !isShort {
+			return TradeResult{}, ErrPositionIsLong
+		}
+		newQuantity, totalInvested, newAvg, _, _, totalAmount =
+			utils.HandleShortTransaction(quantity, currHolding.Quantity, currHolding.TotalInvested, price)
+		if err := checkShortMargin(ctx, q, userId, totalAmount); err != nil {
+			return TradeResult{}, err
+		}
+	case buyToCover:
+		if !isShort {
+			return TradeResult{}, ErrNoShortPosition
+		}
+		if quantity.GreaterThan(currHolding.Quantity.Neg()) {
+			return TradeResult{}, ErrInsufficientCover
+		}
+		newQuantity, totalInvested, newAvg, _, _, totalAmount =
+			utils.HandleCoverTransaction(quantity, currHolding.Quantity, currHolding.TotalInvested, price)
+	default:
+		return TradeResult{}, fmt.Errorf("unknown transaction type %q", txnType)
+	}
+
+	// Insert transaction record
+	txn, err := q.CreateATransaction(ctx, database.CreateATransactionParams{
+		UserID:      userId,
+		StockSymbol: symbol,
+		Type:        txnType,
+		Quantity:    quantity,
+		Price:       price,
+		TotalAmount: totalAmount,
+		ExternalID:  origin.ExternalID,
+		CreatedAt:   origin.TradedAt,
+	})
+	if err != nil {
+		return TradeResult{}, err
+	}
+
+	// Settle the trade against the cash balance, buys and covers debit, sells and shorts credit
+	cashAmount := totalAmount
+	if txnType == buy || txnType == buyToCover {
+		cashAmount = totalAmount.Neg()
+	}
+	_, err = q.CreateCashMovement(ctx, database.CreateCashMovementParams{
+		UserID:        userId,
+		Type:          txnType,
+		Amount:        cashAmount,
+		TransactionID: uuid.NullUUID{UUID: txn.ID, Valid: true},
+		CreatedAt:     origin.TradedAt,
+	})
+	if err != nil {
+		return TradeResult{}, err
+	}
+
+	// Update or remove holding
+	if newQuantity.IsZero() {
+		if _, err := q.DeleteHoldingsOnSellOut(ctx, database.DeleteHoldingsOnSellOutParams{
+			UserID:      userId,
+			StockSymbol: symbol,
+		}); err != nil {
+			return TradeResult{}, err
+		}
+		return TradeResult{Transaction: txn, SoldOut: true}, nil
+	}
+
+	updatedHolding, err := q.CreateNewHoldingOrUpdateExistingForUser(ctx,
+		database.CreateNewHoldingOrUpdateExistingForUserParams{
+			UserID:        userId,
+			StockSymbol:   symbol,
+			Quantity:      newQuantity,
+			AveragePrice:  newAvg,
+			TotalInvested: totalInvested,
+		})
+	if err != nil {
+		return TradeResult{}, err
+	}
+
+	return TradeResult{Transaction: txn, Holding: updatedHolding}, nil
 }
 
 type holdingRes struct {
-	StockSymbol            string  `json:"stock_symbol"`
-	CompanyName            string  `json:"company_name"`
-	Quantity               int     `json:"quantity"`
-	AveragePrice           float64 `json:"average_price"`
-	CurrentPrice           float64 `json:"curr_price"`
-	CurrentValue           float64 `json:"curr_evaluation"`
-	ProfitOrLoss           float64 `json:"pnl"`
-	ProfitOrLossPercentage float64 `json:"pnl_percentage"`
-	TotalInvested          float64 `json:"total_invested"`
-}
-
-func GetHoldings(ctx *gin.Context, cfg *config.APIConfig, userId uuid.UUID) ([]holdingRes, error) {
+	StockSymbol            string          `json:"stock_symbol"`
+	CompanyName            string          `json:"company_name"`
+	AssetClass             string          `json:"asset_class"`
+	Quantity               decimal.Decimal `json:"quantity"`
+	AveragePrice           decimal.Decimal `json:"average_price"`
+	CurrentPrice           decimal.Decimal `json:"curr_price"`
+	CurrentValue           decimal.Decimal `json:"curr_evaluation"`
+	ProfitOrLoss           decimal.Decimal `json:"pnl"`
+	ProfitOrLossPercentage decimal.Decimal `json:"pnl_percentage"`
+	TotalInvested          decimal.Decimal `json:"total_invested"`
+	PreviousClose          decimal.Decimal `json:"previous_close"`
+	DayChange              decimal.Decimal `json:"day_change"`
+	DayChangePercentage    decimal.Decimal `json:"day_change_percentage"`
+}
+
+func GetHoldings(ctx context.Context, cfg *config.APIConfig, userId uuid.UUID) ([]holdingRes, error) {
 	// Get holdings from user
 	holdings, err := cfg.DB.GetAllHoldingsForUser(ctx, userId)
 	if err != nil {
@@ -131,19 +419,30 @@ func GetHoldings(ctx *gin.Context, cfg *config.APIConfig, userId uuid.UUID) ([]h
 	// calculate pnl and pnlpercentage for each holding and store in res
 	var res []holdingRes
 	for _, holding := range holdings {
-		currValue := float64(holding.Quantity) * holding.CurrentPrice
-		pnl := currValue - holding.TotalInvested
-		pnlPercentage := (pnl / holding.TotalInvested) * 100
+		currValue := utils.Amount(holding.Quantity, holding.CurrentPrice)
+		pnl := currValue.Sub(holding.TotalInvested)
+
+		// no previous close means no change for the day
+		prevClose := holding.CurrentPrice
+		if holding.PreviousClose.Valid && holding.PreviousClose.Decimal.Sign() > 0 {
+			prevClose = holding.PreviousClose.Decimal
+		}
+		priceChange := holding.CurrentPrice.Sub(prevClose)
+
 		hold := holdingRes{
 			StockSymbol:            holding.StockSymbol,
 			CompanyName:            holding.CompanyName,
-			Quantity:               int(holding.Quantity),
+			AssetClass:             holding.AssetClass,
+			Quantity:               holding.Quantity,
 			AveragePrice:           holding.AveragePrice,
 			CurrentPrice:           holding.CurrentPrice,
 			CurrentValue:           currValue,
 			ProfitOrLoss:           pnl,
-			ProfitOrLossPercentage: pnlPercentage,
+			ProfitOrLossPercentage: utils.Percentage(pnl, holding.TotalInvested.Abs()),
 			TotalInvested:          holding.TotalInvested,
+			PreviousClose:          prevClose,
+			DayChange:              utils.Amount(holding.Quantity, priceChange),
+			DayChangePercentage:    utils.Percentage(priceChange, prevClose),
 		}
 
 		res = append(res, hold)
@@ -151,35 +450,74 @@ func GetHoldings(ctx *gin.Context, cfg *config.APIConfig, userId uuid.UUID) ([]h
 	return res, nil
 }
 
+type allocationRes struct {
+	Class      string          `json:"class"`
+	Value      decimal.Decimal `json:"value"`
+	Percentage decimal.Decimal `json:"percentage"`
+}
+
 type PortfolioRes struct {
-	TotalInvested     float64 `json:"total_invested"`
-	CurrentValue      float64 `json:"current_value"`
-	TotalProfitOrLoss float64 `json:"pnl"`
-	PNLPercentage     float64 `json:"pnl_percentage"`
-	HoldingsCount     int     `json:"holdings_count"`
+	TotalInvested     decimal.Decimal `json:"total_invested"`
+	CurrentValue      decimal.Decimal `json:"current_value"`
+	TotalProfitOrLoss decimal.Decimal `json:"pnl"`
+	PNLPercentage     decimal.Decimal `json:"pnl_percentage"`
+	HoldingsCount     int             `json:"holdings_count"`
+	ManualAssetsCount int             `json:"manual_assets_count"`
+	// Market holdings by asset class and manual assets by type, as a share of current value
+	Allocation []allocationRes `json:"allocation"`
 }
 
 func GetPortfolio(ctx *gin.Context, cfg *config.APIConfig, userId uuid.UUID) (PortfolioRes, error) {
-	// get the portfolio for the user
+	// get the portfolio for the user, no holdings is fine as long as there are manual assets
 	portfolio, err := cfg.DB.GetPortfolioForUser(ctx, userId)
-	if err != nil {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return PortfolioRes{}, err
 	}
+	noHoldings := err != nil
 
-	// Add the pnl and pnlpercentage
-	pnlPercentage := 0.0
-	pnl := portfolio.CurrentValue - portfolio.TotalInvested
-	if portfolio.TotalInvested > 0 {
-		pnlPercentage = (pnl / portfolio.TotalInvested) * 100
+	manualAssets, err := cfg.DB.GetManualAssetsForUser(ctx, userId)
+	if err != nil {
+		return PortfolioRes{}, err
+	}
+	if noHoldings && len(manualAssets) == 0 {
+		return PortfolioRes{}, sql.ErrNoRows
 	}
 
-	// respond with the portfolio
 	res := PortfolioRes{
 		TotalInvested:     portfolio.TotalInvested,
 		CurrentValue:      portfolio.CurrentValue,
-		TotalProfitOrLoss: pnl,
-		PNLPercentage:     pnlPercentage,
 		HoldingsCount:     int(portfolio.HoldingsCount),
+		ManualAssetsCount: len(manualAssets),
+	}
+
+	classValues, err := cfg.DB.GetMarketValueByAssetClass(ctx, userId)
+	if err != nil {
+		return PortfolioRes{}, err
+	}
+	for _, class := range classValues {
+		res.Allocation = append(res.Allocation, allocationRes{Class: class.AssetClass, Value: class.Value})
 	}
+
+	// Manual assets count at their accrued value
+	now := time.Now()
+	byType := make(map[string]decimal.Decimal)
+	for _, asset := range manualAssets {
+		value := manualAssetValue(asset, now)
+		res.TotalInvested = res.TotalInvested.Add(asset.CostBasis)
+		res.CurrentValue = res.CurrentValue.Add(value)
+		byType[asset.AssetType] = byType[asset.AssetType].Add(value)
+	}
+	for _, assetType := range manualAssetTypes {
+		if value, ok := byType[assetType]; ok {
+			res.Allocation = append(res.Allocation, allocationRes{Class: assetType, Value: value})
+		}
+	}
+	for i := range res.Allocation {
+		res.Allocation[i].Percentage = utils.Percentage(res.Allocation[i].Value, res.CurrentValue)
+	}
+
+	// Add the pnl and pnlpercentage
+	res.TotalProfitOrLoss = res.CurrentValue.Sub(res.TotalInvested)
+	res.PNLPercentage = utils.Percentage(res.TotalProfitOrLoss, res.TotalInvested.Abs())
 	return res, nil
 }
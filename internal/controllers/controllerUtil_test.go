@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMasterCovers(t *testing.T) {
+	tests := []struct {
+		symbol string
+		want   bool
+	}{
+		{"AAPL", true},
+		{"BRK-B", true},
+		{"BRK-A", true},
+		{"SPY", true},
+		{"TCS.NS", true},
+		{"M&M.NS", true},
+		{"500325.BO", true},
+		{"^NSEI", false},
+		{"^GSPC", false},
+		{"BTC-USD", false},
+		{"ETH-INR", false},
+		{"EURUSD=X", false},
+		{"GC=F", false},
+		{"VFIAX", false},
+		{"0P0000XVAA.BO", false},
+		{"VOD.L", false},
+		{"SHOP.TO", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.symbol, func(t *testing.T) {
+			if got := masterCovers(tt.symbol); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// A listed symbol the master doesn't have is unknown without asking Yahoo, however it was written
+func TestGetOrFetchStockListedMissingFromMaster(t *testing.T) {
+	conn := sql.OpenDB(emptyDriver{})
+	defer conn.Close()
+
+	// Nothing listens there, so every cache read misses
+	rd := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	defer rd.Close()
+	cfg := &config.APIConfig{Conn: conn, DB: database.New(conn), RD: rd}
+
+	for _, raw := range []string{"zzzz", "NSE:ZZZZ", "zzzz.nse", "ZZZZ.BOM", "NYSE:ZZZ.B"} {
+		t.Run(raw, func(t *testing.T) {
+			_, err := getOrFetchStock(context.Background(), cfg, raw)
+			if !errors.Is(err, ErrUnknownSymbol) || !strings.Contains(err.Error(), "instrument master") {
+				t.Errorf("got %v, want the instrument master's ErrUnknownSymbol", err)
+			}
+		})
+	}
+}
+
+// A database where every table is empty
+type emptyDriver struct{}
+
+func (emptyDriver) Open(string) (driver.Conn, error)             { return emptyConn{}, nil }
+func (emptyDriver) Connect(context.Context) (driver.Conn, error) { return emptyConn{}, nil }
+func (d emptyDriver) Driver() driver.Driver                      { return d }
+
+type emptyConn struct{}
+
+func (emptyConn) Prepare(string) (driver.Stmt, error) { return emptyStmt{}, nil }
+func (emptyConn) Close() error                        { return nil }
+func (emptyConn) Begin() (driver.Tx, error)           { return nil, errors.New("no transactions") }
+
+type emptyStmt struct{}
+
+func (emptyStmt) Close() error                               { return nil }
+func (emptyStmt) NumInput() int                              { return -1 }
+func (emptyStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(0), nil }
+func (emptyStmt) Query([]driver.Value) (driver.Rows, error)  { return emptyRows{}, nil }
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return nil }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/notify"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	DailyDigest  = "DAILY"
+	WeeklyDigest = "WEEKLY"
+
+	digestMoversCount = 3
+)
+
+type digestMover struct {
+	StockSymbol      string          `json:"stock_symbol"`
+	CompanyName      string          `json:"company_name"`
+	Quantity         decimal.Decimal `json:"quantity"`
+	Price            decimal.Decimal `json:"price"`
+	ReferencePrice   decimal.Decimal `json:"reference_price"`
+	Change           decimal.Decimal `json:"change"`
+	ChangePercentage decimal.Decimal `json:"change_percentage"`
+}
+
+type digestAlert struct {
+	Subject string    `json:"subject"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+type DigestRes struct {
+	Period                string                 `json:"period"`
+	PeriodStart           time.Time              `json:"period_start"`
+	PeriodEnd             time.Time              `json:"period_end"`
+	PortfolioValue        decimal.Decimal        `json:"portfolio_value"`
+	PreviousValue         decimal.Decimal        `json:"previous_value"`
+	ValueChange           decimal.Decimal        `json:"value_change"`
+	ValueChangePercentage decimal.Decimal        `json:"value_change_percentage"`
+	TopGainers            []digestMover          `json:"top_gainers"`
+	TopLosers             []digestMover          `json:"top_losers"`
+	Holdings              []digestMover          `json:"holdings"`
+	Transactions          []database.Transaction `json:"transactions"`
+	Alerts                []digestAlert          `json:"alerts"`
+}
+
+// BuildDigest values the current holdings against reference prices, previous close for a daily digest
+// and the prices stored in the last daily digest before the week started for a weekly one.
+func BuildDigest(ctx context.Context, cfg *config.APIConfig, userId uuid.UUID, period string, start, end time.Time) (DigestRes, error) {
+	holdings, err := GetHoldings(ctx, cfg, userId)
+	if err != nil {
+		return DigestRes{}, err
+	}
+
+	refPrices := make(map[string]decimal.Decimal)
+	if period == WeeklyDigest {
+		prev, err := cfg.DB.GetLatestDigestBefore(ctx, database.GetLatestDigestBeforeParams{
+			UserID:    userId,
+			Period:    DailyDigest,
+			PeriodEnd: start,
+		})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return DigestRes{}, err
+		}
+		var prevDigest DigestRes
+		if err == nil && json.Unmarshal(prev.Payload, &prevDigest) == nil {
+			for _, mover := range prevDigest.Holdings {
+				refPrices[mover.StockSymbol] = mover.Price
+			}
+		}
+	}
+
+	res := DigestRes{
+		Period:      period,
+		PeriodStart: start,
+		PeriodEnd:   end,
+	}
+	for _, holding := range holdings {
+		// holdings bought during the week fall back to previous close
+		ref, ok := refPrices[holding.StockSymbol]
+		if !ok {
+			ref = holding.PreviousClose
+		}
+
+		mover := digestMover{
+			StockSymbol:      holding.StockSymbol,
+			CompanyName:      holding.CompanyName,
+			Quantity:         holding.Quantity,
+			Price:            holding.CurrentPrice,
+			ReferencePrice:   ref,
+			Change:           utils.Amount(holding.Quantity, holding.CurrentPrice.Sub(ref)),
+			ChangePercentage: utils.Percentage(holding.CurrentPrice.Sub(ref), ref),
+		}
+
+		res.Holdings = append(res.Holdings, mover)
+		res.PortfolioValue = res.PortfolioValue.Add(holding.CurrentValue)
+		res.PreviousValue = res.PreviousValue.Add(utils.Amount(holding.Quantity, ref))
+	}
+	res.ValueChange = res.PortfolioValue.Sub(res.PreviousValue)
+	res.ValueChangePercentage = utils.Percentage(res.ValueChange, res.PreviousValue)
+
+	// Top gainers and losers by percentage move
+	movers := append([]digestMover(nil), res.Holdings...)
+	sort.Slice(movers, func(i, j int) bool {
+		return movers[i].ChangePercentage.GreaterThan(movers[j].ChangePercentage)
+	})
+	for _, mover := range movers {
+		if mover.ChangePercentage.Sign() <= 0 || len(res.TopGainers) == digestMoversCount {
+			break
+		}
+		res.TopGainers = append(res.TopGainers, mover)
+	}
+	for i := len(movers) - 1; i >= 0; i-- {
+		if movers[i].ChangePercentage.Sign() >= 0 || len(res.TopLosers) == digestMoversCount {
+			break
+		}
+		res.TopLosers = append(res.TopLosers, movers[i])
+	}
+
+	res.Transactions, err = cfg.DB.GetTransactionsForUserBetween(ctx, database.GetTransactionsForUserBetweenParams{
+		UserID:      userId,
+		CreatedAt:   start,
+		CreatedAt_2: end,
+	})
+	if err != nil {
+		return DigestRes{}, err
+	}
+
+	alerts, err := cfg.DB.GetNotificationsForUserBetween(ctx, database.GetNotificationsForUserBetweenParams{
+		UserID:   userId,
+		Kind:     notify.KindAlert,
+		SentAt:   start,
+		SentAt_2: end,
+	})
+	if err != nil {
+		return DigestRes{}, err
+	}
+	for _, alert := range alerts {
+		res.Alerts = append(res.Alerts, digestAlert{
+			Subject: alert.Subject,
+			SentAt:  alert.SentAt,
+		})
+	}
+
+	return res, nil
+}
+
+func GetLatestDigest(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Applying rate limiter to limit requesting digests
+		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "digests") {
+			respondWithError(ctx, http.StatusTooManyRequests, "Wait for some time!", nil)
+			return
+		}
+
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		period := strings.ToUpper(ctx.DefaultQuery("period", DailyDigest))
+		if period != DailyDigest && period != WeeklyDigest {
+			respondWithError(ctx, http.StatusBadRequest, "period must be daily or weekly", nil)
+			return
+		}
+
+		digest, err := cfg.DB.GetLatestDigestForUser(ctx, database.GetLatestDigestForUserParams{
+			UserID: userId,
+			Period: period,
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithError(ctx, 404, "No digest for this user yet", err)
+				return
+			}
+			respondWithError(ctx, 500, "error getting digest", err)
+			return
+		}
+
+		var res DigestRes
+		if err := json.Unmarshal(digest.Payload, &res); err != nil {
+			respondWithError(ctx, 500, "error reading digest", err)
+			return
+		}
+
+		ctx.JSON(200, res)
+	}
+}
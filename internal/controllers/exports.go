@@ -0,0 +1,358 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	exportPageSize = 500
+	ofxTimeLayout  = "20060102150405"
+)
+
+var exportFormats = []string{"csv", "json", "ofx"}
+
+// Column order is part of the format, new columns only ever go at the end.
+// The transaction columns are what the default import profile reads.
+var (
+	transactionExportColumns = []string{"id", "created_at", "stock_symbol", "type", "quantity", "price", "total_amount", "external_id"}
+	holdingExportColumns     = []string{"stock_symbol", "company_name", "asset_class", "quantity", "average_price", "total_invested", "curr_price", "curr_evaluation", "pnl", "pnl_percentage"}
+)
+
+// ExportTransactions streams the user's transactions oldest first, a page at a time
+func ExportTransactions(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Applying rate limiter, exports can be big
+		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "exports") {
+			respondWithError(ctx, http.StatusTooManyRequests, "Wait for some time!", nil)
+			return
+		}
+
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		format, ok := exportFormat(ctx)
+		if !ok {
+			return
+		}
+		// Optional ?from= and ?to= dates, both inclusive
+		from, to := time.Time{}, time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+		for param, day := range map[string]*time.Time{"from": &from, "to": &to} {
+			if ctx.Query(param) == "" {
+				continue
+			}
+			if *day, err = time.Parse(time.DateOnly, ctx.Query(param)); err != nil {
+				respondWithError(ctx, http.StatusBadRequest, "from and to must be YYYY-MM-DD", err)
+				return
+			}
+		}
+		if ctx.Query("to") != "" {
+			to = to.AddDate(0, 0, 1)
+		}
+		if !from.Before(to) {
+			respondWithError(ctx, http.StatusBadRequest, "from must not be after to", nil)
+			return
+		}
+
+		// Headers go out with the first page, errors after that can only cut the stream short
+		startExport(ctx, "transactions", format)
+		w := newExportWriter(ctx.Writer, format)
+		symbols := make(map[string]bool)
+		w.begin(transactionExportColumns, func() string {
+			return ofxHeader(userId, ctx.DefaultQuery("currency", "USD")) +
+				fmt.Sprintf("<INVTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>\n", from.Format(ofxTimeLayout), to.Format(ofxTimeLayout))
+		})
+
+		after, afterId := time.Time{}, uuid.Nil
+		for {
+			txns, err := cfg.DB.GetTransactionsForExport(ctx, database.GetTransactionsForExportParams{
+				UserID:         userId,
+				FromTime:       from,
+				ToTime:         to,
+				AfterCreatedAt: after,
+				AfterID:        afterId,
+				PageSize:       exportPageSize,
+			})
+			if err != nil {
+				log.Printf("Transactions export for %s failed: %v", userId, err)
+				return
+			}
+			for _, txn := range txns {
+				symbols[txn.StockSymbol] = true
+				w.write(txn, []string{
+					txn.ID.String(), txn.CreatedAt.Format(time.RFC3339Nano), txn.StockSymbol, txn.Type,
+					txn.Quantity.String(), txn.Price.String(), txn.TotalAmount.String(), txn.ExternalID,
+				}, ofxTransaction)
+			}
+			if err := w.flush(ctx.Writer); err != nil {
+				log.Printf("Transactions export for %s failed: %v", userId, err)
+				return
+			}
+			if len(txns) < exportPageSize {
+				break
+			}
+			after, afterId = txns[len(txns)-1].CreatedAt, txns[len(txns)-1].ID
+		}
+
+		w.end(func() string {
+			// The security list names every symbol traded in the range
+			var secs []ofxSecurity
+			for symbol := range symbols {
+				sec := ofxSecurity{Symbol: symbol, Name: symbol}
+				if stonk, err := cfg.DB.GetStockBySymbol(ctx, symbol); err == nil {
+					sec.Name = stonk.CompanyName
+				}
+				secs = append(secs, sec)
+			}
+			return "</INVTRANLIST>\n" + ofxFooter(secs)
+		})
+		if err := w.flush(ctx.Writer); err != nil {
+			log.Printf("Transactions export for %s failed: %v", userId, err)
+		}
+	}
+}
+
+// ExportHoldings writes the user's current holdings, a snapshot so there's no date range
+func ExportHoldings(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Applying rate limiter, exports can be big
+		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "exports") {
+			respondWithError(ctx, http.StatusTooManyRequests, "Wait for some time!", nil)
+			return
+		}
+
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		format, ok := exportFormat(ctx)
+		if !ok {
+			return
+		}
+
+		holdings, err := GetHoldings(ctx, cfg, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting holdings", err)
+			return
+		}
+
+		startExport(ctx, "holdings", format)
+		w := newExportWriter(ctx.Writer, format)
+		now := time.Now()
+		w.begin(holdingExportColumns, func() string {
+			return ofxHeader(userId, ctx.DefaultQuery("currency", "USD")) + "<INVPOSLIST>\n"
+		})
+		var secs []ofxSecurity
+		for _, holding := range holdings {
+			secs = append(secs, ofxSecurity{Symbol: holding.StockSymbol, Name: holding.CompanyName})
+			w.write(holding, []string{
+				holding.StockSymbol, holding.CompanyName, holding.AssetClass, holding.Quantity.String(),
+				holding.AveragePrice.String(), holding.TotalInvested.String(), holding.CurrentPrice.String(),
+				holding.CurrentValue.String(), holding.ProfitOrLoss.String(), holding.ProfitOrLossPercentage.String(),
+			}, func(w io.Writer, item any) error {
+				return ofxPosition(w, item.(holdingRes), now)
+			})
+		}
+		w.end(func() string {
+			return "</INVPOSLIST>\n" + ofxFooter(secs)
+		})
+		if err := w.flush(ctx.Writer); err != nil {
+			log.Printf("Holdings export for %s failed: %v", userId, err)
+		}
+	}
+}
+
+// Reads ?format=, csv by default, responding itself when it's unknown
+func exportFormat(ctx *gin.Context) (string, bool) {
+	format := strings.ToLower(ctx.DefaultQuery("format", "csv"))
+	if !slices.Contains(exportFormats, format) {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid format", fmt.Errorf("must be one of %s", strings.Join(exportFormats, "/")))
+		return "", false
+	}
+	return format, true
+}
+
+func startExport(ctx *gin.Context, name, format string) {
+	contentTypes := map[string]string{
+		"csv":  "text/csv; charset=utf-8",
+		"json": "application/json; charset=utf-8",
+		"ofx":  "application/x-ofx",
+	}
+	ctx.Header("Content-Type", contentTypes[format])
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, name, time.Now().Format(time.DateOnly), format))
+	ctx.Status(200)
+}
+
+// exportWriter writes the same records as CSV rows, JSON array items or OFX elements.
+// Write errors are kept and reported by flush so the loops stay simple.
+type exportWriter struct {
+	format string
+	w      io.Writer
+	csv    *csv.Writer
+	count  int
+	err    error
+}
+
+func newExportWriter(w io.Writer, format string) *exportWriter {
+	return &exportWriter{format: format, w: w, csv: csv.NewWriter(w)}
+}
+
+func (e *exportWriter) begin(columns []string, ofxStart func() string) {
+	switch e.format {
+	case "csv":
+		e.setErr(e.csv.Write(columns))
+	case "json":
+		e.print("[")
+	case "ofx":
+		e.print(ofxStart())
+	}
+}
+
+func (e *exportWriter) write(item any, record []string, ofx func(io.Writer, any) error) {
+	if e.err != nil {
+		return
+	}
+	switch e.format {
+	case "csv":
+		e.setErr(e.csv.Write(record))
+	case "json":
+		raw, err := json.Marshal(item)
+		if err != nil {
+			e.setErr(err)
+			return
+		}
+		if e.count > 0 {
+			e.print(",")
+		}
+		e.print("\n" + string(raw))
+	case "ofx":
+		e.setErr(ofx(e.w, item))
+	}
+	e.count++
+}
+
+func (e *exportWriter) end(ofxEnd func() string) {
+	switch e.format {
+	case "json":
+		e.print("\n]\n")
+	case "ofx":
+		e.print(ofxEnd())
+	}
+}
+
+// Pushes what's written so far to the client
+func (e *exportWriter) flush(w gin.ResponseWriter) error {
+	e.csv.Flush()
+	e.setErr(e.csv.Error())
+	if e.err == nil {
+		w.Flush()
+	}
+	return e.err
+}
+
+func (e *exportWriter) print(s string) {
+	if e.err == nil {
+		_, e.err = io.WriteString(e.w, s)
+	}
+}
+
+func (e *exportWriter) setErr(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+type ofxSecurity struct {
+	Symbol, Name string
+}
+
+// OFX 2.2 investment statement up to the transaction or position list
+func ofxHeader(userId uuid.UUID, currency string) string {
+	now := time.Now().Format(ofxTimeLayout)
+	return `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS><DTSERVER>` + now + `</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>
+<INVSTMTMSGSRSV1><INVSTMTTRNRS><TRNUID>0</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>
+<INVSTMTRS><DTASOF>` + now + `</DTASOF><CURDEF>` + ofxEscape(strings.ToUpper(currency)) + `</CURDEF>
+<INVACCTFROM><BROKERID>stock-portfolio-tracker</BROKERID><ACCTID>` + userId.String() + `</ACCTID></INVACCTFROM>
+`
+}
+
+func ofxFooter(secs []ofxSecurity) string {
+	var b strings.Builder
+	b.WriteString("</INVSTMTRS></INVSTMTTRNRS></INVSTMTMSGSRSV1>\n<SECLISTMSGSRSV1><SECLIST>\n")
+	for _, sec := range secs {
+		fmt.Fprintf(&b, "<STOCKINFO><SECINFO>%s<SECNAME>%s</SECNAME><TICKER>%s</TICKER></SECINFO></STOCKINFO>\n",
+			ofxSecID(sec.Symbol), ofxEscape(sec.Name), ofxEscape(sec.Symbol))
+	}
+	b.WriteString("</SECLIST></SECLISTMSGSRSV1>\n</OFX>\n")
+	return b.String()
+}
+
+// Buys and covers are BUYSTOCK, sells and shorts SELLSTOCK, with units and cash signed the OFX way
+func ofxTransaction(w io.Writer, item any) error {
+	txn := item.(database.Transaction)
+	inv := fmt.Sprintf("<INVTRAN><FITID>%s</FITID><DTTRADE>%s</DTTRADE></INVTRAN>%s",
+		txn.ID, txn.CreatedAt.Format(ofxTimeLayout), ofxSecID(txn.StockSymbol))
+
+	var err error
+	switch txn.Type {
+	case buy, buyToCover:
+		buyType := "BUY"
+		if txn.Type == buyToCover {
+			buyType = "BUYTOCOVER"
+		}
+		_, err = fmt.Fprintf(w, "<BUYSTOCK><INVBUY>%s<UNITS>%s</UNITS><UNITPRICE>%s</UNITPRICE><TOTAL>%s</TOTAL><SUBACCTSEC>CASH</SUBACCTSEC><SUBACCTFUND>CASH</SUBACCTFUND></INVBUY><BUYTYPE>%s</BUYTYPE></BUYSTOCK>\n",
+			inv, txn.Quantity, txn.Price, txn.TotalAmount.Neg(), buyType)
+	default:
+		sellType := "SELL"
+		if txn.Type == sellShort {
+			sellType = "SELLSHORT"
+		}
+		_, err = fmt.Fprintf(w, "<SELLSTOCK><INVSELL>%s<UNITS>%s</UNITS><UNITPRICE>%s</UNITPRICE><TOTAL>%s</TOTAL><SUBACCTSEC>CASH</SUBACCTSEC><SUBACCTFUND>CASH</SUBACCTFUND></INVSELL><SELLTYPE>%s</SELLTYPE></SELLSTOCK>\n",
+			inv, txn.Quantity.Neg(), txn.Price, txn.TotalAmount, sellType)
+	}
+	return err
+}
+
+func ofxPosition(w io.Writer, holding holdingRes, asOf time.Time) error {
+	posType := "LONG"
+	if holding.Quantity.Sign() < 0 {
+		posType = "SHORT"
+	}
+	_, err := fmt.Fprintf(w, "<POSSTOCK><INVPOS>%s<HELDINACCT>CASH</HELDINACCT><POSTYPE>%s</POSTYPE><UNITS>%s</UNITS><UNITPRICE>%s</UNITPRICE><MKTVAL>%s</MKTVAL><DTPRICEASOF>%s</DTPRICEASOF></INVPOS></POSSTOCK>\n",
+		ofxSecID(holding.StockSymbol), posType, holding.Quantity, holding.CurrentPrice, holding.CurrentValue, asOf.Format(ofxTimeLayout))
+	return err
+}
+
+func ofxSecID(symbol string) string {
+	return "<SECID><UNIQUEID>" + ofxEscape(symbol) + "</UNIQUEID><UNIQUEIDTYPE>TICKER</UNIQUEIDTYPE></SECID>"
+}
+
+func ofxEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -25,8 +25,13 @@ func HandleSSE(cfg *config.APIConfig) gin.HandlerFunc {
 			return
 		}
 
-		// Get userId from Authorization token
-		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		// Get userId and session from Authorization token
+		claims, err := auth.GetClaims(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authorization token error", err)
+			return
+		}
+		userId, err := claims.UserID()
 		if err != nil {
 			respondWithError(ctx, http.StatusUnauthorized, "Authorization token error", err)
 			return
@@ -48,9 +53,10 @@ func HandleSSE(cfg *config.APIConfig) gin.HandlerFunc {
 		}
 
 		client := &events.Client{
-			ID:      userId,
-			Send:    make(chan []byte, 1024),
-			Symbols: symbolSet,
+			ID:        userId,
+			SessionID: claims.SessionID,
+			Send:      make(chan []byte, 1024),
+			Symbols:   symbolSet,
 		}
 
 		events.HubInstance.Register <- client
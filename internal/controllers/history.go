@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	defaultHistoryLimit = 10
+	maxHistoryLimit     = 100
+)
+
+// A leading - sorts descending
+var historySorts = []string{"-created_at", "created_at", "-total_amount", "total_amount"}
+
+// historyFilter has the fields every transaction history query shares, null means no filter
+type historyFilter struct {
+	UserID      uuid.UUID
+	StockSymbol sql.NullString
+	Type        sql.NullString
+	FromTime    sql.NullTime
+	ToTime      sql.NullTime
+	MinAmount   decimal.NullDecimal
+	MaxAmount   decimal.NullDecimal
+}
+
+// The last row of a page, opaque to clients and only valid with the sort it came from
+type historyCursor struct {
+	Sort  string    `json:"s"`
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"id"`
+}
+
+// Reads ?symbol=, ?type=, ?from=, ?to= (inclusive dates), ?min_amount= and ?max_amount=,
+// responding itself when one is invalid
+func parseHistoryFilter(ctx *gin.Context, userId uuid.UUID) (historyFilter, bool) {
+	f := historyFilter{UserID: userId}
+	if ctx.Query("symbol") != "" {
+		symbol, err := utils.NormalizeSymbol(ctx.Query("symbol"))
+		if err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid symbol", err)
+			return historyFilter{}, false
+		}
+		f.StockSymbol = sql.NullString{String: symbol, Valid: true}
+	}
+	if txnType := strings.ToUpper(ctx.Query("type")); txnType != "" {
+		if !slices.Contains([]string{buy, sell, sellShort, buyToCover}, txnType) {
+			respondWithError(ctx, http.StatusBadRequest, "type must be BUY/SELL/SELL_SHORT/BUY_TO_COVER", nil)
+			return historyFilter{}, false
+		}
+		f.Type = sql.NullString{String: txnType, Valid: true}
+	}
+	for param, bound := range map[string]*sql.NullTime{"from": &f.FromTime, "to": &f.ToTime} {
+		if ctx.Query(param) == "" {
+			continue
+		}
+		day, err := time.Parse(time.DateOnly, ctx.Query(param))
+		if err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "from and to must be YYYY-MM-DD", err)
+			return historyFilter{}, false
+		}
+		*bound = sql.NullTime{Time: day, Valid: true}
+	}
+	if f.ToTime.Valid {
+		f.ToTime.Time = f.ToTime.Time.AddDate(0, 0, 1)
+	}
+	for param, bound := range map[string]*decimal.NullDecimal{"min_amount": &f.MinAmount, "max_amount": &f.MaxAmount} {
+		if ctx.Query(param) == "" {
+			continue
+		}
+		amount, err := decimal.NewFromString(ctx.Query(param))
+		if err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "min_amount and max_amount must be numbers", err)
+			return historyFilter{}, false
+		}
+		*bound = decimal.NullDecimal{Decimal: amount, Valid: true}
+	}
+	return f, true
+}
+
+// Runs the keyset query for the sort, each one has its own so it can walk the matching
+// (user_id, created_at, id) or (user_id, total_amount, id) index
+func listTransactions(ctx context.Context, q *database.Queries, f historyFilter, sort string, cursor *historyCursor, limit int32) ([]database.Transaction, error) {
+	var afterTime sql.NullTime
+	var afterAmount decimal.NullDecimal
+	var afterId uuid.NullUUID
+	if cursor != nil {
+		afterId = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+		if strings.HasSuffix(sort, "created_at") {
+			t, err := time.Parse(time.RFC3339Nano, cursor.Value)
+			if err != nil {
+				return nil, err
+			}
+			afterTime = sql.NullTime{Time: t, Valid: true}
+		} else {
+			amount, err := decimal.NewFromString(cursor.Value)
+			if err != nil {
+				return nil, err
+			}
+			afterAmount = decimal.NullDecimal{Decimal: amount, Valid: true}
+		}
+	}
+
+	switch sort {
+	case "created_at":
+		return q.ListTransactionsOldestFirst(ctx, database.ListTransactionsOldestFirstParams{
+			UserID: f.UserID, StockSymbol: f.StockSymbol, Type: f.Type, FromTime: f.FromTime, ToTime: f.ToTime,
+			MinAmount: f.MinAmount, MaxAmount: f.MaxAmount, AfterCreatedAt: afterTime, AfterID: afterId, PageSize: limit,
+		})
+	case "-total_amount":
+		return q.ListTransactionsLargestFirst(ctx, database.ListTransactionsLargestFirstParams{
+			UserID: f.UserID, StockSymbol: f.StockSymbol, Type: f.Type, FromTime: f.FromTime, ToTime: f.ToTime,
+			MinAmount: f.MinAmount, MaxAmount: f.MaxAmount, AfterAmount: afterAmount, AfterID: afterId, PageSize: limit,
+		})
+	case "total_amount":
+		return q.ListTransactionsSmallestFirst(ctx, database.ListTransactionsSmallestFirstParams{
+			UserID: f.UserID, StockSymbol: f.StockSymbol, Type: f.Type, FromTime: f.FromTime, ToTime: f.ToTime,
+			MinAmount: f.MinAmount, MaxAmount: f.MaxAmount, AfterAmount: afterAmount, AfterID: afterId, PageSize: limit,
+		})
+	default:
+		return q.ListTransactionsNewestFirst(ctx, database.ListTransactionsNewestFirstParams{
+			UserID: f.UserID, StockSymbol: f.StockSymbol, Type: f.Type, FromTime: f.FromTime, ToTime: f.ToTime,
+			MinAmount: f.MinAmount, MaxAmount: f.MaxAmount, AfterCreatedAt: afterTime, AfterID: afterId, PageSize: limit,
+		})
+	}
+}
+
+func encodeHistoryCursor(last database.Transaction, sort string) string {
+	c := historyCursor{Sort: sort, ID: last.ID, Value: last.TotalAmount.String()}
+	if strings.HasSuffix(sort, "created_at") {
+		c.Value = last.CreatedAt.Format(time.RFC3339Nano)
+	}
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeHistoryCursor(s, sort string) (*historyCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c historyCursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+	if c.Sort != sort {
+		return nil, fmt.Errorf("cursor is for sort=%s", c.Sort)
+	}
+	return &c, nil
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestHistoryCursorRoundTrip(t *testing.T) {
+	last := database.Transaction{
+		ID:          uuid.New(),
+		CreatedAt:   time.Date(2025, 3, 31, 23, 59, 59, 123456789, time.UTC),
+		TotalAmount: decimal.RequireFromString("1234.56789012"),
+	}
+
+	for _, sort := range historySorts {
+		t.Run(sort, func(t *testing.T) {
+			cursor, err := decodeHistoryCursor(encodeHistoryCursor(last, sort), sort)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cursor.Sort != sort || cursor.ID != last.ID {
+				t.Errorf("got sort %s id %s, want %s %s", cursor.Sort, cursor.ID, sort, last.ID)
+			}
+
+			// The value has to come back exactly or the next page skips or repeats rows
+			switch sort {
+			case "created_at", "-created_at":
+				got, err := time.Parse(time.RFC3339Nano, cursor.Value)
+				if err != nil || !got.Equal(last.CreatedAt) {
+					t.Errorf("created_at %s, %v, want %s", cursor.Value, err, last.CreatedAt)
+				}
+			default:
+				got, err := decimal.NewFromString(cursor.Value)
+				if err != nil || !got.Equal(last.TotalAmount) {
+					t.Errorf("total_amount %s, %v, want %s", cursor.Value, err, last.TotalAmount)
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryCursorRejects(t *testing.T) {
+	last := database.Transaction{ID: uuid.New(), CreatedAt: time.Now(), TotalAmount: decimal.NewFromInt(100)}
+
+	for _, from := range historySorts {
+		for _, to := range historySorts {
+			if from == to {
+				continue
+			}
+			if _, err := decodeHistoryCursor(encodeHistoryCursor(last, from), to); err == nil {
+				t.Errorf("cursor from sort=%s accepted for sort=%s", from, to)
+			}
+		}
+	}
+	for _, bad := range []string{"not a cursor!", "bm90IGpzb24", ""} {
+		if _, err := decodeHistoryCursor(bad, "-created_at"); err == nil {
+			t.Errorf("cursor %q accepted", bad)
+		}
+	}
+}
+
+func historyFilterFor(t *testing.T, query string) (historyFilter, int) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/api/transactions?"+query, nil)
+	f, ok := parseHistoryFilter(ctx, uuid.New())
+	if !ok {
+		return f, w.Code
+	}
+	return f, http.StatusOK
+}
+
+// Rows are kept when from <= created_at < to, so a to date has to end after its last moment
+func TestHistoryFilterDates(t *testing.T) {
+	f, code := historyFilterFor(t, "from=2025-03-01&to=2025-03-31")
+	if code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	inRange := func(createdAt time.Time) bool {
+		return !createdAt.Before(f.FromTime.Time) && createdAt.Before(f.ToTime.Time)
+	}
+
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		want      bool
+	}{
+		{"start of the from day", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), true},
+		{"just before the from day", time.Date(2025, 2, 28, 23, 59, 59, 999999999, time.UTC), false},
+		{"start of the to day", time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC), true},
+		{"end of the to day", time.Date(2025, 3, 31, 23, 59, 59, 999999999, time.UTC), true},
+		{"the day after", time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inRange(tt.createdAt); got != tt.want {
+				t.Errorf("%s in from=%s to=%s: %v, want %v", tt.createdAt, f.FromTime.Time, f.ToTime.Time, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryFilterSameDay(t *testing.T) {
+	f, code := historyFilterFor(t, "from=2025-03-31&to=2025-03-31")
+	if code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if !f.ToTime.Time.Equal(f.FromTime.Time.AddDate(0, 0, 1)) {
+		t.Errorf("from=to covers %s to %s, want the whole day", f.FromTime.Time, f.ToTime.Time)
+	}
+}
+
+func TestHistoryFilterRejects(t *testing.T) {
+	for _, query := range []string{
+		"to=31-03-2025",
+		"from=2025-02-30",
+		"to=2025-03-31T10:00:00Z",
+		"type=DIVIDEND",
+		"min_amount=lots",
+		"symbol=$$$",
+	} {
+		t.Run(query, func(t *testing.T) {
+			if _, code := historyFilterFor(t, query); code != http.StatusBadRequest {
+				t.Errorf("status %d, want 400", code)
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
@@ -20,6 +23,14 @@ func Holdings(cfg *config.APIConfig) gin.HandlerFunc {
 		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
 		if err != nil {
 			respondWithError(ctx, 401, "Authentication error", err)
+			return
+		}
+
+		// Optional ?asset_class= filter
+		assetClass := strings.ToUpper(ctx.Query("asset_class"))
+		if assetClass != "" && !slices.Contains(config.AssetClasses, assetClass) {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid asset_class", fmt.Errorf("must be one of %s", strings.Join(config.AssetClasses, "/")))
+			return
 		}
 
 		res, err := GetHoldings(ctx, cfg, userId)
@@ -27,6 +38,9 @@ func Holdings(cfg *config.APIConfig) gin.HandlerFunc {
 			respondWithError(ctx, 500, "holdings not found for this user", err)
 			return
 		}
+		if assetClass != "" {
+			res = slices.DeleteFunc(res, func(h holdingRes) bool { return h.AssetClass != assetClass })
+		}
 
 		// return holdings
 		ctx.JSON(200, res)
@@ -0,0 +1,572 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	maxImportSize = 5 << 20
+	maxImportRows = 5000
+
+	importOK        = "ok"
+	importDuplicate = "duplicate"
+	importError     = "error"
+)
+
+var (
+	// Returned from the import's DB transaction to roll it back after validating everything
+	errDryRun = errors.New("dry run")
+	// A row the transaction engine refused, the import stops there
+	errImportRejected = errors.New("import rejected")
+)
+
+// importMapping names the CSV columns holding each trade field, headers match ignoring case
+type importMapping struct {
+	Symbol   string `json:"symbol"`
+	Type     string `json:"type"`
+	Quantity string `json:"quantity"`
+	Price    string `json:"price"`
+	Date     string `json:"date"`
+	// Go time layout, empty tries RFC 3339, "2006-01-02 15:04:05" and "2006-01-02"
+	DateFormat string `json:"date_format,omitempty"`
+	// The broker's trade id, used to skip trades already imported
+	TradeID string `json:"trade_id,omitempty"`
+	// An exchange column whose values pick the symbol suffix, like NSE -> .NS
+	Exchange         string            `json:"exchange,omitempty"`
+	ExchangeSuffixes map[string]string `json:"exchange_suffixes,omitempty"`
+	// File values for the trade type, BUY/SELL/SELL_SHORT/BUY_TO_COVER are always understood
+	TypeValues map[string]string `json:"type_values,omitempty"`
+	Delimiter  string            `json:"delimiter,omitempty"`
+}
+
+var builtinImportProfiles = map[string]importMapping{
+	// Columns of the transactions CSV export, so exports import back
+	"default": {Symbol: "stock_symbol", Type: "type", Quantity: "quantity", Price: "price", Date: "created_at", TradeID: "id"},
+	// Zerodha Console tradebook
+	"zerodha": {
+		Symbol: "symbol", Type: "trade_type", Quantity: "quantity", Price: "price",
+		Date: "trade_date", DateFormat: time.DateOnly, TradeID: "trade_id",
+		Exchange: "exchange", ExchangeSuffixes: map[string]string{"NSE": ".NS", "BSE": ".BO"},
+	},
+	// Interactive Brokers Flex Query trades, sells have negative quantities
+	"ibkr": {
+		Symbol: "Symbol", Type: "Buy/Sell", Quantity: "Quantity", Price: "TradePrice",
+		Date: "TradeDate", DateFormat: "20060102", TradeID: "TradeID",
+	},
+}
+
+type importRow struct {
+	Line        int             `json:"line"`
+	StockSymbol string          `json:"stock_symbol"`
+	Type        string          `json:"type"`
+	Quantity    decimal.Decimal `json:"quantity"`
+	Price       decimal.Decimal `json:"price"`
+	TradedAt    time.Time       `json:"traded_at"`
+	TradeID     string          `json:"trade_id,omitempty"`
+	Status      string          `json:"status"`
+	Error       string          `json:"error,omitempty"`
+}
+
+type importSummary struct {
+	Rows       int `json:"rows"`
+	Valid      int `json:"valid"`
+	Duplicates int `json:"duplicates"`
+	Errors     int `json:"errors"`
+}
+
+type importRes struct {
+	Profile   string        `json:"profile"`
+	DryRun    bool          `json:"dry_run"`
+	Committed bool          `json:"committed"`
+	Summary   importSummary `json:"summary"`
+	Rows      []importRow   `json:"rows"`
+}
+
+// ImportTransactions replays a CSV tradebook through the transaction engine, all of it or none of it
+func ImportTransactions(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Applying rate limiter, each import can fetch a lot of stocks
+		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "imports") {
+			respondWithError(ctx, http.StatusTooManyRequests, "Wait for some time!", nil)
+			return
+		}
+
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+		if !checkEmailVerified(ctx, cfg, userId) {
+			return
+		}
+
+		// Parse the multipart form, file is required and the profile defaults to our own columns
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportSize)
+		fileHeader, err := ctx.FormFile("file")
+		if err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "A CSV file of at most 5MB is required", err)
+			return
+		}
+		dryRun := ctx.PostForm("dry_run") == "true"
+		profile := ctx.DefaultPostForm("profile", "default")
+		if ctx.PostForm("mapping") != "" {
+			profile = "custom"
+		}
+		mapping, ok := resolveImportMapping(ctx, cfg, userId, profile, ctx.PostForm("mapping"))
+		if !ok {
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Can't read the file", err)
+			return
+		}
+		defer file.Close()
+		rows, err := parseImportFile(file, mapping)
+		if err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid CSV", err)
+			return
+		}
+
+		// Resolve each symbol once, rows of unknown symbols fail
+		stocks := make(map[string]database.Stock)
+		symbolErrs := make(map[string]error)
+		for i := range rows {
+			row := &rows[i]
+			if row.Status != importOK {
+				continue
+			}
+			stonk, known := stocks[row.StockSymbol]
+			if !known && symbolErrs[row.StockSymbol] == nil {
+				stonk, err = getOrFetchStock(ctx, cfg, row.StockSymbol)
+				if err != nil {
+					symbolErrs[row.StockSymbol] = err
+				} else {
+					stocks[row.StockSymbol] = stonk
+					known = true
+				}
+			}
+			if !known {
+				row.fail("can't resolve %s: %v", row.StockSymbol, symbolErrs[row.StockSymbol])
+				continue
+			}
+			if err := checkQuantityPrecision(row.Quantity, stonk); err != nil {
+				row.fail("%v", err)
+			}
+		}
+		hasErrors := slices.ContainsFunc(rows, func(row importRow) bool { return row.Status == importError })
+
+		// Oldest first so holdings build up the way they did at the broker
+		var order []int
+		for i, row := range rows {
+			if row.Status == importOK {
+				order = append(order, i)
+			}
+		}
+		slices.SortStableFunc(order, func(a, b int) int { return rows[a].TradedAt.Compare(rows[b].TradedAt) })
+
+		// Every trade goes through the engine in one DB transaction, dry runs and files with errors roll back
+		seen := make(map[string]bool)
+		latest := make(map[string]sql.NullTime)
+		err = withTx(ctx, cfg, func(q *database.Queries) error {
+			for _, i := range order {
+				row := &rows[i]
+
+				// Twice in the file or already recorded
+				duplicate := seen[row.key()]
+				seen[row.key()] = true
+				if !duplicate {
+					exists, err := q.TransactionExists(ctx, database.TransactionExistsParams{
+						UserID:      userId,
+						ExternalID:  row.TradeID,
+						StockSymbol: row.StockSymbol,
+						Type:        row.Type,
+						Quantity:    row.Quantity,
+						Price:       row.Price,
+						CreatedAt:   row.TradedAt,
+					})
+					if err != nil {
+						return err
+					}
+					duplicate = exists
+				}
+				if duplicate {
+					row.Status = importDuplicate
+					continue
+				}
+
+				// The engine applies a trade to the holding as it is now, one from before trades
+				// already recorded would be averaged and sold against the wrong position
+				last, known := latest[row.StockSymbol]
+				if !known {
+					var err error
+					last, err = q.GetLatestTransactionTime(ctx, database.GetLatestTransactionTimeParams{
+						UserID:      userId,
+						StockSymbol: row.StockSymbol,
+					})
+					if err != nil {
+						return err
+					}
+					latest[row.StockSymbol] = last
+				}
+				if last.Valid && row.TradedAt.Before(last.Time) {
+					row.fail("dated before the latest %s trade already recorded (%s)", row.StockSymbol, last.Time.Format(time.RFC3339))
+					hasErrors = true
+					continue
+				}
+
+				_, err := executeTransaction(ctx, q, userId, row.StockSymbol, row.Type, row.Quantity, row.Price, tradeOrigin{
+					TradedAt:   sql.NullTime{Time: row.TradedAt, Valid: true},
+					ExternalID: row.TradeID,
+				})
+				if err != nil {
+					if IsTradeError(err) {
+						row.fail("%v, rows after this one weren't checked", err)
+						return errImportRejected
+					}
+					return err
+				}
+			}
+			if dryRun || hasErrors {
+				return errDryRun
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errDryRun) && !errors.Is(err, errImportRejected) {
+			respondWithError(ctx, 500, "error importing transactions", err)
+			return
+		}
+
+		res := importRes{Profile: profile, DryRun: dryRun, Committed: err == nil, Rows: rows}
+		res.Summary.Rows = len(rows)
+		for _, row := range rows {
+			switch row.Status {
+			case importOK:
+				res.Summary.Valid++
+			case importDuplicate:
+				res.Summary.Duplicates++
+			case importError:
+				res.Summary.Errors++
+			}
+		}
+
+		switch {
+		case res.Committed:
+			ctx.JSON(http.StatusCreated, res)
+		case dryRun:
+			ctx.JSON(200, res)
+		default:
+			ctx.JSON(http.StatusUnprocessableEntity, res)
+		}
+	}
+}
+
+func GetImportProfiles(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		saved, err := cfg.DB.GetImportProfilesForUser(ctx, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting import profiles", err)
+			return
+		}
+
+		ctx.JSON(200, gin.H{
+			"builtin": builtinImportProfiles,
+			"saved":   saved,
+		})
+	}
+}
+
+// SaveImportProfile creates or replaces the user's mapping under :name
+func SaveImportProfile(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		name := strings.ToLower(strings.TrimSpace(ctx.Param("name")))
+		if _, ok := builtinImportProfiles[name]; ok || name == "custom" {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid profile name", fmt.Errorf("%s is a built-in profile", name))
+			return
+		}
+
+		var mapping importMapping
+		if err := ctx.ShouldBindJSON(&mapping); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+		if err := mapping.validate(); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid mapping", err)
+			return
+		}
+		raw, err := json.Marshal(mapping)
+		if err != nil {
+			respondWithError(ctx, 500, "error encoding mapping", err)
+			return
+		}
+
+		saved, err := cfg.DB.UpsertImportProfile(ctx, database.UpsertImportProfileParams{
+			UserID:  userId,
+			Name:    name,
+			Mapping: raw,
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error saving import profile", err)
+			return
+		}
+		ctx.JSON(200, saved)
+	}
+}
+
+func DeleteImportProfile(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		deleted, err := cfg.DB.DeleteImportProfile(ctx, database.DeleteImportProfileParams{
+			UserID: userId,
+			Name:   strings.ToLower(strings.TrimSpace(ctx.Param("name"))),
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error deleting import profile", err)
+			return
+		}
+		if deleted == 0 {
+			respondWithError(ctx, 404, "No import profile with this name", nil)
+			return
+		}
+		ctx.JSON(200, gin.H{"message": "Import profile deleted"})
+	}
+}
+
+// An inline mapping wins over the profile name, then built-in profiles, then the user's saved ones.
+// Responds itself on failure.
+func resolveImportMapping(ctx *gin.Context, cfg *config.APIConfig, userId uuid.UUID, profile, inline string) (importMapping, bool) {
+	var mapping importMapping
+	if inline != "" {
+		if err := json.Unmarshal([]byte(inline), &mapping); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid mapping", err)
+			return importMapping{}, false
+		}
+	} else if builtin, ok := builtinImportProfiles[strings.ToLower(profile)]; ok {
+		mapping = builtin
+	} else {
+		saved, err := cfg.DB.GetImportProfile(ctx, database.GetImportProfileParams{
+			UserID: userId,
+			Name:   strings.ToLower(profile),
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithError(ctx, 404, "No import profile with this name", err)
+				return importMapping{}, false
+			}
+			respondWithError(ctx, 500, "error getting import profile", err)
+			return importMapping{}, false
+		}
+		if err := json.Unmarshal(saved.Mapping, &mapping); err != nil {
+			respondWithError(ctx, 500, "error decoding import profile", err)
+			return importMapping{}, false
+		}
+	}
+
+	if err := mapping.validate(); err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid mapping", err)
+		return importMapping{}, false
+	}
+	return mapping, true
+}
+
+func (m importMapping) validate() error {
+	if m.Symbol == "" || m.Type == "" || m.Quantity == "" || m.Price == "" || m.Date == "" {
+		return errors.New("symbol, type, quantity, price and date columns are required")
+	}
+	if utf8.RuneCountInString(m.Delimiter) > 1 {
+		return errors.New("delimiter must be a single character")
+	}
+	for _, txnType := range m.TypeValues {
+		if !slices.Contains([]string{buy, sell, sellShort, buyToCover}, strings.ToUpper(txnType)) {
+			return fmt.Errorf("type_values can only map to BUY/SELL/SELL_SHORT/BUY_TO_COVER, not %q", txnType)
+		}
+	}
+	return nil
+}
+
+// Reads the whole file into rows, a row that doesn't parse is marked failed rather than stopping the import
+func parseImportFile(r io.Reader, m importMapping) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if m.Delimiter != "" {
+		reader.Comma, _ = utf8.DecodeRuneInString(m.Delimiter)
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheet exports often start with a byte order mark
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	column := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		i, ok := columns[strings.ToLower(name)]
+		if !ok {
+			return 0, fmt.Errorf("no %q column in the header", name)
+		}
+		return i, nil
+	}
+	var cols [7]int
+	for i, name := range []string{m.Symbol, m.Type, m.Quantity, m.Price, m.Date, m.TradeID, m.Exchange} {
+		if cols[i], err = column(name); err != nil {
+			return nil, err
+		}
+	}
+
+	// Lookups ignore case
+	typeValues := make(map[string]string, len(m.TypeValues))
+	for from, to := range m.TypeValues {
+		typeValues[strings.ToUpper(from)] = strings.ToUpper(to)
+	}
+	suffixes := make(map[string]string, len(m.ExchangeSuffixes))
+	for exchange, suffix := range m.ExchangeSuffixes {
+		suffixes[strings.ToUpper(exchange)] = strings.ToUpper(suffix)
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("at most %d rows per import", maxImportRows)
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(col int) string {
+			if col < 0 || col >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[col])
+		}
+
+		row := importRow{Line: line, Status: importOK}
+		row.parse(m, field(cols[0]), field(cols[1]), field(cols[2]), field(cols[3]), field(cols[4]), typeValues)
+		row.TradeID = field(cols[5])
+		if suffix := suffixes[strings.ToUpper(field(cols[6]))]; suffix != "" && !strings.HasSuffix(row.StockSymbol, suffix) {
+			row.StockSymbol += suffix
+		}
+		if row.Status == importOK {
+			if symbol, err := utils.NormalizeSymbol(row.StockSymbol); err != nil {
+				row.fail("%v", err)
+			} else {
+				row.StockSymbol = symbol
+			}
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("no rows after the header")
+	}
+	return rows, nil
+}
+
+func (row *importRow) parse(m importMapping, symbol, txnType, quantity, price, date string, typeValues map[string]string) {
+	row.StockSymbol = strings.ToUpper(symbol)
+	if row.StockSymbol == "" {
+		row.fail("symbol is empty")
+		return
+	}
+
+	row.Type = strings.ToUpper(txnType)
+	if mapped, ok := typeValues[row.Type]; ok {
+		row.Type = mapped
+	}
+	if !slices.Contains([]string{buy, sell, sellShort, buyToCover}, row.Type) {
+		row.fail("unknown type %q", txnType)
+		return
+	}
+
+	// The type says the side, some brokers also sign the quantity
+	q, err := decimal.NewFromString(quantity)
+	if err != nil || q.IsZero() {
+		row.fail("quantity %q isn't a non-zero number", quantity)
+		return
+	}
+	row.Quantity = q.Abs()
+	p, err := decimal.NewFromString(price)
+	if err != nil || p.Sign() <= 0 {
+		row.fail("price %q isn't a positive number", price)
+		return
+	}
+	row.Price = p
+
+	layouts := []string{time.RFC3339, time.DateTime, time.DateOnly}
+	if m.DateFormat != "" {
+		layouts = []string{m.DateFormat}
+	}
+	for _, layout := range layouts {
+		if row.TradedAt, err = time.Parse(layout, date); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		row.fail("date %q doesn't match %s", date, strings.Join(layouts, " or "))
+		return
+	}
+	if row.TradedAt.After(time.Now()) {
+		row.fail("date %q is in the future", date)
+	}
+}
+
+func (row *importRow) fail(format string, args ...any) {
+	row.Status = importError
+	row.Error = fmt.Sprintf(format, args...)
+}
+
+// Rows with the same key are the same trade, by the broker's id when there is one
+func (row importRow) key() string {
+	if row.TradeID != "" {
+		return "id:" + row.TradeID
+	}
+	return fmt.Sprintf("%s|%s|%s|%s|%d", row.StockSymbol, row.Type, row.Quantity, row.Price, row.TradedAt.UnixNano())
+}
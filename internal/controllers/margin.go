@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrShortingDisabled      = errors.New("short selling isn't enabled for this account")
+	ErrInsufficientMargin    = errors.New("not enough collateral for the short exposure")
+	ErrMarginLimitsAdminOnly = errors.New("margin_requirement and max_short_value are set by an admin")
+
+	// Same as the column default, 150% of the short exposure in cash
+	defaultMarginRequirement = decimal.RequireFromString("1.5")
+)
+
+type marginRes struct {
+	database.MarginAccount
+	ShortExposure      decimal.Decimal `json:"short_exposure"`
+	Collateral         decimal.Decimal `json:"collateral"`
+	RequiredCollateral decimal.Decimal `json:"required_collateral"`
+	MarginCall         bool            `json:"margin_call"`
+}
+
+func GetMargin(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		account, err := getMarginAccount(ctx, cfg.DB, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting margin account", err)
+			return
+		}
+		res, err := buildMarginRes(ctx, cfg, account)
+		if err != nil {
+			respondWithError(ctx, 500, "error computing margin", err)
+			return
+		}
+
+		ctx.JSON(200, res)
+	}
+}
+
+// UpdateMargin opts the user in or out of short selling. The limits are an admin's to set,
+// see AdminSetUserMargin.
+func UpdateMargin(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		// Parse request, the limit fields are only read to turn them away
+		var req struct {
+			ShortSellingEnabled *bool            `json:"short_selling_enabled"`
+			MarginRequirement   *decimal.Decimal `json:"margin_requirement"`
+			MaxShortValue       *decimal.Decimal `json:"max_short_value"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+		if req.MarginRequirement != nil || req.MaxShortValue != nil {
+			respondWithError(ctx, http.StatusForbidden, "Only an admin can change margin limits", ErrMarginLimitsAdminOnly)
+			return
+		}
+		if req.ShortSellingEnabled == nil {
+			respondWithError(ctx, http.StatusBadRequest, "short_selling_enabled is required", nil)
+			return
+		}
+
+		account, err := cfg.DB.SetShortSellingEnabled(ctx, database.SetShortSellingEnabledParams{
+			UserID:              userId,
+			ShortSellingEnabled: *req.ShortSellingEnabled,
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error updating margin account", err)
+			return
+		}
+		res, err := buildMarginRes(ctx, cfg, account)
+		if err != nil {
+			respondWithError(ctx, 500, "error computing margin", err)
+			return
+		}
+
+		ctx.JSON(200, res)
+	}
+}
+
+// Users without a row have short selling disabled at the default requirement
+func getMarginAccount(ctx context.Context, q *database.Queries, userId uuid.UUID) (database.MarginAccount, error) {
+	account, err := q.GetMarginAccount(ctx, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.MarginAccount{UserID: userId, MarginRequirement: defaultMarginRequirement}, nil
+	}
+	return account, err
+}
+
+func buildMarginRes(ctx context.Context, cfg *config.APIConfig, account database.MarginAccount) (marginRes, error) {
+	exposure, err := cfg.DB.GetShortExposure(ctx, account.UserID)
+	if err != nil {
+		return marginRes{}, err
+	}
+	collateral, err := cfg.DB.GetCashBalance(ctx, account.UserID)
+	if err != nil {
+		return marginRes{}, err
+	}
+	required := exposure.Mul(account.MarginRequirement)
+	return marginRes{
+		MarginAccount:      account,
+		ShortExposure:      exposure,
+		Collateral:         collateral,
+		RequiredCollateral: required,
+		MarginCall:         collateral.LessThan(required),
+	}, nil
+}
+
+// Cash that has to stay in the account to back the user's short positions
+func requiredCollateral(ctx context.Context, q *database.Queries, userId uuid.UUID) (decimal.Decimal, error) {
+	account, err := getMarginAccount(ctx, q, userId)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	exposure, err := q.GetShortExposure(ctx, userId)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return exposure.Mul(account.MarginRequirement), nil
+}
+
+// A new short is capped by max_short_value and the cash, its proceeds included,
+// has to cover margin_requirement times the whole short exposure
+func checkShortMargin(ctx context.Context, q *database.Queries, userId uuid.UUID, proceeds decimal.Decimal) error {
+	account, err := getMarginAccount(ctx, q, userId)
+	if err != nil {
+		return err
+	}
+	if !account.ShortSellingEnabled {
+		return ErrShortingDisabled
+	}
+
+	exposure, err := q.GetShortExposure(ctx, userId)
+	if err != nil {
+		return err
+	}
+	exposure = exposure.Add(proceeds)
+	if account.MaxShortValue.Valid && exposure.GreaterThan(account.MaxShortValue.Decimal) {
+		return fmt.Errorf("%w: exposure of %s is over the %s limit", ErrInsufficientMargin,
+			exposure.StringFixed(2), account.MaxShortValue.Decimal.StringFixed(2))
+	}
+
+	cash, err := q.GetCashBalance(ctx, userId)
+	if err != nil {
+		return err
+	}
+	collateral := cash.Add(proceeds)
+	required := exposure.Mul(account.MarginRequirement)
+	if collateral.LessThan(required) {
+		return fmt.Errorf("%w: needs %s, has %s", ErrInsufficientMargin, required.StringFixed(2), collateral.StringFixed(2))
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/notify"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type notificationPreferenceRes struct {
+	Channel string `json:"channel"`
+	Kind    string `json:"kind"`
+	Enabled bool   `json:"enabled"`
+}
+
+func GetNotificationPreferences(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		res, err := getNotificationPreferences(ctx, cfg, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting notification preferences", err)
+			return
+		}
+
+		ctx.JSON(200, res)
+	}
+}
+
+func UpdateNotificationPreferences(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		// Parse request, only the listed preferences are changed
+		var req []notificationPreferenceRes
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+		for _, pref := range req {
+			if !slices.Contains(notify.Channels, pref.Channel) || !slices.Contains(notify.Kinds, pref.Kind) {
+				respondWithError(ctx, http.StatusBadRequest, "Unknown notification channel or kind", nil)
+				return
+			}
+		}
+
+		for _, pref := range req {
+			_, err := cfg.DB.UpsertNotificationPreference(ctx, database.UpsertNotificationPreferenceParams{
+				UserID:  userId,
+				Channel: pref.Channel,
+				Kind:    pref.Kind,
+				Enabled: pref.Enabled,
+			})
+			if err != nil {
+				respondWithError(ctx, 500, "error updating notification preferences", err)
+				return
+			}
+		}
+
+		res, err := getNotificationPreferences(ctx, cfg, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting notification preferences", err)
+			return
+		}
+
+		ctx.JSON(200, res)
+	}
+}
+
+// returns every channel/kind combination, defaulting to enabled when the user never set it
+func getNotificationPreferences(ctx *gin.Context, cfg *config.APIConfig, userId uuid.UUID) ([]notificationPreferenceRes, error) {
+	prefs, err := cfg.DB.GetNotificationPreferencesForUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]bool)
+	for _, pref := range prefs {
+		set[pref.Channel+":"+pref.Kind] = pref.Enabled
+	}
+
+	var res []notificationPreferenceRes
+	for _, channel := range notify.Channels {
+		for _, kind := range notify.Kinds {
+			enabled, ok := set[channel+":"+kind]
+			res = append(res, notificationPreferenceRes{
+				Channel: channel,
+				Kind:    kind,
+				Enabled: enabled || !ok,
+			})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/notify"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/oidc"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// How long a user has to log in at the provider before the callback is refused
+const oidcLoginTTL = 10 * time.Minute
+
+var (
+	errSSONotConfigured   = errors.New("OIDC_ISSUER not set")
+	errSSOEmailUnverified = errors.New("identity provider didn't verify the email")
+)
+
+func oidcStateKey(state string) string {
+	return "oidc-state:" + auth.HashToken(state)
+}
+
+// StartOIDCLogin gives the URL to send the browser to for logging in at the identity provider.
+// The PKCE verifier and nonce stay here, in redis under the state, until the callback.
+func StartOIDCLogin(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if cfg.OIDC == nil {
+			respondWithError(ctx, http.StatusNotFound, "Single sign-on isn't set up", errSSONotConfigured)
+			return
+		}
+		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "oidc") {
+			respondWithError(ctx, http.StatusTooManyRequests, "Login Quota Expired", nil)
+			return
+		}
+
+		authReq, err := oidc.NewAuthRequest()
+		if err != nil {
+			respondWithError(ctx, 500, "error starting login", err)
+			return
+		}
+		authURL, err := cfg.OIDC.AuthCodeURL(ctx, authReq)
+		if err != nil {
+			respondWithError(ctx, http.StatusBadGateway, "Identity provider unavailable", err)
+			return
+		}
+		stored, err := json.Marshal(authReq)
+		if err != nil {
+			respondWithError(ctx, 500, "error starting login", err)
+			return
+		}
+		if err := cfg.RD.Set(ctx, oidcStateKey(authReq.State), stored, oidcLoginTTL).Err(); err != nil {
+			respondWithError(ctx, 500, "error starting login", err)
+			return
+		}
+
+		ctx.JSON(200, gin.H{
+			"authorization_url": authURL,
+			"state":             authReq.State,
+			"expires_in":        int(oidcLoginTTL.Seconds()),
+		})
+	}
+}
+
+// OIDCCallback finishes the login with the code and state the provider sent the browser back
+// with, as query params on GET or a JSON body on POST. It responds like LoginUser.
+func OIDCCallback(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if cfg.OIDC == nil {
+			respondWithError(ctx, http.StatusNotFound, "Single sign-on isn't set up", errSSONotConfigured)
+			return
+		}
+		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "login") {
+			respondWithError(ctx, http.StatusTooManyRequests, "Login Quota Expired", nil)
+			return
+		}
+
+		req := struct {
+			Code             string `form:"code" json:"code"`
+			State            string `form:"state" json:"state"`
+			Error            string `form:"error" json:"error"`
+			ErrorDescription string `form:"error_description" json:"error_description"`
+		}{}
+		if err := ctx.ShouldBind(&req); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid request", err)
+			return
+		}
+		if req.Error != "" {
+			respondWithError(ctx, http.StatusUnauthorized, "Login at the identity provider failed", errors.New(strings.TrimSpace(req.Error+" "+req.ErrorDescription)))
+			return
+		}
+		if req.Code == "" || req.State == "" {
+			respondWithError(ctx, http.StatusBadRequest, "code and state are required", nil)
+			return
+		}
+
+		// Each state works once, so a code can't be replayed through it
+		stored, err := cfg.RD.GetDel(ctx, oidcStateKey(req.State)).Bytes()
+		if errors.Is(err, redis.Nil) {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid or expired login, start again", nil)
+			return
+		}
+		if err != nil {
+			respondWithError(ctx, 500, "error getting login state", err)
+			return
+		}
+		var authReq oidc.AuthRequest
+		if err := json.Unmarshal(stored, &authReq); err != nil {
+			respondWithError(ctx, 500, "error reading login state", err)
+			return
+		}
+
+		identity, err := cfg.OIDC.Exchange(ctx, req.Code, authReq)
+		if errors.Is(err, oidc.ErrProviderUnavailable) {
+			respondWithError(ctx, http.StatusBadGateway, "Identity provider unavailable", err)
+			return
+		}
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Login at the identity provider failed", err)
+			return
+		}
+
+		user, err := oidcUser(ctx, cfg, identity)
+		if errors.Is(err, errSSOEmailUnverified) {
+			respondWithError(ctx, http.StatusForbidden, "The identity provider hasn't verified your email", err)
+			return
+		}
+		if errors.Is(err, errAccountDisabled) {
+			respondWithError(ctx, http.StatusForbidden, "Account is disabled", err)
+			return
+		}
+		if err != nil {
+			respondWithError(ctx, 500, "error getting user", err)
+			return
+		}
+
+		// Same as a password login from here, two-factor included
+		twoFactor, err := twoFactorEnabled(ctx, cfg, user.ID)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting two-factor status", err)
+			return
+		}
+		if twoFactor {
+			challenge, err := startTwoFactorChallenge(ctx, cfg, user.ID)
+			if err != nil {
+				respondWithError(ctx, 500, "error starting two-factor challenge", err)
+				return
+			}
+			ctx.JSON(200, challenge)
+			return
+		}
+
+		completeLogin(ctx, cfg, user)
+	}
+}
+
+// Finds the user an identity belongs to. The first login links it to the account with the same
+// email, or makes a new account, but only when the provider has verified the email.
+func oidcUser(ctx *gin.Context, cfg *config.APIConfig, identity oidc.Identity) (database.User, error) {
+	identity.Email = utils.NormalizeEmail(identity.Email)
+	linked, err := cfg.DB.GetUserIdentity(ctx, database.GetUserIdentityParams{
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+	})
+	if err == nil {
+		if err := cfg.DB.TouchUserIdentity(ctx, database.TouchUserIdentityParams{ID: linked.ID, Email: identity.Email}); err != nil {
+			log.Printf("Error updating identity last login: %v\n", err)
+		}
+		return cfg.DB.GetUserByID(ctx, linked.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	// Linking on an email the provider hasn't checked would hand the account to whoever typed it
+	if !identity.EmailVerified || utils.ValidateEmail(identity.Email) != nil {
+		return database.User{}, errSSOEmailUnverified
+	}
+	user, err := cfg.DB.GetUserByEmail(ctx, identity.Email)
+	isNew := errors.Is(err, sql.ErrNoRows)
+	if err != nil && !isNew {
+		return database.User{}, err
+	}
+	if user.DisabledAt.Valid {
+		return database.User{}, errAccountDisabled
+	}
+
+	// Whoever signed up with an address they never verified may not own it. Linking keeps none
+	// of what they could have set up: the password, sessions, API keys and two-factor all go.
+	takeover := !isNew && !user.VerifiedAt.Valid
+
+	// New accounts get a password nobody knows, a password reset sets a real one
+	var hashedPass string
+	if isNew || takeover {
+		password, err := auth.MakeToken()
+		if err != nil {
+			return database.User{}, err
+		}
+		if hashedPass, err = auth.HashPassword(password); err != nil {
+			return database.User{}, err
+		}
+	}
+	var ended []uuid.UUID
+	err = withTx(ctx, cfg, func(q *database.Queries) error {
+		if isNew {
+			name := identity.Name
+			if name == "" {
+				name = identity.Email[:strings.Index(identity.Email, "@")]
+			}
+			user, err = q.CreateUser(ctx, database.CreateUserParams{
+				Email:          identity.Email,
+				Name:           name,
+				HashedPassword: hashedPass,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if takeover {
+			if err := updatePassword(ctx, q, user.ID, hashedPass); err != nil {
+				return err
+			}
+			if ended, err = auth.EndSessions(ctx, q, user.ID, uuid.Nil); err != nil {
+				return err
+			}
+			if err := q.RevokeAllAPIKeys(ctx, user.ID); err != nil {
+				return err
+			}
+			if err := q.DeleteTOTP(ctx, user.ID); err != nil {
+				return err
+			}
+			if err := q.DeleteRecoveryCodes(ctx, user.ID); err != nil {
+				return err
+			}
+		}
+		if _, err := q.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+			UserID:  user.ID,
+			Issuer:  identity.Issuer,
+			Subject: identity.Subject,
+			Email:   identity.Email,
+		}); err != nil {
+			return err
+		}
+		// The provider checked the address, so it's theirs
+		return q.MarkUserVerified(ctx, user.ID)
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	for _, sessionId := range ended {
+		endSessionStream(sessionId)
+	}
+	if !user.VerifiedAt.Valid {
+		user.VerifiedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+
+	if !isNew {
+		err = notify.Enqueue(ctx, cfg.RD, notify.Message{
+			UserID:   user.ID,
+			Kind:     notify.KindSecurity,
+			Template: "identity_linked",
+			Subject:  "Single sign-on was linked to your account",
+			Data: map[string]any{
+				"Issuer":    identity.Issuer,
+				"Time":      time.Now().UTC().Format(time.RFC1123),
+				"IP":        ctx.ClientIP(),
+				"UserAgent": ctx.Request.UserAgent(),
+			},
+		})
+		if err != nil {
+			log.Printf("Error queueing identity linked notification: %v\n", err)
+		}
+	}
+	return user, nil
+}
@@ -0,0 +1,419 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/notify"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	limitOrder     = "LIMIT"
+	stopOrder      = "STOP"
+	stopLimitOrder = "STOP_LIMIT"
+
+	goodTillCancelled = "GTC"
+	dayOrder          = "DAY"
+
+	orderOpen      = "OPEN"
+	orderTriggered = "TRIGGERED"
+)
+
+// When each market's regular session ends, on the exchange's own clock
+var marketCloses = map[string]struct {
+	loc          *time.Location
+	hour, minute int
+}{
+	"IN": {mustLoadLocation("Asia/Kolkata"), 15, 30},
+	"US": {mustLoadLocation("America/New_York"), 16, 0},
+}
+
+// When each market's regular session starts, on the same clock as marketCloses
+var marketOpens = map[string]struct{ hour, minute int }{
+	"IN": {9, 15},
+	"US": {9, 30},
+}
+
+// Exchanges in the instrument master and the market they belong to
+var exchangeMarkets = map[string]string{
+	"NSE":          "IN",
+	"BSE":          "IN",
+	"NASDAQ":       "US",
+	"NYSE":         "US",
+	"NYSEAMERICAN": "US",
+	"NYSEARCA":     "US",
+	"BATS":         "US",
+}
+
+// time/tzdata is built in, so a zone that doesn't load is a typo here
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+func CreateOrder(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Applying rate limiter to limit placing orders
+		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "orders") {
+			respondWithError(ctx, http.StatusTooManyRequests, "Wait for some time!", nil)
+			return
+		}
+
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+		if !checkEmailVerified(ctx, cfg, userId) {
+			return
+		}
+
+		// Parse request
+		var req struct {
+			StockSymbol string           `json:"stock_symbol"`
+			Side        string           `json:"side"`
+			OrderType   string           `json:"order_type"`
+			Quantity    decimal.Decimal  `json:"quantity"`
+			LimitPrice  *decimal.Decimal `json:"limit_price"`
+			StopPrice   *decimal.Decimal `json:"stop_price"`
+			TimeInForce string           `json:"time_in_force"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+		if req.TimeInForce == "" {
+			req.TimeInForce = goodTillCancelled
+		}
+		if err := validateOrder(req.Side, req.OrderType, req.TimeInForce, req.Quantity, req.LimitPrice, req.StopPrice); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid order", err)
+			return
+		}
+
+		// Resolving the stock also makes sure it exists in DB for the FK
+		stonk, err := getOrFetchStock(ctx, cfg, req.StockSymbol)
+		if err != nil {
+			respondWithStockError(ctx, err)
+			return
+		}
+		if err := checkQuantityPrecision(req.Quantity, stonk); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid order", err)
+			return
+		}
+
+		// Sell orders need the holding now, it's checked again when the order fills
+		if req.Side == sell {
+			holding, err := cfg.DB.GetHoldingByStockSymbol(ctx, database.GetHoldingByStockSymbolParams{
+				UserID:      userId,
+				StockSymbol: stonk.Symbol,
+			})
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					respondWithError(ctx, http.StatusBadRequest, "Invalid order", ErrStockNotOwned)
+					return
+				}
+				respondWithError(ctx, http.StatusInternalServerError, "Error fetching holdings", err)
+				return
+			}
+			if holding.Quantity.LessThan(req.Quantity) {
+				respondWithError(ctx, http.StatusBadRequest, "Invalid order", ErrInsufficientHolding)
+				return
+			}
+		}
+
+		var expiresAt sql.NullTime
+		if req.TimeInForce == dayOrder {
+			expiry, err := dayOrderExpiry(ctx, cfg, stonk, time.Now())
+			if err != nil {
+				respondWithError(ctx, http.StatusInternalServerError, "Failed to work out the order's expiry", err)
+				return
+			}
+			expiresAt = sql.NullTime{Time: expiry, Valid: true}
+		}
+
+		order, err := cfg.DB.CreateOrder(ctx, database.CreateOrderParams{
+			UserID:      userId,
+			StockSymbol: stonk.Symbol,
+			Side:        req.Side,
+			OrderType:   req.OrderType,
+			Quantity:    req.Quantity,
+			LimitPrice:  toNullDecimal(req.LimitPrice),
+			StopPrice:   toNullDecimal(req.StopPrice),
+			TimeInForce: req.TimeInForce,
+			ExpiresAt:   expiresAt,
+		})
+		if err != nil {
+			respondWithError(ctx, http.StatusInternalServerError, "Failed to create order", err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, order)
+	}
+}
+
+func GetOrders(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		// Optional status filter
+		var orders []database.Order
+		if status := strings.ToUpper(ctx.Query("status")); status != "" {
+			orders, err = cfg.DB.GetOrdersForUserByStatus(ctx, database.GetOrdersForUserByStatusParams{
+				UserID: userId,
+				Status: status,
+			})
+		} else {
+			orders, err = cfg.DB.GetOrdersForUser(ctx, userId)
+		}
+		if err != nil {
+			respondWithError(ctx, 500, "error getting orders", err)
+			return
+		}
+
+		ctx.JSON(200, orders)
+	}
+}
+
+func CancelOrder(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		orderId, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid order id", err)
+			return
+		}
+
+		order, err := cfg.DB.CancelOrder(ctx, database.CancelOrderParams{
+			ID:     orderId,
+			UserID: userId,
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithError(ctx, 404, "No open order with this id", err)
+				return
+			}
+			respondWithError(ctx, 500, "error cancelling order", err)
+			return
+		}
+
+		ctx.JSON(200, order)
+	}
+}
+
+// MatchOrders runs every open order for the symbol against a new price,
+// filled orders become a transaction and holding update in one DB transaction.
+func MatchOrders(ctx context.Context, cfg *config.APIConfig, symbol string, price decimal.Decimal) {
+	// Orders past their expiry are left out, the OrderExpirer marks them EXPIRED
+	orders, err := cfg.DB.GetOpenOrdersForSymbol(ctx, symbol)
+	if err != nil {
+		log.Printf("Error getting open orders for %s: %v\n", symbol, err)
+		return
+	}
+
+	for _, order := range orders {
+		// Stop orders trigger first, STOP becomes a market order and STOP_LIMIT a limit order
+		if order.Status == orderOpen && order.OrderType != limitOrder {
+			if !stopReached(order.Side, order.StopPrice.Decimal, price) {
+				continue
+			}
+			if order.OrderType == stopLimitOrder {
+				if err := cfg.DB.TriggerOrder(ctx, order.ID); err != nil {
+					log.Printf("Error triggering order %s: %v\n", order.ID, err)
+					continue
+				}
+			}
+		}
+		if order.OrderType != stopOrder && !limitReached(order.Side, order.LimitPrice.Decimal, price) {
+			continue
+		}
+
+		fillOrder(ctx, cfg, order, price)
+	}
+}
+
+func fillOrder(ctx context.Context, cfg *config.APIConfig, order database.Order, price decimal.Decimal) {
+	var (
+		result TradeResult
+		filled database.Order
+	)
+	err := withTx(ctx, cfg, func(q *database.Queries) error {
+		var err error
+		result, err = ExecuteTransaction(ctx, q, order.UserID, order.StockSymbol, order.Side, order.Quantity, price)
+		if err != nil {
+			return err
+		}
+		// No rows here means the order got cancelled in the meantime so the trade is rolled back
+		filled, err = q.FillOrder(ctx, database.FillOrderParams{
+			ID:            order.ID,
+			TransactionID: uuid.NullUUID{UUID: result.Transaction.ID, Valid: true},
+		})
+		return err
+	})
+	if err != nil {
+		if IsTradeError(err) {
+			if err := cfg.DB.RejectOrder(ctx, order.ID); err != nil {
+				log.Printf("Error rejecting order %s: %v\n", order.ID, err)
+			}
+			order.Status = "REJECTED"
+			enqueueOrderNotification(ctx, cfg, order, fmt.Sprintf("Order rejected: %s %s %s", order.Side, order.Quantity, order.StockSymbol), err.Error())
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Error filling order %s: %v\n", order.ID, err)
+		}
+		return
+	}
+
+	enqueueOrderNotification(ctx, cfg, filled,
+		fmt.Sprintf("Order filled: %s %s %s", filled.Side, filled.Quantity, filled.StockSymbol),
+		fmt.Sprintf("Your %s %s order for %s %s was filled at %s (total %s).",
+			filled.OrderType, filled.Side, filled.Quantity, filled.StockSymbol, price.StringFixed(2), result.Transaction.TotalAmount.StringFixed(2)),
+	)
+}
+
+// Fill events go through the notification queue, IN_APP delivers them on the user's SSE stream
+func enqueueOrderNotification(ctx context.Context, cfg *config.APIConfig, order database.Order, title, body string) {
+	err := notify.Enqueue(ctx, cfg.RD, notify.Message{
+		UserID:   order.UserID,
+		Kind:     notify.KindAlert,
+		Template: "order_update",
+		Subject:  title,
+		Data: map[string]any{
+			"Title":   title,
+			"Body":    body,
+			"OrderID": order.ID,
+			"Status":  order.Status,
+		},
+	})
+	if err != nil {
+		log.Printf("Error queueing order notification: %v\n", err)
+	}
+}
+
+func validateOrder(side, orderType, timeInForce string, quantity decimal.Decimal, limitPrice, stopPrice *decimal.Decimal) error {
+	if side != buy && side != sell {
+		return errors.New("side must be BUY/SELL")
+	}
+	if quantity.Sign() <= 0 {
+		return errors.New("quantity must be > 0")
+	}
+	if timeInForce != goodTillCancelled && timeInForce != dayOrder {
+		return errors.New("time_in_force must be GTC/DAY")
+	}
+
+	hasLimit := limitPrice != nil && limitPrice.Sign() > 0
+	hasStop := stopPrice != nil && stopPrice.Sign() > 0
+	switch orderType {
+	case limitOrder:
+		if !hasLimit || stopPrice != nil {
+			return errors.New("LIMIT orders need only a limit_price > 0")
+		}
+	case stopOrder:
+		if !hasStop || limitPrice != nil {
+			return errors.New("STOP orders need only a stop_price > 0")
+		}
+	case stopLimitOrder:
+		if !hasLimit || !hasStop {
+			return errors.New("STOP_LIMIT orders need a limit_price and stop_price > 0")
+		}
+	default:
+		return errors.New("order_type must be LIMIT/STOP/STOP_LIMIT")
+	}
+	return nil
+}
+
+// Buy stops trigger on the way up, sell stops (stop-loss) on the way down
+func stopReached(side string, stopPrice, price decimal.Decimal) bool {
+	if side == buy {
+		return price.GreaterThanOrEqual(stopPrice)
+	}
+	return price.LessThanOrEqual(stopPrice)
+}
+
+// Buys fill at or below the limit, sells (take-profit) at or above it
+func limitReached(side string, limitPrice, price decimal.Decimal) bool {
+	if side == buy {
+		return price.LessThanOrEqual(limitPrice)
+	}
+	return price.GreaterThanOrEqual(limitPrice)
+}
+
+// DAY orders live until the close of the trading day they're placed on, or of the next trading
+// day when placed after the close or while the market is shut. Crypto never closes, so its DAY
+// orders get 24 hours.
+func dayOrderExpiry(ctx context.Context, cfg *config.APIConfig, stonk database.Stock, now time.Time) (time.Time, error) {
+	if stonk.AssetClass == config.AssetCrypto {
+		return now.Add(24 * time.Hour), nil
+	}
+	market, err := instrumentMarket(ctx, cfg, stonk.Symbol)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Dates and the close are the exchange's, AddDate keeps the close's wall clock across DST
+	session := marketCloses[market]
+	local := now.In(session.loc)
+	expiry := time.Date(local.Year(), local.Month(), local.Day(), session.hour, session.minute, 0, 0, session.loc)
+	for ; ; expiry = expiry.AddDate(0, 0, 1) {
+		if !now.Before(expiry) || expiry.Weekday() == time.Saturday || expiry.Weekday() == time.Sunday {
+			continue
+		}
+		holiday, err := cfg.DB.IsMarketHoliday(ctx, database.IsMarketHolidayParams{
+			Market:      market,
+			HolidayDate: dateOnly(expiry),
+		})
+		if err != nil {
+			return time.Time{}, err
+		}
+		if !holiday {
+			return expiry.UTC(), nil
+		}
+	}
+}
+
+// The market a symbol trades on, from its exchange in the instrument master when that's known
+func instrumentMarket(ctx context.Context, cfg *config.APIConfig, symbol string) (string, error) {
+	instrument, err := cfg.DB.GetInstrument(ctx, symbol)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+	if market, ok := exchangeMarkets[instrument.Exchange]; ok {
+		return market, nil
+	}
+	return marketForSymbol(symbol), nil
+}
+
+func toNullDecimal(d *decimal.Decimal) decimal.NullDecimal {
+	if d == nil {
+		return decimal.NullDecimal{}
+	}
+	return decimal.NullDecimal{Decimal: *d, Valid: true}
+}
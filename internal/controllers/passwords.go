@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/notify"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	passwordResetTTL  = time.Hour
+	minPasswordLength = 8
+)
+
+var errPasswordTooShort = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+
+// ForgotPassword emails a single use reset link to the account with the address. The response
+// is the same whether there is one or not, so it can't be used to find out who has an account.
+func ForgotPassword(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "password_forgot") {
+			respondWithError(ctx, http.StatusTooManyRequests, "Password Reset Quota Expired", nil)
+			return
+		}
+
+		req := struct {
+			Email string `json:"email"`
+		}{}
+		err := ctx.ShouldBindJSON(&req)
+		req.Email = utils.NormalizeEmail(req.Email)
+		if err != nil || req.Email == "" {
+			respondWithError(ctx, http.StatusBadRequest, "email is required", err)
+			return
+		}
+		accepted := func() {
+			ctx.JSON(http.StatusAccepted, gin.H{"message": "If an account uses this email, a reset link is on its way"})
+		}
+
+		// Per address as well so one inbox can't be flooded from many IPs
+		if !cfg.CheckRateLimit(ctx, req.Email, "password_forgot") {
+			accepted()
+			return
+		}
+		user, err := cfg.DB.GetUserByEmail(ctx, req.Email)
+		if errors.Is(err, sql.ErrNoRows) {
+			accepted()
+			return
+		}
+		if err != nil {
+			respondWithError(ctx, 500, "error getting user", err)
+			return
+		}
+
+		token, err := auth.MakeToken()
+		if err != nil {
+			respondWithError(ctx, 500, "error making reset token", err)
+			return
+		}
+		// Asking again replaces the link sent before
+		err = withTx(ctx, cfg, func(q *database.Queries) error {
+			if err := q.ExpirePasswordResetTokens(ctx, user.ID); err != nil {
+				return err
+			}
+			_, err := q.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+				UserID:    user.ID,
+				TokenHash: auth.HashToken(token),
+				ExpiresAt: time.Now().UTC().Add(passwordResetTTL),
+			})
+			return err
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error creating reset token", err)
+			return
+		}
+
+		link := ""
+		if cfg.AppURL != "" {
+			link = cfg.AppURL + "/reset-password?token=" + token
+		}
+		err = notify.Enqueue(ctx, cfg.RD, notify.Message{
+			UserID:   user.ID,
+			Kind:     notify.KindAccount,
+			Template: "password_reset",
+			Subject:  "Reset your password",
+			Data: map[string]any{
+				"Token":     token,
+				"Link":      link,
+				"ExpiresIn": fmt.Sprintf("%d minutes", int(passwordResetTTL.Minutes())),
+			},
+		})
+		if err != nil {
+			log.Printf("Error queueing password reset email: %v\n", err)
+		}
+
+		accepted()
+	}
+}
+
+// ResetPassword sets a new password with the token from a reset link and logs out everywhere
+func ResetPassword(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "password_reset") {
+			respondWithError(ctx, http.StatusTooManyRequests, "Password Reset Quota Expired", nil)
+			return
+		}
+
+		req := struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		}{}
+		if err := ctx.ShouldBindJSON(&req); err != nil || req.Token == "" {
+			respondWithError(ctx, http.StatusBadRequest, "token and password are required", err)
+			return
+		}
+		if len(req.Password) < minPasswordLength {
+			respondWithError(ctx, http.StatusBadRequest, "Password too short", errPasswordTooShort)
+			return
+		}
+
+		hashedPass, err := auth.HashPassword(req.Password)
+		if err != nil {
+			respondWithError(ctx, 500, "error hashing password", err)
+			return
+		}
+
+		var userId uuid.UUID
+		err = withTx(ctx, cfg, func(q *database.Queries) error {
+			userId, err = q.UsePasswordResetToken(ctx, database.UsePasswordResetTokenParams{
+				TokenHash: auth.HashToken(req.Token),
+				ExpiresAt: time.Now().UTC(),
+			})
+			if err != nil {
+				return err
+			}
+			if err := updatePassword(ctx, q, userId, hashedPass); err != nil {
+				return err
+			}
+			// The link got to their inbox, so the address is theirs
+			return q.MarkUserVerified(ctx, userId)
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid or expired reset token", nil)
+			return
+		}
+		if err != nil {
+			respondWithError(ctx, 500, "error resetting password", err)
+			return
+		}
+
+		if err := passwordChanged(ctx, cfg, userId, "reset"); err != nil {
+			respondWithError(ctx, 500, "error ending sessions", err)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// ChangePassword needs the current password as well as a token. Every session is ended,
+// the caller gets a new one so they stay logged in here.
+func ChangePassword(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "password_change") {
+			respondWithError(ctx, http.StatusTooManyRequests, "Password Change Quota Expired", nil)
+			return
+		}
+
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		req := struct {
+			OldPassword string `json:"old_password"`
+			NewPassword string `json:"new_password"`
+		}{}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "error unmarshalling request", err)
+			return
+		}
+
+		user, err := cfg.DB.GetUserByID(ctx, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting user", err)
+			return
+		}
+		if err := auth.CheckPasswordHash(req.OldPassword, user.HashedPassword); err != nil {
+			respondWithError(ctx, http.StatusForbidden, "Old password doesn't match", err)
+			return
+		}
+		if len(req.NewPassword) < minPasswordLength {
+			respondWithError(ctx, http.StatusBadRequest, "Password too short", errPasswordTooShort)
+			return
+		}
+
+		hashedPass, err := auth.HashPassword(req.NewPassword)
+		if err != nil {
+			respondWithError(ctx, 500, "error hashing password", err)
+			return
+		}
+		err = withTx(ctx, cfg, func(q *database.Queries) error {
+			return updatePassword(ctx, q, userId, hashedPass)
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error changing password", err)
+			return
+		}
+
+		if err := passwordChanged(ctx, cfg, userId, "changed"); err != nil {
+			respondWithError(ctx, 500, "error ending sessions", err)
+			return
+		}
+		tokens, err := startSession(ctx, cfg, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error making token", err)
+			return
+		}
+		ctx.JSON(200, tokens)
+	}
+}
+
+// Stores the new hash, reset links sent for the old password stop working
+func updatePassword(ctx context.Context, q *database.Queries, userId uuid.UUID, hashedPass string) error {
+	if err := q.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		ID:             userId,
+		HashedPassword: hashedPass,
+	}); err != nil {
+		return err
+	}
+	return q.ExpirePasswordResetTokens(ctx, userId)
+}
+
+// Ends every session signed in with the old password and lets the user know
+func passwordChanged(ctx *gin.Context, cfg *config.APIConfig, userId uuid.UUID, how string) error {
+	if _, err := endOtherSessions(ctx, cfg, userId, uuid.Nil); err != nil {
+		return err
+	}
+
+	err := notify.Enqueue(ctx, cfg.RD, notify.Message{
+		UserID:   userId,
+		Kind:     notify.KindSecurity,
+		Template: "password_changed",
+		Subject:  "Your password was " + how,
+		Data: map[string]any{
+			"How":       how,
+			"Time":      time.Now().UTC().Format(time.RFC1123),
+			"IP":        ctx.ClientIP(),
+			"UserAgent": ctx.Request.UserAgent(),
+		},
+	})
+	if err != nil {
+		log.Printf("Error queueing password changed notification: %v\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,529 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/notify"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	dailyPlan   = "DAILY"
+	weeklyPlan  = "WEEKLY"
+	monthlyPlan = "MONTHLY"
+
+	planActive    = "ACTIVE"
+	planPaused    = "PAUSED"
+	planCompleted = "COMPLETED"
+	planCancelled = "CANCELLED"
+
+	planExecuted = "EXECUTED"
+	planSkipped  = "SKIPPED"
+)
+
+var errPlanAlreadyRan = errors.New("plan already ran for this date")
+
+func CreatePlan(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+		if !checkEmailVerified(ctx, cfg, userId) {
+			return
+		}
+
+		// Parse request, either amount or quantity per installment
+		var req struct {
+			StockSymbol string           `json:"stock_symbol"`
+			Amount      *decimal.Decimal `json:"amount"`
+			Quantity    *decimal.Decimal `json:"quantity"`
+			Frequency   string           `json:"frequency"`
+			StartDate   string           `json:"start_date"`
+			EndDate     string           `json:"end_date"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+		if (req.Amount == nil) == (req.Quantity == nil) ||
+			(req.Amount != nil && req.Amount.Sign() <= 0) || (req.Quantity != nil && req.Quantity.Sign() <= 0) {
+			respondWithError(ctx, http.StatusBadRequest, "Exactly one of amount or quantity must be given and > 0", nil)
+			return
+		}
+		req.Frequency = strings.ToUpper(req.Frequency)
+		if req.Frequency != dailyPlan && req.Frequency != weeklyPlan && req.Frequency != monthlyPlan {
+			respondWithError(ctx, http.StatusBadRequest, "frequency must be DAILY/WEEKLY/MONTHLY", nil)
+			return
+		}
+
+		// Resolving the stock also makes sure it exists in DB for the FK
+		stonk, err := getOrFetchStock(ctx, cfg, req.StockSymbol)
+		if err != nil {
+			respondWithStockError(ctx, err)
+			return
+		}
+
+		// Plan dates are on the exchange's calendar, not the server's
+		clock, err := clockForStock(ctx, cfg, stonk)
+		if err != nil {
+			respondWithError(ctx, http.StatusInternalServerError, "Failed to create plan", err)
+			return
+		}
+		today := clock.today(time.Now())
+		startDate := today
+		if req.StartDate != "" {
+			startDate, err = time.Parse(time.DateOnly, req.StartDate)
+			if err != nil {
+				respondWithError(ctx, http.StatusBadRequest, "start_date must be YYYY-MM-DD", err)
+				return
+			}
+			if startDate.Before(today) {
+				respondWithError(ctx, http.StatusBadRequest, "start_date can't be in the past", nil)
+				return
+			}
+		}
+		var endDate sql.NullTime
+		if req.EndDate != "" {
+			end, err := time.Parse(time.DateOnly, req.EndDate)
+			if err != nil || end.Before(startDate) {
+				respondWithError(ctx, http.StatusBadRequest, "end_date must be YYYY-MM-DD on or after start_date", err)
+				return
+			}
+			endDate = sql.NullTime{Time: end, Valid: true}
+		}
+
+		var quantity decimal.NullDecimal
+		if req.Quantity != nil {
+			if err := checkQuantityPrecision(*req.Quantity, stonk); err != nil {
+				respondWithError(ctx, http.StatusBadRequest, "Invalid quantity", err)
+				return
+			}
+			quantity = decimal.NullDecimal{Decimal: *req.Quantity, Valid: true}
+		}
+		plan, err := cfg.DB.CreateInvestmentPlan(ctx, database.CreateInvestmentPlanParams{
+			UserID:      userId,
+			StockSymbol: stonk.Symbol,
+			Amount:      toNullDecimal(req.Amount),
+			Quantity:    quantity,
+			Frequency:   req.Frequency,
+			StartDate:   startDate,
+			EndDate:     endDate,
+		})
+		if err != nil {
+			respondWithError(ctx, http.StatusInternalServerError, "Failed to create plan", err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, plan)
+	}
+}
+
+func GetPlans(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		plans, err := cfg.DB.GetInvestmentPlansForUser(ctx, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting plans", err)
+			return
+		}
+
+		ctx.JSON(200, plans)
+	}
+}
+
+func GetPlanExecutions(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		plan, ok := planFromRequest(ctx, cfg)
+		if !ok {
+			return
+		}
+
+		executions, err := cfg.DB.GetPlanExecutions(ctx, plan.ID)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting plan executions", err)
+			return
+		}
+
+		ctx.JSON(200, executions)
+	}
+}
+
+func PausePlan(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		plan, ok := planFromRequest(ctx, cfg)
+		if !ok {
+			return
+		}
+		if plan.Status != planActive {
+			respondWithError(ctx, http.StatusConflict, "Only active plans can be paused", nil)
+			return
+		}
+
+		plan, err := cfg.DB.UpdateInvestmentPlanStatus(ctx, database.UpdateInvestmentPlanStatusParams{
+			ID:     plan.ID,
+			UserID: plan.UserID,
+			Status: planPaused,
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error pausing plan", err)
+			return
+		}
+
+		ctx.JSON(200, plan)
+	}
+}
+
+func ResumePlan(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		plan, ok := planFromRequest(ctx, cfg)
+		if !ok {
+			return
+		}
+		if plan.Status != planPaused {
+			respondWithError(ctx, http.StatusConflict, "Only paused plans can be resumed", nil)
+			return
+		}
+
+		stonk, err := cfg.DB.GetStockBySymbol(ctx, plan.StockSymbol)
+		if err != nil {
+			respondWithError(ctx, 500, "error resuming plan", err)
+			return
+		}
+		clock, err := clockForStock(ctx, cfg, stonk)
+		if err != nil {
+			respondWithError(ctx, 500, "error resuming plan", err)
+			return
+		}
+
+		// Installments missed while paused aren't caught up, the plan carries on from its next date
+		nextRun := plan.NextRunDate
+		today := clock.today(time.Now())
+		if nextRun.Before(today) {
+			nextRun = nextPlanDate(plan, today.AddDate(0, 0, -1))
+		}
+		status := planActive
+		if plan.EndDate.Valid && nextRun.After(plan.EndDate.Time) {
+			status = planCompleted
+		}
+
+		plan, err = cfg.DB.ScheduleInvestmentPlan(ctx, database.ScheduleInvestmentPlanParams{
+			ID:          plan.ID,
+			NextRunDate: nextRun,
+			Status:      status,
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error resuming plan", err)
+			return
+		}
+
+		ctx.JSON(200, plan)
+	}
+}
+
+func CancelPlan(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		plan, ok := planFromRequest(ctx, cfg)
+		if !ok {
+			return
+		}
+		if plan.Status == planCancelled || plan.Status == planCompleted {
+			respondWithError(ctx, http.StatusConflict, "Plan already finished", nil)
+			return
+		}
+
+		plan, err := cfg.DB.UpdateInvestmentPlanStatus(ctx, database.UpdateInvestmentPlanStatusParams{
+			ID:     plan.ID,
+			UserID: plan.UserID,
+			Status: planCancelled,
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error cancelling plan", err)
+			return
+		}
+
+		ctx.JSON(200, plan)
+	}
+}
+
+// Authenticates and loads the :id plan of the user, responding itself on failure
+func planFromRequest(ctx *gin.Context, cfg *config.APIConfig) (database.InvestmentPlan, bool) {
+	// Authorization required for this route
+	userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+	if err != nil {
+		respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+		return database.InvestmentPlan{}, false
+	}
+
+	planId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid plan id", err)
+		return database.InvestmentPlan{}, false
+	}
+
+	plan, err := cfg.DB.GetInvestmentPlanForUser(ctx, database.GetInvestmentPlanForUserParams{
+		ID:     planId,
+		UserID: userId,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(ctx, 404, "No plan with this id", err)
+			return database.InvestmentPlan{}, false
+		}
+		respondWithError(ctx, 500, "error getting plan", err)
+		return database.InvestmentPlan{}, false
+	}
+	return plan, true
+}
+
+// RunDuePlans executes every active plan due on or before today on its exchange's calendar,
+// while that exchange is in its regular session. Plans due on a weekend or market holiday stay
+// due and run on the next trading day.
+func RunDuePlans(ctx context.Context, cfg *config.APIConfig, now time.Time) {
+	// A day past UTC covers every exchange's today, each plan is checked on its own clock below
+	plans, err := cfg.DB.GetDueInvestmentPlans(ctx, dateOnly(now.UTC()).AddDate(0, 0, 1))
+	if err != nil {
+		log.Printf("Error getting due plans: %v\n", err)
+		return
+	}
+
+	for _, plan := range plans {
+		stonk, err := cfg.DB.GetStockBySymbol(ctx, plan.StockSymbol)
+		if err != nil {
+			log.Printf("Error getting stock for plan %s: %v\n", plan.ID, err)
+			continue
+		}
+		clock, err := clockForStock(ctx, cfg, stonk)
+		if err != nil {
+			log.Printf("Error getting market for plan %s: %v\n", plan.ID, err)
+			continue
+		}
+		today := clock.today(now)
+		if plan.NextRunDate.After(today) || !clock.open(now) {
+			continue
+		}
+
+		tradingDay, err := isTradingDay(ctx, cfg, clock, today)
+		if err != nil {
+			log.Printf("Error checking market holidays: %v\n", err)
+			continue
+		}
+		if !tradingDay {
+			continue
+		}
+
+		if err := executePlan(ctx, cfg, plan, today); err != nil && !errors.Is(err, errPlanAlreadyRan) {
+			log.Printf("Error executing plan %s: %v\n", plan.ID, err)
+		}
+	}
+}
+
+func executePlan(ctx context.Context, cfg *config.APIConfig, plan database.InvestmentPlan, today time.Time) error {
+	stonk, err := getOrFetchStock(ctx, cfg, plan.StockSymbol)
+	if err != nil {
+		return err
+	}
+
+	// Amount plans buy as much as the amount covers at the stock's precision
+	quantity := plan.Quantity.Decimal
+	if plan.Amount.Valid {
+		quantity = utils.QuantityForAmount(plan.Amount.Decimal, stonk.CurrentPrice, stonk.QuantityPrecision)
+	}
+	cost := utils.Amount(quantity, stonk.CurrentPrice)
+
+	balance, err := cfg.DB.GetCashBalance(ctx, plan.UserID)
+	if err != nil {
+		return err
+	}
+
+	reason := ""
+	switch {
+	case quantity.IsZero():
+		reason = fmt.Sprintf("amount doesn't cover the smallest quantity at %s", stonk.CurrentPrice.StringFixed(2))
+	case balance.LessThan(cost):
+		reason = fmt.Sprintf("%v: needed %s, had %s", ErrInsufficientCash, cost.StringFixed(2), balance.StringFixed(2))
+	}
+
+	// Skipped installments still move the plan on to its next date
+	nextRun := nextPlanDate(plan, today)
+	status := planActive
+	if plan.EndDate.Valid && nextRun.After(plan.EndDate.Time) {
+		status = planCompleted
+	}
+
+	var result TradeResult
+	err = withTx(ctx, cfg, func(q *database.Queries) error {
+		execution := database.CreatePlanExecutionParams{
+			PlanID:        plan.ID,
+			ScheduledDate: plan.NextRunDate,
+			Status:        planSkipped,
+			Reason:        reason,
+		}
+		if reason == "" {
+			var err error
+			result, err = ExecuteTransaction(ctx, q, plan.UserID, plan.StockSymbol, buy, quantity, stonk.CurrentPrice)
+			switch {
+			// e.g. a short position in the stock, rejected before anything is written
+			case IsTradeError(err):
+				reason = err.Error()
+				execution.Reason = reason
+			case err != nil:
+				return err
+			default:
+				execution.Status = planExecuted
+				execution.TransactionID = uuid.NullUUID{UUID: result.Transaction.ID, Valid: true}
+			}
+		}
+
+		// The unique (plan, date) row keeps a second scheduler run from buying twice
+		rows, err := q.CreatePlanExecution(ctx, execution)
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return errPlanAlreadyRan
+		}
+
+		_, err = q.ScheduleInvestmentPlan(ctx, database.ScheduleInvestmentPlanParams{
+			ID:          plan.ID,
+			NextRunDate: nextRun,
+			Status:      status,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	title := fmt.Sprintf("Plan installment executed: bought %s %s", quantity, plan.StockSymbol)
+	body := fmt.Sprintf("Bought %s %s at %s for %s.", quantity, plan.StockSymbol, stonk.CurrentPrice.StringFixed(2), result.Transaction.TotalAmount.StringFixed(2))
+	if reason != "" {
+		title = fmt.Sprintf("Plan installment skipped: %s", plan.StockSymbol)
+		body = fmt.Sprintf("Your %s installment for %s was skipped, %s.", strings.ToLower(plan.Frequency), plan.StockSymbol, reason)
+	}
+	err = notify.Enqueue(ctx, cfg.RD, notify.Message{
+		UserID:   plan.UserID,
+		Kind:     notify.KindAlert,
+		Template: "alert",
+		Subject:  title,
+		Data: map[string]any{
+			"Title": title,
+			"Body":  body,
+		},
+	})
+	if err != nil {
+		log.Printf("Error queueing plan notification: %v\n", err)
+	}
+	return nil
+}
+
+// nth installment date counted from the start date, so monthly plans keep their day of month
+func planDate(plan database.InvestmentPlan, n int) time.Time {
+	start := plan.StartDate
+	switch plan.Frequency {
+	case dailyPlan:
+		return start.AddDate(0, 0, n)
+	case weeklyPlan:
+		return start.AddDate(0, 0, 7*n)
+	default:
+		// clamp to the end of shorter months instead of spilling into the next one
+		first := time.Date(start.Year(), start.Month()+time.Month(n), 1, 0, 0, 0, 0, start.Location())
+		day := min(start.Day(), first.AddDate(0, 1, -1).Day())
+		return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, start.Location())
+	}
+}
+
+// First installment date strictly after the given date
+func nextPlanDate(plan database.InvestmentPlan, after time.Time) time.Time {
+	for n := 1; ; n++ {
+		if date := planDate(plan, n); date.After(after) {
+			return date
+		}
+	}
+}
+
+// The market a plan trades on and the clock its dates are kept on. Crypto never closes, its
+// plans have no market and go by UTC dates.
+type planClock struct {
+	market string
+	loc    *time.Location
+}
+
+func clockForStock(ctx context.Context, cfg *config.APIConfig, stonk database.Stock) (planClock, error) {
+	if stonk.AssetClass == config.AssetCrypto {
+		return planClock{loc: time.UTC}, nil
+	}
+	market, err := instrumentMarket(ctx, cfg, stonk.Symbol)
+	if err != nil {
+		return planClock{}, err
+	}
+	return planClock{market: market, loc: marketCloses[market].loc}, nil
+}
+
+// The exchange's calendar date at now
+func (c planClock) today(now time.Time) time.Time {
+	return dateOnly(now.In(c.loc))
+}
+
+// Whether now falls in the exchange's regular session, by its own wall clock
+func (c planClock) open(now time.Time) bool {
+	if c.market == "" {
+		return true
+	}
+	local := now.In(c.loc)
+	minute := local.Hour()*60 + local.Minute()
+	opens, closes := marketOpens[c.market], marketCloses[c.market]
+	return minute >= opens.hour*60+opens.minute && minute < closes.hour*60+closes.minute
+}
+
+// Weekends and the market's holidays are closed, crypto trades every day
+func isTradingDay(ctx context.Context, cfg *config.APIConfig, clock planClock, day time.Time) (bool, error) {
+	if clock.market == "" {
+		return true, nil
+	}
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false, nil
+	}
+	holiday, err := cfg.DB.IsMarketHoliday(ctx, database.IsMarketHolidayParams{
+		Market:      clock.market,
+		HolidayDate: day,
+	})
+	if err != nil {
+		return false, err
+	}
+	return !holiday, nil
+}
+
+// Indian listings carry an exchange suffix (and ^NSEI style indices), everything else is US
+func marketForSymbol(symbol string) string {
+	if strings.HasSuffix(symbol, ".NS") || strings.HasSuffix(symbol, ".BO") || strings.HasPrefix(symbol, "^NSE") || strings.HasPrefix(symbol, "^BSE") {
+		return "IN"
+	}
+	return "US"
+}
+
+// Local calendar date as UTC midnight, the way DATE columns come back from postgres
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
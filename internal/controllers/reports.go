@@ -0,0 +1,291 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	shortTerm = "SHORT_TERM"
+	longTerm  = "LONG_TERM"
+)
+
+// taxRule is a jurisdiction's fiscal year and the months an asset has to be held
+// for its gain to be long term, by asset class. A class without an entry uses the
+// "" entry and 0 months means the gain is never long term.
+type taxRule struct {
+	FiscalYearStart time.Month     `json:"fiscal_year_start"`
+	LongTermMonths  map[string]int `json:"long_term_months"`
+}
+
+var taxRules = map[string]taxRule{
+	// April to March, listed equity and equity funds after 12 months, other assets
+	// after 24, crypto gains are all taxed the same
+	"IN": {FiscalYearStart: time.April, LongTermMonths: map[string]int{
+		"": 24, config.AssetEquity: 12, config.AssetETF: 12, config.AssetMutualFund: 12, config.AssetCrypto: 0,
+	}},
+	// Calendar year, anything held more than a year
+	"US": {FiscalYearStart: time.January, LongTermMonths: map[string]int{"": 12}},
+}
+
+var capitalGainsColumns = []string{"stock_symbol", "asset_class", "position", "quantity", "acquired_on", "disposed_on", "holding_days", "term", "cost_basis", "proceeds", "gain"}
+
+// One lot, or part of one, closed within the fiscal year
+type disposalRes struct {
+	StockSymbol string `json:"stock_symbol"`
+	AssetClass  string `json:"asset_class"`
+	// LONG for a sale of bought shares, SHORT for a cover of shorted ones
+	Position    string          `json:"position"`
+	Quantity    decimal.Decimal `json:"quantity"`
+	AcquiredOn  time.Time       `json:"acquired_on"`
+	DisposedOn  time.Time       `json:"disposed_on"`
+	HoldingDays int             `json:"holding_days"`
+	Term        string          `json:"term"`
+	CostBasis   decimal.Decimal `json:"cost_basis"`
+	Proceeds    decimal.Decimal `json:"proceeds"`
+	Gain        decimal.Decimal `json:"gain"`
+}
+
+type capitalGainsRes struct {
+	Jurisdiction   string          `json:"jurisdiction"`
+	FiscalYear     string          `json:"fiscal_year"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	Rule           taxRule         `json:"rule"`
+	Disposals      []disposalRes   `json:"disposals"`
+	TotalCost      decimal.Decimal `json:"total_cost_basis"`
+	TotalProceeds  decimal.Decimal `json:"total_proceeds"`
+	ShortTermGain  decimal.Decimal `json:"short_term_gain"`
+	LongTermGain   decimal.Decimal `json:"long_term_gain"`
+	TotalGain      decimal.Decimal `json:"total_gain"`
+	DisposalsCount int             `json:"disposals_count"`
+}
+
+// GetCapitalGains lists every disposal realized in a fiscal year, matched to its lots first in first out
+func GetCapitalGains(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		// ?jurisdiction= picks the rules, ?long_term_months= overrides the holding period for every class
+		jurisdiction := strings.ToUpper(ctx.DefaultQuery("jurisdiction", "IN"))
+		rule, ok := taxRules[jurisdiction]
+		if !ok {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid jurisdiction", fmt.Errorf("must be one of IN/US"))
+			return
+		}
+		if months := ctx.Query("long_term_months"); months != "" {
+			n, err := strconv.Atoi(months)
+			if err != nil || n < 0 {
+				respondWithError(ctx, http.StatusBadRequest, "long_term_months must be a whole number >= 0", err)
+				return
+			}
+			rule = taxRule{FiscalYearStart: rule.FiscalYearStart, LongTermMonths: map[string]int{"": n}}
+		}
+
+		// ?fy= is the year the fiscal year starts in, the current one by default
+		from := fiscalYearStart(time.Now(), rule.FiscalYearStart)
+		if fy := ctx.Query("fy"); fy != "" {
+			year, err := strconv.Atoi(fy)
+			if err != nil || year < 1900 || year > 9999 {
+				respondWithError(ctx, http.StatusBadRequest, "fy must be a year like 2025", err)
+				return
+			}
+			from = time.Date(year, rule.FiscalYearStart, 1, 0, 0, 0, 0, time.UTC)
+		}
+		to := from.AddDate(1, 0, 0)
+
+		format := strings.ToLower(ctx.DefaultQuery("format", "json"))
+		if format != "json" && format != "csv" {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid format", fmt.Errorf("must be json or csv"))
+			return
+		}
+
+		res, err := buildCapitalGains(ctx, cfg, userId, rule, from, to)
+		if err != nil {
+			respondWithError(ctx, 500, "error building capital gains report", err)
+			return
+		}
+		res.Jurisdiction = jurisdiction
+		res.FiscalYear = fiscalYearLabel(from)
+
+		if format == "json" {
+			ctx.JSON(200, res)
+			return
+		}
+		ctx.Header("Content-Type", "text/csv; charset=utf-8")
+		ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="capital-gains-%s-%s.csv"`, jurisdiction, res.FiscalYear))
+		ctx.Status(200)
+		w := csv.NewWriter(ctx.Writer)
+		w.Write(capitalGainsColumns)
+		for _, d := range res.Disposals {
+			w.Write([]string{
+				d.StockSymbol, d.AssetClass, d.Position, d.Quantity.String(),
+				d.AcquiredOn.Format(time.DateOnly), d.DisposedOn.Format(time.DateOnly), strconv.Itoa(d.HoldingDays), d.Term,
+				d.CostBasis.String(), d.Proceeds.String(), d.Gain.String(),
+			})
+		}
+		w.Flush()
+	}
+}
+
+// An open lot, for shorts the amount is the sale proceeds instead of the cost
+type taxLot struct {
+	date     time.Time
+	quantity decimal.Decimal
+	amount   decimal.Decimal
+}
+
+// Takes quantity off the front of the lots, splitting the amounts in proportion so the parts
+// always add up to the whole
+func takeLots(lots []taxLot, quantity decimal.Decimal) (taken, rest []taxLot) {
+	for len(lots) > 0 && quantity.Sign() > 0 {
+		lot := lots[0]
+		if quantity.GreaterThanOrEqual(lot.quantity) {
+			taken = append(taken, lot)
+			quantity = quantity.Sub(lot.quantity)
+			lots = lots[1:]
+			continue
+		}
+		part := lot.amount.Mul(quantity).DivRound(lot.quantity, utils.MoneyScale)
+		taken = append(taken, taxLot{date: lot.date, quantity: quantity, amount: part})
+		lots[0] = taxLot{date: lot.date, quantity: lot.quantity.Sub(quantity), amount: lot.amount.Sub(part)}
+		quantity = decimal.Zero
+	}
+	return taken, lots
+}
+
+// Replays all of the user's trades up to the end of the year so lots opened in earlier years are known
+func buildCapitalGains(ctx context.Context, cfg *config.APIConfig, userId uuid.UUID, rule taxRule, from, to time.Time) (capitalGainsRes, error) {
+	txns, err := cfg.DB.GetTransactionsForUserBetween(ctx, database.GetTransactionsForUserBetweenParams{
+		UserID:      userId,
+		CreatedAt:   time.Time{},
+		CreatedAt_2: to,
+	})
+	if err != nil {
+		return capitalGainsRes{}, err
+	}
+
+	res := capitalGainsRes{From: from, To: to.AddDate(0, 0, -1), Rule: rule, Disposals: []disposalRes{}}
+	assetClasses := make(map[string]string)
+	longLots := make(map[string][]taxLot)
+	shortLots := make(map[string][]taxLot)
+	for _, txn := range txns {
+		symbol := txn.StockSymbol
+		switch txn.Type {
+		case buy:
+			longLots[symbol] = append(longLots[symbol], taxLot{date: txn.CreatedAt, quantity: txn.Quantity, amount: txn.TotalAmount})
+			continue
+		case sellShort:
+			shortLots[symbol] = append(shortLots[symbol], taxLot{date: txn.CreatedAt, quantity: txn.Quantity, amount: txn.TotalAmount})
+			continue
+		}
+
+		// A sale or cover, the lots it closes are taken even before the year so later years match right
+		lots := longLots
+		if txn.Type == buyToCover {
+			lots = shortLots
+		}
+		var taken []taxLot
+		taken, lots[symbol] = takeLots(lots[symbol], txn.Quantity)
+		if txn.CreatedAt.Before(from) {
+			continue
+		}
+
+		if _, ok := assetClasses[symbol]; !ok {
+			assetClasses[symbol] = config.AssetEquity
+			if stonk, err := cfg.DB.GetStockBySymbol(ctx, symbol); err == nil {
+				assetClasses[symbol] = stonk.AssetClass
+			}
+		}
+
+		// The transaction's amount splits over its lots the same way
+		remainingQty, remainingAmount := txn.Quantity, txn.TotalAmount
+		for _, lot := range taken {
+			amount := remainingAmount
+			if lot.quantity.LessThan(remainingQty) {
+				amount = remainingAmount.Mul(lot.quantity).DivRound(remainingQty, utils.MoneyScale)
+			}
+			remainingQty, remainingAmount = remainingQty.Sub(lot.quantity), remainingAmount.Sub(amount)
+
+			d := disposalRes{
+				StockSymbol: symbol,
+				AssetClass:  assetClasses[symbol],
+				Position:    "LONG",
+				Quantity:    lot.quantity,
+				AcquiredOn:  dateOnly(lot.date),
+				DisposedOn:  dateOnly(txn.CreatedAt),
+				CostBasis:   lot.amount,
+				Proceeds:    amount,
+				Term:        shortTerm,
+			}
+			if txn.Type == buyToCover {
+				// Shorts are sold first and bought back later, and are short term everywhere
+				d.Position, d.CostBasis, d.Proceeds = "SHORT", amount, lot.amount
+			} else {
+				d.Term = holdingTerm(rule, d.AssetClass, d.AcquiredOn, d.DisposedOn)
+			}
+			d.HoldingDays = int(d.DisposedOn.Sub(d.AcquiredOn).Hours() / 24)
+			d.Gain = d.Proceeds.Sub(d.CostBasis)
+
+			res.Disposals = append(res.Disposals, d)
+			res.TotalCost = res.TotalCost.Add(d.CostBasis)
+			res.TotalProceeds = res.TotalProceeds.Add(d.Proceeds)
+			if d.Term == longTerm {
+				res.LongTermGain = res.LongTermGain.Add(d.Gain)
+			} else {
+				res.ShortTermGain = res.ShortTermGain.Add(d.Gain)
+			}
+		}
+	}
+
+	res.TotalGain = res.ShortTermGain.Add(res.LongTermGain)
+	res.DisposalsCount = len(res.Disposals)
+	return res, nil
+}
+
+// Long term when held for more than the rule's months for the asset class
+func holdingTerm(rule taxRule, assetClass string, acquired, disposed time.Time) string {
+	months, ok := rule.LongTermMonths[assetClass]
+	if !ok {
+		months = rule.LongTermMonths[""]
+	}
+	if months > 0 && disposed.After(acquired.AddDate(0, months, 0)) {
+		return longTerm
+	}
+	return shortTerm
+}
+
+// Start of the fiscal year t falls in
+func fiscalYearStart(t time.Time, start time.Month) time.Time {
+	year := t.Year()
+	if t.Month() < start {
+		year--
+	}
+	return time.Date(year, start, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// 2025 for calendar years, 2025-26 for years that straddle two
+func fiscalYearLabel(from time.Time) string {
+	if from.Month() == time.January {
+		return strconv.Itoa(from.Year())
+	}
+	return fmt.Sprintf("%d-%02d", from.Year(), (from.Year()+1)%100)
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/shopspring/decimal"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestTakeLots(t *testing.T) {
+	d := decimal.RequireFromString
+	lot := func(date, quantity, amount string) taxLot {
+		return taxLot{date: day(date), quantity: d(quantity), amount: d(amount)}
+	}
+
+	tests := []struct {
+		name      string
+		lots      []taxLot
+		quantity  string
+		wantTaken []taxLot
+		wantRest  []taxLot
+	}{
+		{
+			name:      "whole lot",
+			lots:      []taxLot{lot("2024-01-02", "10", "1000")},
+			quantity:  "10",
+			wantTaken: []taxLot{lot("2024-01-02", "10", "1000")},
+		},
+		{
+			name:      "part of a lot",
+			lots:      []taxLot{lot("2024-01-02", "10", "1000")},
+			quantity:  "4",
+			wantTaken: []taxLot{lot("2024-01-02", "4", "400")},
+			wantRest:  []taxLot{lot("2024-01-02", "6", "600")},
+		},
+		{
+			name:      "part of a lot that doesn't split evenly keeps the whole",
+			lots:      []taxLot{lot("2024-01-02", "3", "100")},
+			quantity:  "1",
+			wantTaken: []taxLot{lot("2024-01-02", "1", "33.33333333")},
+			wantRest:  []taxLot{lot("2024-01-02", "2", "66.66666667")},
+		},
+		{
+			name:      "fractional part",
+			lots:      []taxLot{lot("2024-01-02", "0.5", "25000")},
+			quantity:  "0.00000001",
+			wantTaken: []taxLot{lot("2024-01-02", "0.00000001", "0.0005")},
+			wantRest:  []taxLot{lot("2024-01-02", "0.49999999", "24999.9995")},
+		},
+		{
+			name:      "across several lots, oldest first",
+			lots:      []taxLot{lot("2024-01-02", "5", "500"), lot("2024-03-04", "5", "600"), lot("2024-05-06", "10", "1500")},
+			quantity:  "12",
+			wantTaken: []taxLot{lot("2024-01-02", "5", "500"), lot("2024-03-04", "5", "600"), lot("2024-05-06", "2", "300")},
+			wantRest:  []taxLot{lot("2024-05-06", "8", "1200")},
+		},
+		{
+			name:      "exactly several lots",
+			lots:      []taxLot{lot("2024-01-02", "5", "500"), lot("2024-03-04", "5", "600"), lot("2024-05-06", "10", "1500")},
+			quantity:  "10",
+			wantTaken: []taxLot{lot("2024-01-02", "5", "500"), lot("2024-03-04", "5", "600")},
+			wantRest:  []taxLot{lot("2024-05-06", "10", "1500")},
+		},
+		{
+			name:      "more than there is takes everything",
+			lots:      []taxLot{lot("2024-01-02", "5", "500"), lot("2024-03-04", "5", "600")},
+			quantity:  "12",
+			wantTaken: []taxLot{lot("2024-01-02", "5", "500"), lot("2024-03-04", "5", "600")},
+		},
+		{
+			name:     "no lots",
+			quantity: "1",
+		},
+		{
+			name:     "nothing to take",
+			lots:     []taxLot{lot("2024-01-02", "5", "500")},
+			quantity: "0",
+			wantRest: []taxLot{lot("2024-01-02", "5", "500")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before decimal.Decimal
+			for _, l := range tt.lots {
+				before = before.Add(l.amount)
+			}
+
+			taken, rest := takeLots(tt.lots, d(tt.quantity))
+			checkLots(t, "taken", taken, tt.wantTaken)
+			checkLots(t, "rest", rest, tt.wantRest)
+
+			// Nothing is lost or made up by the split
+			var after decimal.Decimal
+			for _, l := range append(taken, rest...) {
+				after = after.Add(l.amount)
+			}
+			if !after.Equal(before) {
+				t.Errorf("amounts add up to %s, lots had %s", after, before)
+			}
+		})
+	}
+}
+
+func checkLots(t *testing.T, what string, got, want []taxLot) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s %d lots, want %d", what, len(got), len(want))
+	}
+	for i := range got {
+		if !got[i].date.Equal(want[i].date) || !got[i].quantity.Equal(want[i].quantity) || !got[i].amount.Equal(want[i].amount) {
+			t.Errorf("%s lot %d is %s, want %s", what, i, formatLot(got[i]), formatLot(want[i]))
+		}
+	}
+}
+
+func formatLot(l taxLot) string {
+	return l.date.Format(time.DateOnly) + " " + l.quantity.String() + " for " + l.amount.String()
+}
+
+// Long term starts the day after the holding period ends
+func TestHoldingTerm(t *testing.T) {
+	tests := []struct {
+		name         string
+		jurisdiction string
+		assetClass   string
+		acquired     string
+		disposed     string
+		want         string
+	}{
+		{"IN equity on the anniversary", "IN", config.AssetEquity, "2024-01-15", "2025-01-15", shortTerm},
+		{"IN equity the day after", "IN", config.AssetEquity, "2024-01-15", "2025-01-16", longTerm},
+		{"IN ETF the day after", "IN", config.AssetETF, "2024-01-15", "2025-01-16", longTerm},
+		{"IN mutual fund on the anniversary", "IN", config.AssetMutualFund, "2024-01-15", "2025-01-15", shortTerm},
+		{"IN other assets after 12 months", "IN", config.AssetIndex, "2024-01-15", "2025-01-16", shortTerm},
+		{"IN other assets on the second anniversary", "IN", config.AssetIndex, "2024-01-15", "2026-01-15", shortTerm},
+		{"IN other assets the day after", "IN", config.AssetIndex, "2024-01-15", "2026-01-16", longTerm},
+		{"IN crypto is never long term", "IN", config.AssetCrypto, "2020-01-15", "2025-01-16", shortTerm},
+		{"US on the anniversary", "US", config.AssetEquity, "2024-01-15", "2025-01-15", shortTerm},
+		{"US the day after", "US", config.AssetEquity, "2024-01-15", "2025-01-16", longTerm},
+		{"US crypto the day after", "US", config.AssetCrypto, "2024-01-15", "2025-01-16", longTerm},
+		{"US same day", "US", config.AssetEquity, "2024-01-15", "2024-01-15", shortTerm},
+		// A year after Feb 29 is Mar 1
+		{"US leap day on Mar 1", "US", config.AssetEquity, "2024-02-29", "2025-03-01", shortTerm},
+		{"US leap day on Mar 2", "US", config.AssetEquity, "2024-02-29", "2025-03-02", longTerm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := holdingTerm(taxRules[tt.jurisdiction], tt.assetClass, day(tt.acquired), day(tt.disposed))
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
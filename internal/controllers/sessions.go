@@ -0,0 +1,372 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/events"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/notify"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	errRefreshTokenReused = errors.New("refresh token was already used")
+	errAccountDisabled    = errors.New("account is disabled")
+)
+
+type tokensRes struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	// Seconds until token expires
+	ExpiresIn int `json:"expires_in"`
+}
+
+// Hands out an access token and a refresh token for the session, the refresh token starts
+// the session when it's new and rotates it otherwise. The access token carries the user's
+// role as it is now, disabled users get errAccountDisabled.
+func issueTokens(ctx context.Context, q *database.Queries, secret string, userId, sessionId uuid.UUID) (tokensRes, error) {
+	user, err := q.GetUserByID(ctx, userId)
+	if err != nil {
+		return tokensRes{}, err
+	}
+	if user.DisabledAt.Valid {
+		return tokensRes{}, errAccountDisabled
+	}
+
+	refreshToken, err := auth.MakeToken()
+	if err != nil {
+		return tokensRes{}, err
+	}
+	_, err = q.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		UserID:    userId,
+		FamilyID:  sessionId,
+		TokenHash: auth.HashToken(refreshToken),
+		ExpiresAt: time.Now().UTC().Add(auth.RefreshTokenTTL),
+	})
+	if err != nil {
+		return tokensRes{}, err
+	}
+
+	token, err := auth.MakeJWT(userId, sessionId, user.Role, secret, auth.AccessTokenTTL)
+	if err != nil {
+		return tokensRes{}, err
+	}
+	return tokensRes{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// Starts a session for a user who just proved who they are
+func startSession(ctx *gin.Context, cfg *config.APIConfig, userId uuid.UUID) (tokensRes, error) {
+	var tokens tokensRes
+	err := withTx(ctx, cfg, func(q *database.Queries) error {
+		session, err := q.CreateSession(ctx, database.CreateSessionParams{
+			UserID:    userId,
+			UserAgent: ctx.Request.UserAgent(),
+			Ip:        ctx.ClientIP(),
+			ExpiresAt: time.Now().UTC().Add(auth.RefreshTokenTTL),
+		})
+		if err != nil {
+			return err
+		}
+		tokens, err = issueTokens(ctx, q, cfg.JWTSecret, userId, session.ID)
+		return err
+	})
+	return tokens, err
+}
+
+// Ends a session, its refresh tokens stop working and so do the access tokens and SSE
+// connections already out. Reports whether the user had such a session still going.
+func endSession(ctx context.Context, cfg *config.APIConfig, userId, sessionId uuid.UUID) (bool, error) {
+	n, err := cfg.DB.RevokeSession(ctx, database.RevokeSessionParams{ID: sessionId, UserID: userId})
+	if err != nil {
+		return false, err
+	}
+	// Only ever the user's own refresh tokens, whether or not there's a session row for them
+	if _, err := cfg.DB.RevokeRefreshTokenFamily(ctx, database.RevokeRefreshTokenFamilyParams{
+		UserID:   userId,
+		FamilyID: sessionId,
+	}); err != nil {
+		return false, err
+	}
+	// Someone else's session, or one that's over already, isn't ours to revoke
+	if n == 0 {
+		return false, nil
+	}
+	return true, closeSession(ctx, sessionId)
+}
+
+// Ends every session of the user but keep, all of them when keep is uuid.Nil
+func endOtherSessions(ctx context.Context, cfg *config.APIConfig, userId, keep uuid.UUID) (int, error) {
+	ended, err := auth.EndSessions(ctx, cfg.DB, userId, keep)
+	if err != nil {
+		return 0, err
+	}
+	for _, sessionId := range ended {
+		endSessionStream(sessionId)
+	}
+	return len(ended), nil
+}
+
+func closeSession(ctx context.Context, sessionId uuid.UUID) error {
+	endSessionStream(sessionId)
+	return auth.RevokeSession(ctx, sessionId)
+}
+
+// Closes the session's SSE connections on this instance
+func endSessionStream(sessionId uuid.UUID) {
+	select {
+	case events.HubInstance.EndSession <- sessionId:
+	default:
+		log.Println("No session end sent on EndSession")
+	}
+}
+
+// RefreshToken swaps a refresh token for a new pair. Each refresh token works once, a used one
+// coming back means it was copied, so the whole session is ended.
+func RefreshToken(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "refresh") {
+			respondWithError(ctx, http.StatusTooManyRequests, "Refresh Quota Expired", nil)
+			return
+		}
+
+		req := struct {
+			RefreshToken string `json:"refresh_token"`
+		}{}
+		if err := ctx.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+			respondWithError(ctx, http.StatusBadRequest, "refresh_token is required", err)
+			return
+		}
+
+		stored, err := cfg.DB.GetRefreshTokenByHash(ctx, auth.HashToken(req.RefreshToken))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithError(ctx, http.StatusUnauthorized, "Invalid refresh token", nil)
+				return
+			}
+			respondWithError(ctx, 500, "error getting refresh token", err)
+			return
+		}
+		if stored.RevokedAt.Valid {
+			respondWithError(ctx, http.StatusUnauthorized, "Session has ended, log in again", nil)
+			return
+		}
+		if stored.UsedAt.Valid {
+			refreshTokenReused(ctx, cfg, stored)
+			return
+		}
+		if time.Now().After(stored.ExpiresAt) {
+			respondWithError(ctx, http.StatusUnauthorized, "Refresh token expired, log in again", nil)
+			return
+		}
+
+		var tokens tokensRes
+		err = withTx(ctx, cfg, func(q *database.Queries) error {
+			n, err := q.MarkRefreshTokenUsed(ctx, stored.ID)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return errRefreshTokenReused
+			}
+			err = q.TouchSession(ctx, database.TouchSessionParams{
+				ID:        stored.FamilyID,
+				UserAgent: ctx.Request.UserAgent(),
+				Ip:        ctx.ClientIP(),
+				ExpiresAt: time.Now().UTC().Add(auth.RefreshTokenTTL),
+			})
+			if err != nil {
+				return err
+			}
+			tokens, err = issueTokens(ctx, q, cfg.JWTSecret, stored.UserID, stored.FamilyID)
+			return err
+		})
+		if errors.Is(err, errRefreshTokenReused) {
+			refreshTokenReused(ctx, cfg, stored)
+			return
+		}
+		if errors.Is(err, errAccountDisabled) {
+			respondWithError(ctx, http.StatusForbidden, "Account is disabled", err)
+			return
+		}
+		if err != nil {
+			respondWithError(ctx, 500, "error refreshing tokens", err)
+			return
+		}
+
+		ctx.JSON(200, tokens)
+	}
+}
+
+func refreshTokenReused(ctx *gin.Context, cfg *config.APIConfig, stored database.RefreshToken) {
+	if _, err := endSession(ctx, cfg, stored.UserID, stored.FamilyID); err != nil {
+		respondWithError(ctx, 500, "error ending session", err)
+		return
+	}
+
+	err := notify.Enqueue(ctx, cfg.RD, notify.Message{
+		UserID:   stored.UserID,
+		Kind:     notify.KindSecurity,
+		Template: "session_revoked",
+		Subject:  "We signed out one of your sessions",
+		Data: map[string]any{
+			"Time":      time.Now().UTC().Format(time.RFC1123),
+			"IP":        ctx.ClientIP(),
+			"UserAgent": ctx.Request.UserAgent(),
+		},
+	})
+	if err != nil {
+		log.Printf("Error queueing session revoked notification: %v\n", err)
+	}
+
+	respondWithError(ctx, http.StatusUnauthorized, "Refresh token reuse detected, session ended", errRefreshTokenReused)
+}
+
+// LogoutUser ends the session the access token belongs to
+func LogoutUser(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		claims, err := auth.GetClaims(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+		userId, err := claims.UserID()
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		if _, err := endSession(ctx, cfg, userId, claims.SessionID); err != nil {
+			respondWithError(ctx, 500, "error ending session", err)
+			return
+		}
+		// Tokens from before sessions existed have no session to end
+		if err := auth.RevokeToken(ctx, claims); err != nil {
+			respondWithError(ctx, 500, "error revoking token", err)
+			return
+		}
+
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+type sessionRes struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// The session of the token making the request
+	Current bool `json:"current"`
+}
+
+// GetSessions lists the sessions that can still refresh, most recently used first
+func GetSessions(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		claims, err := auth.GetClaims(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+		userId, err := claims.UserID()
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		sessions, err := cfg.DB.GetActiveSessionsForUser(ctx, database.GetActiveSessionsForUserParams{
+			UserID:    userId,
+			ExpiresAt: time.Now().UTC(),
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error getting sessions", err)
+			return
+		}
+
+		res := []sessionRes{}
+		for _, session := range sessions {
+			res = append(res, sessionRes{
+				ID:         session.ID,
+				UserAgent:  session.UserAgent,
+				IP:         session.Ip,
+				CreatedAt:  session.CreatedAt,
+				LastUsedAt: session.LastUsedAt,
+				ExpiresAt:  session.ExpiresAt,
+				Current:    session.ID == claims.SessionID,
+			})
+		}
+		ctx.JSON(200, res)
+	}
+}
+
+// DeleteSession ends one of the user's sessions, the current one included
+func DeleteSession(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		sessionId, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid session id", err)
+			return
+		}
+
+		ended, err := endSession(ctx, cfg, userId, sessionId)
+		if err != nil {
+			respondWithError(ctx, 500, "error ending session", err)
+			return
+		}
+		if !ended {
+			respondWithError(ctx, 404, "Session not found", nil)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// DeleteSessions logs out everywhere, ?keep_current=true stays logged in on this device
+func DeleteSessions(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		claims, err := auth.GetClaims(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+		userId, err := claims.UserID()
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		keep := uuid.Nil
+		if ctx.Query("keep_current") == "true" {
+			keep = claims.SessionID
+		}
+		ended, err := endOtherSessions(ctx, cfg, userId, keep)
+		if err != nil {
+			respondWithError(ctx, 500, "error ending sessions", err)
+			return
+		}
+
+		ctx.JSON(200, gin.H{"ended": ended})
+	}
+}
@@ -0,0 +1,269 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/statements"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var ErrEmptyStatement = errors.New("nothing happened in the account this month")
+
+func GetStatements(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		issued, err := cfg.DB.GetStatementsForUser(ctx, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting statements", err)
+			return
+		}
+		ctx.JSON(200, issued)
+	}
+}
+
+// GetStatement serves the statement for :month, issuing it now if the month job hasn't
+func GetStatement(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		month, err := time.Parse("2006-01", ctx.Param("month"))
+		if err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Month must be YYYY-MM", err)
+			return
+		}
+		now := time.Now()
+		if !month.Before(time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)) {
+			respondWithError(ctx, 404, "Statements are issued once the month is over", nil)
+			return
+		}
+		format := strings.ToLower(ctx.DefaultQuery("format", "html"))
+		if !slices.Contains([]string{"html", "pdf", "json"}, format) {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid format", fmt.Errorf("must be html/pdf/json"))
+			return
+		}
+
+		statement, err := cfg.DB.GetStatement(ctx, database.GetStatementParams{
+			UserID: userId,
+			Period: month,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			statement, err = IssueStatement(ctx, cfg, userId, month, true)
+		}
+		if err != nil {
+			respondWithError(ctx, 500, "error getting statement", err)
+			return
+		}
+
+		filename := fmt.Sprintf("statement-%s", month.Format("2006-01"))
+		switch format {
+		case "html":
+			ctx.Data(200, "text/html; charset=utf-8", []byte(statement.Html))
+		case "pdf":
+			ctx.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s.pdf"`, filename))
+			ctx.Data(200, "application/pdf", statement.Pdf)
+		case "json":
+			ctx.Data(200, "application/json; charset=utf-8", statement.Payload)
+		}
+	}
+}
+
+// IssueStatement builds, renders and stores the statement for the month starting at month.
+// An issued statement is never replaced, if one already exists that one is returned.
+// Without allowEmpty a month with no activity returns ErrEmptyStatement.
+func IssueStatement(ctx context.Context, cfg *config.APIConfig, userId uuid.UUID, month time.Time, allowEmpty bool) (database.Statement, error) {
+	existing, err := cfg.DB.GetStatement(ctx, database.GetStatementParams{UserID: userId, Period: month})
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.Statement{}, err
+	}
+
+	statement, err := BuildStatement(ctx, cfg, userId, month)
+	if err != nil {
+		return database.Statement{}, err
+	}
+	if statement.Empty() && !allowEmpty {
+		return database.Statement{}, ErrEmptyStatement
+	}
+	html, pdf, err := statements.Render(statement)
+	if err != nil {
+		return database.Statement{}, err
+	}
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		return database.Statement{}, err
+	}
+
+	issued, err := cfg.DB.CreateStatement(ctx, database.CreateStatementParams{
+		UserID:  userId,
+		Period:  month,
+		Payload: payload,
+		Html:    html,
+		Pdf:     pdf,
+	})
+	// Someone else issued it in the meantime
+	if errors.Is(err, sql.ErrNoRows) {
+		return cfg.DB.GetStatement(ctx, database.GetStatementParams{UserID: userId, Period: month})
+	}
+	return issued, err
+}
+
+// BuildStatement replays the user's trades to get holdings at both ends of the month.
+// Closing holdings are valued at the current price, which is the month end price when the
+// month job issues it, and opening holdings at the previous statement's closing prices.
+func BuildStatement(ctx context.Context, cfg *config.APIConfig, userId uuid.UUID, month time.Time) (statements.Statement, error) {
+	end := month.AddDate(0, 1, 0)
+	user, err := cfg.DB.GetUserByID(ctx, userId)
+	if err != nil {
+		return statements.Statement{}, err
+	}
+	txns, err := cfg.DB.GetTransactionsForUserBetween(ctx, database.GetTransactionsForUserBetweenParams{
+		UserID:      userId,
+		CreatedAt:   time.Time{},
+		CreatedAt_2: end,
+	})
+	if err != nil {
+		return statements.Statement{}, err
+	}
+	split, _ := slices.BinarySearchFunc(txns, month, func(txn database.Transaction, t time.Time) int { return txn.CreatedAt.Compare(t) })
+
+	s := statements.Statement{
+		Name:         user.Name,
+		Email:        user.Email,
+		Month:        month,
+		IssuedAt:     time.Now(),
+		Transactions: txns[split:],
+	}
+
+	// Opening prices come from the statement for the month before
+	openingPrices := make(map[string]decimal.Decimal)
+	prev, err := cfg.DB.GetLatestStatementBefore(ctx, database.GetLatestStatementBeforeParams{UserID: userId, Period: month})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return statements.Statement{}, err
+	}
+	var prevStatement statements.Statement
+	if err == nil && prev.Period.Equal(month.AddDate(0, -1, 0)) && json.Unmarshal(prev.Payload, &prevStatement) == nil {
+		for _, p := range prevStatement.ClosingHoldings {
+			openingPrices[p.StockSymbol] = p.Price
+		}
+	}
+	for _, p := range replayHoldings(txns[:split]) {
+		price, ok := openingPrices[p.StockSymbol]
+		if !ok {
+			s.OpeningValuedAtCost = true
+			price = p.CostBasis.DivRound(p.Quantity, utils.MoneyScale)
+		}
+		p.Price, p.Value = price, utils.Amount(p.Quantity, price)
+		s.OpeningHoldings = append(s.OpeningHoldings, p)
+		s.Summary.OpeningHoldingsValue = s.Summary.OpeningHoldingsValue.Add(p.Value)
+	}
+	for _, p := range replayHoldings(txns) {
+		stonk, err := cfg.DB.GetStockBySymbol(ctx, p.StockSymbol)
+		if err != nil {
+			return statements.Statement{}, err
+		}
+		p.Price, p.Value = stonk.CurrentPrice, utils.Amount(p.Quantity, stonk.CurrentPrice)
+		s.ClosingHoldings = append(s.ClosingHoldings, p)
+		s.Summary.ClosingHoldingsValue = s.Summary.ClosingHoldingsValue.Add(p.Value)
+	}
+
+	// Trades show up as transactions, the other movements are listed on their own
+	movements, err := cfg.DB.GetCashMovementsForUserBetween(ctx, database.GetCashMovementsForUserBetweenParams{
+		UserID:      userId,
+		CreatedAt:   month,
+		CreatedAt_2: end,
+	})
+	if err != nil {
+		return statements.Statement{}, err
+	}
+	for _, m := range movements {
+		switch m.Type {
+		case deposit, withdrawal:
+			s.Summary.NetDeposits = s.Summary.NetDeposits.Add(m.Amount)
+		case dividend:
+			s.Summary.Dividends = s.Summary.Dividends.Add(m.Amount)
+		case fee:
+			s.Summary.Fees = s.Summary.Fees.Add(m.Amount.Neg())
+		default:
+			continue
+		}
+		s.CashMovements = append(s.CashMovements, m)
+	}
+	if s.Summary.OpeningCash, err = cfg.DB.GetCashBalanceBefore(ctx, database.GetCashBalanceBeforeParams{UserID: userId, CreatedAt: month}); err != nil {
+		return statements.Statement{}, err
+	}
+	if s.Summary.ClosingCash, err = cfg.DB.GetCashBalanceBefore(ctx, database.GetCashBalanceBeforeParams{UserID: userId, CreatedAt: end}); err != nil {
+		return statements.Statement{}, err
+	}
+
+	// Performance leaves out deposits and withdrawals, and counts them as in for half the month (simple Dietz)
+	sum := &s.Summary
+	sum.OpeningValue = sum.OpeningHoldingsValue.Add(sum.OpeningCash)
+	sum.ClosingValue = sum.ClosingHoldingsValue.Add(sum.ClosingCash)
+	sum.Performance = sum.ClosingValue.Sub(sum.OpeningValue).Sub(sum.NetDeposits)
+	sum.PerformancePercentage = utils.Percentage(sum.Performance, sum.OpeningValue.Add(sum.NetDeposits.Div(decimal.NewFromInt(2))))
+	return s, nil
+}
+
+// Quantity and cost basis per symbol after the trades, with the same math as ExecuteTransaction
+func replayHoldings(txns []database.Transaction) []statements.Position {
+	var symbols []string
+	positions := make(map[string]statements.Position)
+	for _, txn := range txns {
+		p, ok := positions[txn.StockSymbol]
+		if !ok {
+			p.StockSymbol = txn.StockSymbol
+			symbols = append(symbols, txn.StockSymbol)
+		}
+		switch txn.Type {
+		case buy:
+			p.Quantity, p.CostBasis, _, _, _, _ = utils.HandleBuyTransaction(txn.Quantity, p.Quantity, p.CostBasis, txn.Price)
+		case sell:
+			if txn.Quantity.LessThanOrEqual(p.Quantity) {
+				p.Quantity, p.CostBasis, _, _, _, _ = utils.HandleSellTransaction(txn.Quantity, p.Quantity, p.CostBasis, txn.Price)
+			}
+		case sellShort:
+			p.Quantity, p.CostBasis, _, _, _, _ = utils.HandleShortTransaction(txn.Quantity, p.Quantity, p.CostBasis, txn.Price)
+		case buyToCover:
+			if txn.Quantity.LessThanOrEqual(p.Quantity.Neg()) {
+				p.Quantity, p.CostBasis, _, _, _, _ = utils.HandleCoverTransaction(txn.Quantity, p.Quantity, p.CostBasis, txn.Price)
+			}
+		}
+		positions[txn.StockSymbol] = p
+	}
+
+	slices.Sort(symbols)
+	var res []statements.Position
+	for _, symbol := range symbols {
+		if p := positions[symbol]; !p.Quantity.IsZero() {
+			res = append(res, p)
+		}
+	}
+	return res
+}
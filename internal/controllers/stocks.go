@@ -3,15 +3,76 @@ package controllers
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
+type stockDetailRes struct {
+	Symbol              string              `json:"symbol"`
+	CompanyName         string              `json:"company_name"`
+	AssetClass          string              `json:"asset_class"`
+	CurrentPrice        decimal.Decimal     `json:"current_price"`
+	PreviousClose       decimal.Decimal     `json:"previous_close"`
+	DayChange           decimal.Decimal     `json:"day_change"`
+	DayChangePercentage decimal.Decimal     `json:"day_change_percentage"`
+	DayHigh             decimal.NullDecimal `json:"day_high"`
+	DayLow              decimal.NullDecimal `json:"day_low"`
+	Volume              int64               `json:"volume"`
+	FiftyTwoWeekHigh    decimal.NullDecimal `json:"fifty_two_week_high"`
+	FiftyTwoWeekLow     decimal.NullDecimal `json:"fifty_two_week_low"`
+	UpdatedAt           time.Time           `json:"updated_at"`
+	// Set when the price hasn't been updated in longer than the asset class is polled
+	Stale bool `json:"stale"`
+	// Only for authenticated callers holding the stock
+	Position *positionRes `json:"position,omitempty"`
+}
+
+type positionRes struct {
+	Quantity               decimal.Decimal `json:"quantity"`
+	AveragePrice           decimal.Decimal `json:"average_price"`
+	TotalInvested          decimal.Decimal `json:"total_invested"`
+	CurrentValue           decimal.Decimal `json:"curr_evaluation"`
+	ProfitOrLoss           decimal.Decimal `json:"pnl"`
+	ProfitOrLossPercentage decimal.Decimal `json:"pnl_percentage"`
+	DayChange              decimal.Decimal `json:"day_change"`
+}
+
+// How old a price can get before it's stale, funds only publish one NAV a day
+func staleAfter(assetClass string) time.Duration {
+	if assetClass == config.AssetMutualFund {
+		return 36 * time.Hour
+	}
+	return 5 * time.Minute
+}
+
 func GetStocks(cfg *config.APIConfig) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		// Optional ?asset_class= filter
+		assetClass := strings.ToUpper(ctx.Query("asset_class"))
+		if assetClass != "" && !slices.Contains(config.AssetClasses, assetClass) {
+			respondWithError(ctx, 400, "Invalid asset_class", fmt.Errorf("must be one of %s", strings.Join(config.AssetClasses, "/")))
+			return
+		}
+
 		// just get the stocks from DB
-		stonks, err := cfg.DB.GetAllStocks(ctx)
+		var stonks []database.Stock
+		var err error
+		if assetClass == "" {
+			stonks, err = cfg.DB.GetAllStocks(ctx)
+		} else {
+			stonks, err = cfg.DB.GetAllStocksByAssetClass(ctx, assetClass)
+		}
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				respondWithError(ctx, 404, "No stocks found in DB", err)
@@ -25,26 +86,158 @@ func GetStocks(cfg *config.APIConfig) gin.HandlerFunc {
 	}
 }
 
+// SearchStocks ranks the instrument master against ?q=, exact symbols and ISINs first,
+// then symbol prefixes, then names by how closely they match
 func SearchStocks(cfg *config.APIConfig) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// Bind query param to request
 		req := struct {
-			Query string `form:"q"`
-		}{}
+			Query      string `form:"q"`
+			Exchange   string `form:"exchange"`
+			AssetClass string `form:"asset_class"`
+			Limit      int32  `form:"limit"`
+			Offset     int32  `form:"offset"`
+		}{Limit: 20}
 		err := ctx.ShouldBind(&req)
 		if err != nil {
-			respondWithError(ctx, 500, "error parsing query", err)
+			respondWithError(ctx, 400, "error parsing query", err)
+			return
+		}
+
+		term := strings.ToUpper(strings.TrimSpace(req.Query))
+		if term == "" || len(term) > 100 {
+			respondWithError(ctx, 400, "q must be 1 to 100 characters", nil)
+			return
+		}
+		if req.Limit < 1 || req.Limit > 100 || req.Offset < 0 {
+			respondWithError(ctx, 400, "limit must be 1 to 100 and offset at least 0", nil)
+			return
+		}
+		filter := database.CountInstrumentMatchesParams{
+			Term:    term,
+			Pattern: likeEscaper.Replace(term),
+		}
+		// ?exchange=NSE,BSE
+		for _, exchange := range strings.Split(req.Exchange, ",") {
+			if exchange = strings.ToUpper(strings.TrimSpace(exchange)); exchange != "" {
+				filter.Exchanges = append(filter.Exchanges, exchange)
+			}
+		}
+		if req.AssetClass != "" {
+			assetClass := strings.ToUpper(req.AssetClass)
+			if !slices.Contains(config.AssetClasses, assetClass) {
+				respondWithError(ctx, 400, "Invalid asset_class", fmt.Errorf("must be one of %s", strings.Join(config.AssetClasses, "/")))
+				return
+			}
+			filter.AssetClass = sql.NullString{String: assetClass, Valid: true}
+		}
+
+		// Get matching instruments
+		matches, err := cfg.DB.SearchInstruments(ctx, database.SearchInstrumentsParams{
+			Term:       filter.Term,
+			Pattern:    filter.Pattern,
+			Exchanges:  filter.Exchanges,
+			AssetClass: filter.AssetClass,
+			PageSize:   req.Limit,
+			PageOffset: req.Offset,
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error searching instruments", err)
+			return
+		}
+		total, err := cfg.DB.CountInstrumentMatches(ctx, filter)
+		if err != nil {
+			respondWithError(ctx, 500, "error counting matches", err)
 			return
 		}
 
-		// Get matching stock
-		stonks, err := cfg.DB.SearchStockByName(ctx, sql.NullString{String: req.Query, Valid: true})
+		var nextOffset *int32
+		if next := req.Offset + int32(len(matches)); int64(next) < total {
+			nextOffset = &next
+		}
+		if matches == nil {
+			matches = []database.SearchInstrumentsRow{}
+		}
+
+		// Return matching instruments
+		ctx.JSON(200, gin.H{
+			"results":     matches,
+			"total_count": total,
+			"next_offset": nextOffset,
+		})
+	}
+}
+
+// Makes the term match itself literally in LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// GetStock returns the latest quote for :symbol, with the caller's position when they're signed in
+func GetStock(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization is optional, but a token that's sent has to be valid
+		var userId uuid.UUID
+		if ctx.GetHeader("Authorization") != "" {
+			id, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+			if err != nil {
+				respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+				return
+			}
+			userId = id
+		}
+
+		stonk, err := getOrFetchStock(ctx, cfg, ctx.Param("symbol"))
 		if err != nil {
-			respondWithError(ctx, 404, "No stocks found for this query", err)
+			respondWithStockError(ctx, err)
 			return
 		}
 
-		// Return matching stock
-		ctx.JSON(200, stonks)
+		// no previous close means no change for the day
+		prevClose := stonk.CurrentPrice
+		if stonk.PreviousClose.Valid && stonk.PreviousClose.Decimal.Sign() > 0 {
+			prevClose = stonk.PreviousClose.Decimal
+		}
+		priceChange := stonk.CurrentPrice.Sub(prevClose)
+		res := stockDetailRes{
+			Symbol:              stonk.Symbol,
+			CompanyName:         stonk.CompanyName,
+			AssetClass:          stonk.AssetClass,
+			CurrentPrice:        stonk.CurrentPrice,
+			PreviousClose:       prevClose,
+			DayChange:           priceChange,
+			DayChangePercentage: utils.Percentage(priceChange, prevClose),
+			DayHigh:             stonk.DayHigh,
+			DayLow:              stonk.DayLow,
+			Volume:              stonk.Volume,
+			FiftyTwoWeekHigh:    stonk.FiftyTwoWeekHigh,
+			FiftyTwoWeekLow:     stonk.FiftyTwoWeekLow,
+			UpdatedAt:           stonk.UpdatedAt,
+			Stale:               time.Since(stonk.UpdatedAt) > staleAfter(stonk.AssetClass),
+		}
+
+		if userId != uuid.Nil {
+			holding, err := cfg.DB.GetHoldingByStockSymbol(ctx, database.GetHoldingByStockSymbolParams{
+				UserID:      userId,
+				StockSymbol: stonk.Symbol,
+			})
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				respondWithError(ctx, 500, "error getting position", err)
+				return
+			}
+			if err == nil {
+				currValue := utils.Amount(holding.Quantity, stonk.CurrentPrice)
+				pnl := currValue.Sub(holding.TotalInvested)
+				res.Position = &positionRes{
+					Quantity:               holding.Quantity,
+					AveragePrice:           holding.AveragePrice,
+					TotalInvested:          holding.TotalInvested,
+					CurrentValue:           currValue,
+					ProfitOrLoss:           pnl,
+					ProfitOrLossPercentage: utils.Percentage(pnl, holding.TotalInvested.Abs()),
+					DayChange:              utils.Amount(holding.Quantity, priceChange),
+				}
+			}
+		}
+
+		ctx.JSON(200, res)
 	}
 }
@@ -1,16 +1,18 @@
 package controllers
 
 import (
-	"database/sql"
-	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 )
 
 func CreateTransaction(cfg *config.APIConfig) gin.HandlerFunc {
@@ -27,121 +29,85 @@ func CreateTransaction(cfg *config.APIConfig) gin.HandlerFunc {
 			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
 			return
 		}
+		if !checkEmailVerified(ctx, cfg, userId) {
+			return
+		}
 
 		// Parse request
 		var req struct {
-			StockSymbol string `json:"stock_symbol"`
-			Type        string `json:"type"`
-			Quantity    int    `json:"quantity"`
+			StockSymbol string          `json:"stock_symbol"`
+			Type        string          `json:"type"`
+			Quantity    decimal.Decimal `json:"quantity"`
+			Amount      decimal.Decimal `json:"amount"`
 		}
 		if err := ctx.ShouldBindJSON(&req); err != nil {
 			respondWithError(ctx, http.StatusBadRequest, "Invalid request body", err)
 			return
 		}
-		if req.Quantity <= 0 || (req.Type != buy && req.Type != sell) {
-			respondWithError(ctx, http.StatusBadRequest, "Quantity must be > 0 and type must be BUY/SELL", nil)
+		if req.Type != buy && req.Type != sell && req.Type != sellShort && req.Type != buyToCover {
+			respondWithError(ctx, http.StatusBadRequest, "Type must be BUY/SELL/SELL_SHORT/BUY_TO_COVER", nil)
+			return
+		}
+		// Trade either a quantity or an amount of money worth of the stock
+		if (req.Quantity.Sign() > 0) == (req.Amount.Sign() > 0) || req.Quantity.Sign() < 0 || req.Amount.Sign() < 0 {
+			respondWithError(ctx, http.StatusBadRequest, "Exactly one of quantity or amount must be > 0", nil)
 			return
 		}
 
 		// Get stock info
 		stonk, err := getOrFetchStock(ctx, cfg, req.StockSymbol)
 		if err != nil {
-			respondWithError(ctx, http.StatusInternalServerError, "Failed to resolve stock info", err)
+			respondWithStockError(ctx, err)
 			return
 		}
 
-		// Get current holdings for user
-		currHolding, err := cfg.DB.GetHoldingByStockSymbol(ctx, database.GetHoldingByStockSymbolParams{
-			UserID:      userId,
-			StockSymbol: req.StockSymbol,
-		})
-		isNewHolding := errors.Is(err, sql.ErrNoRows)
-		if err != nil && !isNewHolding {
-			respondWithError(ctx, http.StatusInternalServerError, "Error fetching holdings", err)
-			return
-		}
-
-		// Compute transaction outcome
-		var (
-			newQuantity int
-			newAvg      float64
-			totalAmount float64
-		)
-		if isNewHolding {
-			// Cannot sell if no holdings
-			if req.Type == sell {
-				respondWithError(ctx, http.StatusBadRequest, "Can't sell the stock you don't OWN niga!", nil)
+		quantity := req.Quantity
+		if req.Amount.Sign() > 0 {
+			quantity = utils.QuantityForAmount(req.Amount, stonk.CurrentPrice, stonk.QuantityPrecision)
+			if quantity.Sign() <= 0 {
+				respondWithError(ctx, http.StatusBadRequest, "Amount too small", fmt.Errorf("%s amount buys less than the smallest %s quantity", req.Amount, stonk.Symbol))
 				return
 			}
-			newQuantity, _, newAvg, _, _, totalAmount =
-				utils.HandleBuyTransaction(req.Quantity, 0, 0, stonk.CurrentPrice)
-		} else {
-			switch req.Type {
-			case buy:
-				newQuantity, _, newAvg, _, _, totalAmount =
-					utils.HandleBuyTransaction(req.Quantity, int(currHolding.Quantity), currHolding.AveragePrice, stonk.CurrentPrice)
-				currHolding.TotalInvested += totalAmount
-			case sell:
-				newQuantity, _, newAvg, _, _, totalAmount =
-					utils.HandleSellTransaction(req.Quantity, int(currHolding.Quantity), currHolding.AveragePrice, stonk.CurrentPrice)
-				currHolding.TotalInvested -= totalAmount
-			}
 		}
-
-		// Insert transaction record
-		txn, err := cfg.DB.CreateATransaction(ctx, database.CreateATransactionParams{
-			UserID:      userId,
-			StockSymbol: req.StockSymbol,
-			Type:        req.Type,
-			Quantity:    int32(req.Quantity),
-			Price:       stonk.CurrentPrice,
-			TotalAmount: totalAmount,
-		})
-		if err != nil {
-			respondWithError(ctx, http.StatusInternalServerError, "Failed to create transaction", err)
+		if err := checkQuantityPrecision(quantity, stonk); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid quantity", err)
 			return
 		}
 
-		// Update or remove holding
-		if newQuantity == 0 && !isNewHolding {
-			if _, err := cfg.DB.DeleteHoldingsOnSellOut(ctx, database.DeleteHoldingsOnSellOutParams{
-				UserID:      userId,
-				StockSymbol: req.StockSymbol,
-			}); err != nil {
-				respondWithError(ctx, http.StatusInternalServerError, "Failed to delete holding", err)
+		// Execute the trade, holding update and transaction record commit together
+		var result TradeResult
+		err = withTx(ctx, cfg, func(q *database.Queries) error {
+			result, err = ExecuteTransaction(ctx, q, userId, stonk.Symbol, req.Type, quantity, stonk.CurrentPrice)
+			return err
+		})
+		if err != nil {
+			if IsTradeError(err) {
+				respondWithError(ctx, http.StatusBadRequest, "Invalid transaction", err)
 				return
 			}
-
-			// return the sold out message
-			ctx.JSON(http.StatusCreated, gin.H{"message": fmt.Sprintf("Sold out holdings for %s", req.StockSymbol)})
+			respondWithError(ctx, http.StatusInternalServerError, "Failed to execute transaction", err)
 			return
 		}
 
-		updatedHolding, err := cfg.DB.CreateNewHoldingOrUpdateExistingForUser(ctx,
-			database.CreateNewHoldingOrUpdateExistingForUserParams{
-				UserID:        userId,
-				StockSymbol:   req.StockSymbol,
-				Quantity:      int32(newQuantity),
-				AveragePrice:  newAvg,
-				TotalInvested: currHolding.TotalInvested + totalAmount,
-			})
-		if err != nil {
-			respondWithError(ctx, http.StatusInternalServerError, "Failed to update holdings", err)
+		if result.SoldOut {
+			// return the sold out message
+			ctx.JSON(http.StatusCreated, gin.H{"message": fmt.Sprintf("Sold out holdings for %s", stonk.Symbol)})
 			return
 		}
 
 		// Respond with Transaction and Current HOlding
 		ctx.JSON(http.StatusCreated, gin.H{
-			"Transaction": txn,
-			"Holding":     updatedHolding,
+			"Transaction": result.Transaction,
+			"Holding":     result.Holding,
 		})
 	}
 }
 
+// GetTransactions pages through the user's transactions, filtered and sorted by the query params
 func GetTransactions(cfg *config.APIConfig) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		// Applying rate limiter to limit transactions
-		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "login") {
+		// Applying rate limiter to limit reading transactions
+		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "history") {
 			respondWithError(ctx, http.StatusTooManyRequests, "Wait for some time!", nil)
 			return
 		}
@@ -150,16 +116,56 @@ func GetTransactions(cfg *config.APIConfig) gin.HandlerFunc {
 		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
 		if err != nil {
 			respondWithError(ctx, 401, "Authentication error", err)
+			return
 		}
 
-		// get transactions for userId
-		txns, err := cfg.DB.GetAllTransactionsForUser(ctx, userId)
+		filter, ok := parseHistoryFilter(ctx, userId)
+		if !ok {
+			return
+		}
+		sort := ctx.DefaultQuery("sort", "-created_at")
+		if !slices.Contains(historySorts, sort) {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid sort", fmt.Errorf("must be one of %s", strings.Join(historySorts, "/")))
+			return
+		}
+		limit := defaultHistoryLimit
+		if l := ctx.Query("limit"); l != "" {
+			if limit, err = strconv.Atoi(l); err != nil || limit < 1 || limit > maxHistoryLimit {
+				respondWithError(ctx, http.StatusBadRequest, fmt.Sprintf("limit must be 1 to %d", maxHistoryLimit), err)
+				return
+			}
+		}
+		var cursor *historyCursor
+		if c := ctx.Query("cursor"); c != "" {
+			if cursor, err = decodeHistoryCursor(c, sort); err != nil {
+				respondWithError(ctx, http.StatusBadRequest, "Invalid cursor", err)
+				return
+			}
+		}
+
+		// One extra row tells whether there's a next page
+		txns, err := listTransactions(ctx, cfg.DB, filter, sort, cursor, int32(limit+1))
 		if err != nil {
 			respondWithError(ctx, 500, "error getting transactions", err)
 			return
 		}
+		total, err := cfg.DB.CountTransactions(ctx, database.CountTransactionsParams(filter))
+		if err != nil {
+			respondWithError(ctx, 500, "error counting transactions", err)
+			return
+		}
+
+		nextCursor := ""
+		if len(txns) > limit {
+			txns = txns[:limit]
+			nextCursor = encodeHistoryCursor(txns[limit-1], sort)
+		}
 
-		// return the transactions
-		ctx.JSON(200, txns)
+		// return the page
+		ctx.JSON(200, gin.H{
+			"transactions": txns,
+			"total_count":  total,
+			"next_cursor":  nextCursor,
+		})
 	}
 }
@@ -0,0 +1,434 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/notify"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Shown next to the account in authenticator apps
+	totpIssuer        = "Stocker"
+	recoveryCodeCount = 10
+	// Time between the password and the code at login
+	twoFactorChallengeTTL = 5 * time.Minute
+)
+
+var errInvalidSecondFactor = errors.New("invalid two-factor code")
+
+type twoFactorChallengeRes struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	// Seconds until challenge_token expires
+	ExpiresIn int `json:"expires_in"`
+}
+
+// A code from the authenticator app, or a recovery code when the app is lost
+type secondFactorReq struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// The challenge token stands for a correct password until the code comes in. It's opaque and
+// only lives in redis, so it can't be passed off as an access token.
+func startTwoFactorChallenge(ctx context.Context, cfg *config.APIConfig, userId uuid.UUID) (twoFactorChallengeRes, error) {
+	token, err := auth.MakeToken()
+	if err != nil {
+		return twoFactorChallengeRes{}, err
+	}
+	if err := cfg.RD.Set(ctx, challengeKey(token), userId.String(), twoFactorChallengeTTL).Err(); err != nil {
+		return twoFactorChallengeRes{}, err
+	}
+	return twoFactorChallengeRes{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresIn:         int(twoFactorChallengeTTL.Seconds()),
+	}, nil
+}
+
+func challengeKey(token string) string {
+	return "2fa-challenge:" + auth.HashToken(token)
+}
+
+// Checks the second factor of a user who has it on, responding with failStatus when it's wrong.
+// Each code works once. Attempts are limited per IP and per user, a six digit code doesn't
+// take long to guess otherwise.
+func verifySecondFactor(ctx *gin.Context, cfg *config.APIConfig, userId uuid.UUID, req secondFactorReq, failStatus int) bool {
+	if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "totp") || !cfg.CheckRateLimit(ctx, userId.String(), "totp") {
+		respondWithError(ctx, http.StatusTooManyRequests, "Too many attempts, wait for some time!", nil)
+		return false
+	}
+
+	var n int64
+	var err error
+	if req.RecoveryCode != "" {
+		n, err = cfg.DB.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   userId,
+			CodeHash: auth.HashRecoveryCode(req.RecoveryCode),
+		})
+	} else {
+		var totp database.UserTotp
+		totp, err = cfg.DB.GetTOTP(ctx, userId)
+		if err == nil {
+			if step, ok := auth.ValidateTOTP(totp.Secret, req.Code, time.Now()); ok {
+				n, err = cfg.DB.UseTOTPStep(ctx, database.UseTOTPStepParams{UserID: userId, LastUsedStep: step})
+			}
+		}
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(ctx, 500, "error checking code", err)
+		return false
+	}
+	if n == 0 {
+		respondWithError(ctx, failStatus, "Invalid code", errInvalidSecondFactor)
+		return false
+	}
+	return true
+}
+
+// Swaps the user's recovery codes for a new set, the old ones stop working
+func replaceRecoveryCodes(ctx context.Context, q *database.Queries, userId uuid.UUID) ([]string, error) {
+	codes, err := auth.MakeRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.DeleteRecoveryCodes(ctx, userId); err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		if err := q.CreateRecoveryCode(ctx, database.CreateRecoveryCodeParams{
+			UserID:   userId,
+			CodeHash: auth.HashRecoveryCode(code),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// Reports whether the user has a confirmed authenticator
+func twoFactorEnabled(ctx context.Context, cfg *config.APIConfig, userId uuid.UUID) (bool, error) {
+	totp, err := cfg.DB.GetTOTP(ctx, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil && totp.ConfirmedAt.Valid, err
+}
+
+func enqueueTwoFactorNotification(ctx *gin.Context, cfg *config.APIConfig, userId uuid.UUID, how string) {
+	err := notify.Enqueue(ctx, cfg.RD, notify.Message{
+		UserID:   userId,
+		Kind:     notify.KindSecurity,
+		Template: "two_factor",
+		Subject:  "Two-factor authentication was " + how,
+		Data: map[string]any{
+			"How":       how,
+			"Time":      time.Now().UTC().Format(time.RFC1123),
+			"IP":        ctx.ClientIP(),
+			"UserAgent": ctx.Request.UserAgent(),
+		},
+	})
+	if err != nil {
+		log.Printf("Error queueing two-factor notification: %v\n", err)
+	}
+}
+
+func GetTwoFactor(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		enabled, err := twoFactorEnabled(ctx, cfg, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting two-factor status", err)
+			return
+		}
+		left, err := cfg.DB.CountUnusedRecoveryCodes(ctx, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error counting recovery codes", err)
+			return
+		}
+
+		ctx.JSON(200, gin.H{
+			"enabled":             enabled,
+			"recovery_codes_left": left,
+		})
+	}
+}
+
+// SetupTOTP generates a secret for the user's authenticator app. It isn't used for logins until
+// a code from the app is confirmed, setting up again before that replaces the secret.
+func SetupTOTP(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		user, err := cfg.DB.GetUserByID(ctx, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting user", err)
+			return
+		}
+		secret, err := auth.MakeTOTPSecret()
+		if err != nil {
+			respondWithError(ctx, 500, "error making secret", err)
+			return
+		}
+		_, err = cfg.DB.StartTOTPEnrollment(ctx, database.StartTOTPEnrollmentParams{
+			UserID: userId,
+			Secret: secret,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(ctx, http.StatusConflict, "Two-factor authentication is already on", nil)
+			return
+		}
+		if err != nil {
+			respondWithError(ctx, 500, "error starting two-factor setup", err)
+			return
+		}
+
+		ctx.JSON(200, gin.H{
+			"secret":      secret,
+			"otpauth_uri": auth.TOTPURI(totpIssuer, user.Email, secret),
+		})
+	}
+}
+
+// ConfirmTOTP turns two-factor on once the app shows the right code, and hands out the
+// recovery codes. They are only ever shown here.
+func ConfirmTOTP(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "totp") {
+			respondWithError(ctx, http.StatusTooManyRequests, "Too many attempts, wait for some time!", nil)
+			return
+		}
+
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		req := struct {
+			Code string `json:"code"`
+		}{}
+		if err := ctx.ShouldBindJSON(&req); err != nil || req.Code == "" {
+			respondWithError(ctx, http.StatusBadRequest, "code is required", err)
+			return
+		}
+
+		totp, err := cfg.DB.GetTOTP(ctx, userId)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(ctx, http.StatusConflict, "Set up an authenticator first", nil)
+			return
+		}
+		if err != nil {
+			respondWithError(ctx, 500, "error getting authenticator", err)
+			return
+		}
+		if totp.ConfirmedAt.Valid {
+			respondWithError(ctx, http.StatusConflict, "Two-factor authentication is already on", nil)
+			return
+		}
+		step, ok := auth.ValidateTOTP(totp.Secret, req.Code, time.Now())
+		if !ok {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid code", errInvalidSecondFactor)
+			return
+		}
+
+		var codes []string
+		err = withTx(ctx, cfg, func(q *database.Queries) error {
+			n, err := q.ConfirmTOTP(ctx, database.ConfirmTOTPParams{UserID: userId, LastUsedStep: step})
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return sql.ErrNoRows
+			}
+			codes, err = replaceRecoveryCodes(ctx, q, userId)
+			return err
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(ctx, http.StatusConflict, "Two-factor authentication is already on", nil)
+			return
+		}
+		if err != nil {
+			respondWithError(ctx, 500, "error turning on two-factor authentication", err)
+			return
+		}
+
+		enqueueTwoFactorNotification(ctx, cfg, userId, "turned on")
+		ctx.JSON(200, gin.H{"recovery_codes": codes})
+	}
+}
+
+// DisableTOTP turns two-factor off, it takes the password and a code
+func DisableTOTP(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		req := struct {
+			Password string `json:"password"`
+			secondFactorReq
+		}{}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "error unmarshalling request", err)
+			return
+		}
+
+		enabled, err := twoFactorEnabled(ctx, cfg, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting two-factor status", err)
+			return
+		}
+		if !enabled {
+			respondWithError(ctx, http.StatusConflict, "Two-factor authentication isn't on", nil)
+			return
+		}
+		user, err := cfg.DB.GetUserByID(ctx, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting user", err)
+			return
+		}
+		if err := auth.CheckPasswordHash(req.Password, user.HashedPassword); err != nil {
+			respondWithError(ctx, http.StatusForbidden, "Password doesn't match", err)
+			return
+		}
+		if !verifySecondFactor(ctx, cfg, userId, req.secondFactorReq, http.StatusForbidden) {
+			return
+		}
+
+		err = withTx(ctx, cfg, func(q *database.Queries) error {
+			if err := q.DeleteTOTP(ctx, userId); err != nil {
+				return err
+			}
+			return q.DeleteRecoveryCodes(ctx, userId)
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error turning off two-factor authentication", err)
+			return
+		}
+
+		enqueueTwoFactorNotification(ctx, cfg, userId, "turned off")
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes, for when they run low or got exposed
+func RegenerateRecoveryCodes(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		var req secondFactorReq
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "error unmarshalling request", err)
+			return
+		}
+
+		enabled, err := twoFactorEnabled(ctx, cfg, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting two-factor status", err)
+			return
+		}
+		if !enabled {
+			respondWithError(ctx, http.StatusConflict, "Two-factor authentication isn't on", nil)
+			return
+		}
+		if !verifySecondFactor(ctx, cfg, userId, req, http.StatusForbidden) {
+			return
+		}
+
+		var codes []string
+		err = withTx(ctx, cfg, func(q *database.Queries) error {
+			codes, err = replaceRecoveryCodes(ctx, q, userId)
+			return err
+		})
+		if err != nil {
+			respondWithError(ctx, 500, "error making recovery codes", err)
+			return
+		}
+
+		ctx.JSON(200, gin.H{"recovery_codes": codes})
+	}
+}
+
+// CompleteTwoFactorLogin is the second login step, it takes the challenge token LoginUser
+// gave out and a code, and logs in like LoginUser does for users without two-factor
+func CompleteTwoFactorLogin(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		req := struct {
+			ChallengeToken string `json:"challenge_token"`
+			secondFactorReq
+		}{}
+		if err := ctx.ShouldBindJSON(&req); err != nil || req.ChallengeToken == "" {
+			respondWithError(ctx, http.StatusBadRequest, "challenge_token is required", err)
+			return
+		}
+
+		key := challengeKey(req.ChallengeToken)
+		stored, err := cfg.RD.Get(ctx, key).Result()
+		if errors.Is(err, redis.Nil) {
+			respondWithError(ctx, http.StatusUnauthorized, "Invalid or expired challenge, log in again", nil)
+			return
+		}
+		if err != nil {
+			respondWithError(ctx, 500, "error getting challenge", err)
+			return
+		}
+		userId, err := uuid.Parse(stored)
+		if err != nil {
+			respondWithError(ctx, 500, "error parsing challenge", err)
+			return
+		}
+
+		if !verifySecondFactor(ctx, cfg, userId, req.secondFactorReq, http.StatusUnauthorized) {
+			return
+		}
+		// A challenge logs in once
+		n, err := cfg.RD.Del(ctx, key).Result()
+		if err != nil {
+			respondWithError(ctx, 500, "error ending challenge", err)
+			return
+		}
+		if n == 0 {
+			respondWithError(ctx, http.StatusUnauthorized, "Invalid or expired challenge, log in again", nil)
+			return
+		}
+
+		user, err := cfg.DB.GetUserByID(ctx, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting user", err)
+			return
+		}
+		completeLogin(ctx, cfg, user)
+	}
+}
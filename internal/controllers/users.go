@@ -1,15 +1,17 @@
 package controllers
 
 import (
+	"errors"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/notify"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -35,6 +37,12 @@ func CreateUser(cfg *config.APIConfig) gin.HandlerFunc {
 			return
 		}
 
+		req.Email = utils.NormalizeEmail(req.Email)
+		if err := utils.ValidateEmail(req.Email); err != nil {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid email address", err)
+			return
+		}
+
 		// hashing the text password from req
 		hashedPass, err := auth.HashPassword(req.Password)
 		if err != nil {
@@ -49,7 +57,7 @@ func CreateUser(cfg *config.APIConfig) gin.HandlerFunc {
 			HashedPassword: hashedPass,
 		})
 		if err != nil {
-			if strings.Contains(err.Error(), "duplicate key value violates unique constraint \"users_email_key\"") {
+			if strings.Contains(err.Error(), "duplicate key value violates unique constraint \"users_email_lower_key\"") {
 				respondWithError(ctx, 401, "User already exists", err)
 				return
 			}
@@ -57,17 +65,24 @@ func CreateUser(cfg *config.APIConfig) gin.HandlerFunc {
 			return
 		}
 
+		// The user can log in right away, the policy decides whether they can trade before verifying
+		if err := sendVerificationEmail(ctx, cfg, user.ID); err != nil {
+			log.Printf("Error sending verification email: %v\n", err)
+		}
+
 		// Creating response
 		res := struct {
-			ID        uuid.UUID `json:"id"`
-			Name      string    `json:"name"`
-			CreatedAt time.Time `json:"created_at"`
-			Email     string    `json:"email"`
+			ID            uuid.UUID `json:"id"`
+			Name          string    `json:"name"`
+			CreatedAt     time.Time `json:"created_at"`
+			Email         string    `json:"email"`
+			EmailVerified bool      `json:"email_verified"`
 		}{
-			ID:        user.ID,
-			Name:      user.Name,
-			CreatedAt: user.CreatedAt,
-			Email:     user.Email,
+			ID:            user.ID,
+			Name:          user.Name,
+			CreatedAt:     user.CreatedAt,
+			Email:         user.Email,
+			EmailVerified: user.VerifiedAt.Valid,
 		}
 
 		// Respond with user data
@@ -96,7 +111,7 @@ func LoginUser(cfg *config.APIConfig) gin.HandlerFunc {
 		}
 
 		// Get User by Email
-		user, err := cfg.DB.GetUserByEmail(ctx, req.Email)
+		user, err := cfg.DB.GetUserByEmail(ctx, utils.NormalizeEmail(req.Email))
 		if err != nil {
 			respondWithError(ctx, 401, "user with email not found", err)
 			return
@@ -108,47 +123,76 @@ func LoginUser(cfg *config.APIConfig) gin.HandlerFunc {
 			respondWithError(ctx, 401, "email and password doesn't match", err)
 			return
 		}
+		if user.DisabledAt.Valid {
+			respondWithError(ctx, http.StatusForbidden, "Account is disabled", errAccountDisabled)
+			return
+		}
 
-		// Create the Access Token
-		token, err := auth.MakeJWT(user.ID, cfg.JWTSecret, 3600*time.Second)
+		// With two-factor on the password only gets a challenge, the code finishes the login
+		twoFactor, err := twoFactorEnabled(ctx, cfg, user.ID)
 		if err != nil {
-			respondWithError(ctx, 500, "error making token", err)
+			respondWithError(ctx, 500, "error getting two-factor status", err)
 			return
 		}
-
-		// Creating response and responding
-		res := struct {
-			ID        uuid.UUID `json:"id"`
-			CreatedAt time.Time `json:"created_at"`
-			Email     string    `json:"email"`
-			Name      string    `json:"name"`
-			Token     string    `json:"token"`
-		}{
-			ID:        user.ID,
-			CreatedAt: user.CreatedAt,
-			Email:     user.Email,
-			Name:      user.Name,
-			Token:     token,
+		if twoFactor {
+			challenge, err := startTwoFactorChallenge(ctx, cfg, user.ID)
+			if err != nil {
+				respondWithError(ctx, 500, "error starting two-factor challenge", err)
+				return
+			}
+			ctx.JSON(200, challenge)
+			return
 		}
 
-		ctx.JSON(200, res)
+		completeLogin(ctx, cfg, user)
 	}
 }
 
-func DeleteUsers(cfg *config.APIConfig) gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		if os.Getenv("PLATFORM") != "dev" {
-			ctx.Writer.WriteHeader(403)
-			return
-		}
+// Starts a session for a user who proved who they are and responds like LoginUser
+func completeLogin(ctx *gin.Context, cfg *config.APIConfig, user database.User) {
+	// Create the Access and Refresh Tokens for a new session
+	tokens, err := startSession(ctx, cfg, user.ID)
+	if errors.Is(err, errAccountDisabled) {
+		respondWithError(ctx, http.StatusForbidden, "Account is disabled", err)
+		return
+	}
+	if err != nil {
+		respondWithError(ctx, 500, "error making token", err)
+		return
+	}
 
-		err := cfg.DB.DeleteAllUsers(ctx)
-		if err != nil {
-			respondWithError(ctx, 500, "error deleting all users", err)
-			return
-		}
-		log.Println("All Users Deleted Successfully.")
+	// Security email for the new login, queued so it never slows the login down
+	err = notify.Enqueue(ctx, cfg.RD, notify.Message{
+		UserID:   user.ID,
+		Kind:     notify.KindSecurity,
+		Template: "new_login",
+		Subject:  "New sign-in to your account",
+		Data: map[string]any{
+			"Time":      time.Now().UTC().Format(time.RFC1123),
+			"IP":        ctx.ClientIP(),
+			"UserAgent": ctx.Request.UserAgent(),
+		},
+	})
+	if err != nil {
+		log.Printf("Error queueing login notification: %v\n", err)
+	}
 
-		ctx.Writer.WriteHeader(200)
+	// Creating response and responding
+	res := struct {
+		ID            uuid.UUID `json:"id"`
+		CreatedAt     time.Time `json:"created_at"`
+		Email         string    `json:"email"`
+		Name          string    `json:"name"`
+		EmailVerified bool      `json:"email_verified"`
+		tokensRes
+	}{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		Email:         user.Email,
+		Name:          user.Name,
+		EmailVerified: user.VerifiedAt.Valid,
+		tokensRes:     tokens,
 	}
+
+	ctx.JSON(200, res)
 }
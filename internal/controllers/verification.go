@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/notify"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const emailVerificationTTL = 48 * time.Hour
+
+var errEmailNotVerified = errors.New("email address isn't verified")
+
+// Emails a new verification link, the ones sent before stop working
+func sendVerificationEmail(ctx context.Context, cfg *config.APIConfig, userId uuid.UUID) error {
+	token, err := auth.MakeToken()
+	if err != nil {
+		return err
+	}
+	err = withTx(ctx, cfg, func(q *database.Queries) error {
+		if err := q.ExpireEmailVerificationTokens(ctx, userId); err != nil {
+			return err
+		}
+		_, err := q.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+			UserID:    userId,
+			TokenHash: auth.HashToken(token),
+			ExpiresAt: time.Now().UTC().Add(emailVerificationTTL),
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	link := ""
+	if cfg.AppURL != "" {
+		link = cfg.AppURL + "/verify-email?token=" + token
+	}
+	return notify.Enqueue(ctx, cfg.RD, notify.Message{
+		UserID:   userId,
+		Kind:     notify.KindAccount,
+		Template: "verify_email",
+		Subject:  "Verify your email address",
+		Data: map[string]any{
+			"Token":     token,
+			"Link":      link,
+			"ExpiresIn": fmt.Sprintf("%d hours", int(emailVerificationTTL.Hours())),
+		},
+	})
+}
+
+// Responds with 403 when cfg.RequireVerifiedEmail is on and the user hasn't verified yet
+func checkEmailVerified(ctx *gin.Context, cfg *config.APIConfig, userId uuid.UUID) bool {
+	if !cfg.RequireVerifiedEmail {
+		return true
+	}
+	user, err := cfg.DB.GetUserByID(ctx, userId)
+	if err != nil {
+		respondWithError(ctx, 500, "error getting user", err)
+		return false
+	}
+	if !user.VerifiedAt.Valid {
+		respondWithError(ctx, http.StatusForbidden, "Verify your email address before trading", errEmailNotVerified)
+		return false
+	}
+	return true
+}
+
+// VerifyEmail marks the email verified with the token from a verification link
+func VerifyEmail(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "verify_email") {
+			respondWithError(ctx, http.StatusTooManyRequests, "Wait for some time!", nil)
+			return
+		}
+
+		req := struct {
+			Token string `json:"token"`
+		}{}
+		if err := ctx.ShouldBindJSON(&req); err != nil || req.Token == "" {
+			respondWithError(ctx, http.StatusBadRequest, "token is required", err)
+			return
+		}
+
+		err := withTx(ctx, cfg, func(q *database.Queries) error {
+			userId, err := q.UseEmailVerificationToken(ctx, database.UseEmailVerificationTokenParams{
+				TokenHash: auth.HashToken(req.Token),
+				ExpiresAt: time.Now().UTC(),
+			})
+			if err != nil {
+				return err
+			}
+			return q.MarkUserVerified(ctx, userId)
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(ctx, http.StatusBadRequest, "Invalid or expired verification token", nil)
+			return
+		}
+		if err != nil {
+			respondWithError(ctx, 500, "error verifying email", err)
+			return
+		}
+
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// ResendVerification sends the logged in user a new verification link
+func ResendVerification(cfg *config.APIConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !cfg.CheckRateLimit(ctx, ctx.ClientIP(), "verification_resend") {
+			respondWithError(ctx, http.StatusTooManyRequests, "Verification Quota Expired", nil)
+			return
+		}
+
+		// Authorization required for this route
+		userId, err := auth.GetUserID(ctx.Request.Header, cfg.JWTSecret)
+		if err != nil {
+			respondWithError(ctx, http.StatusUnauthorized, "Authentication error", err)
+			return
+		}
+
+		user, err := cfg.DB.GetUserByID(ctx, userId)
+		if err != nil {
+			respondWithError(ctx, 500, "error getting user", err)
+			return
+		}
+		if user.VerifiedAt.Valid {
+			respondWithError(ctx, http.StatusConflict, "Email already verified", nil)
+			return
+		}
+
+		if err := sendVerificationEmail(ctx, cfg, userId); err != nil {
+			respondWithError(ctx, 500, "error sending verification email", err)
+			return
+		}
+		ctx.Status(http.StatusAccepted)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 const createNewHoldingOrUpdateExistingForUser = `-- name: CreateNewHoldingOrUpdateExistingForUser :one
@@ -33,11 +34,11 @@ RETURNING id, user_id, stock_symbol, quantity, average_price, created_at, update
 `
 
 type CreateNewHoldingOrUpdateExistingForUserParams struct {
-	UserID        uuid.UUID `json:"user_id"`
-	StockSymbol   string    `json:"stock_symbol"`
-	Quantity      int32     `json:"quantity"`
-	AveragePrice  float64   `json:"average_price"`
-	TotalInvested float64   `json:"total_invested"`
+	UserID        uuid.UUID       `json:"user_id"`
+	StockSymbol   string          `json:"stock_symbol"`
+	Quantity      decimal.Decimal `json:"quantity"`
+	AveragePrice  decimal.Decimal `json:"average_price"`
+	TotalInvested decimal.Decimal `json:"total_invested"`
 }
 
 func (q *Queries) CreateNewHoldingOrUpdateExistingForUser(ctx context.Context, arg CreateNewHoldingOrUpdateExistingForUserParams) (Holding, error) {
@@ -87,7 +88,9 @@ SELECT
     holdings.quantity AS quantity,
     holdings.average_price AS average_price,
     stocks.current_price AS current_price,
-    holdings.total_invested AS total_invested
+    holdings.total_invested AS total_invested,
+    stocks.previous_close AS previous_close,
+    stocks.asset_class AS asset_class
 FROM holdings
 JOIN stocks
 ON holdings.stock_symbol = stocks.symbol
@@ -95,12 +98,14 @@ WHERE holdings.user_id = $1
 `
 
 type GetAllHoldingsForUserRow struct {
-	StockSymbol   string  `json:"stock_symbol"`
-	CompanyName   string  `json:"company_name"`
-	Quantity      int32   `json:"quantity"`
-	AveragePrice  float64 `json:"average_price"`
-	CurrentPrice  float64 `json:"current_price"`
-	TotalInvested float64 `json:"total_invested"`
+	StockSymbol   string              `json:"stock_symbol"`
+	CompanyName   string              `json:"company_name"`
+	Quantity      decimal.Decimal     `json:"quantity"`
+	AveragePrice  decimal.Decimal     `json:"average_price"`
+	CurrentPrice  decimal.Decimal     `json:"current_price"`
+	TotalInvested decimal.Decimal     `json:"total_invested"`
+	PreviousClose decimal.NullDecimal `json:"previous_close"`
+	AssetClass    string              `json:"asset_class"`
 }
 
 func (q *Queries) GetAllHoldingsForUser(ctx context.Context, userID uuid.UUID) ([]GetAllHoldingsForUserRow, error) {
@@ -119,6 +124,8 @@ func (q *Queries) GetAllHoldingsForUser(ctx context.Context, userID uuid.UUID) (
 			&i.AveragePrice,
 			&i.CurrentPrice,
 			&i.TotalInvested,
+			&i.PreviousClose,
+			&i.AssetClass,
 		); err != nil {
 			return nil, err
 		}
@@ -159,6 +166,34 @@ func (q *Queries) GetHoldingByStockSymbol(ctx context.Context, arg GetHoldingByS
 	return i, err
 }
 
+const getHoldingByStockSymbolForUpdate = `-- name: GetHoldingByStockSymbolForUpdate :one
+-- a trade's read, the row stays locked until its transaction ends
+SELECT id, user_id, stock_symbol, quantity, average_price, created_at, updated_at, total_invested FROM holdings
+WHERE user_id = $1 AND stock_symbol = $2
+FOR UPDATE
+`
+
+type GetHoldingByStockSymbolForUpdateParams struct {
+	UserID      uuid.UUID `json:"user_id"`
+	StockSymbol string    `json:"stock_symbol"`
+}
+
+func (q *Queries) GetHoldingByStockSymbolForUpdate(ctx context.Context, arg GetHoldingByStockSymbolForUpdateParams) (Holding, error) {
+	row := q.db.QueryRowContext(ctx, getHoldingByStockSymbolForUpdate, arg.UserID, arg.StockSymbol)
+	var i Holding
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.StockSymbol,
+		&i.Quantity,
+		&i.AveragePrice,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TotalInvested,
+	)
+	return i, err
+}
+
 const getStockSymbolsForUser = `-- name: GetStockSymbolsForUser :many
 SELECT stock_symbol FROM holdings
 WHERE user_id = $1
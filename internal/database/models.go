@@ -6,45 +6,297 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
+type AdminAuditLog struct {
+	ID           uuid.UUID       `json:"id"`
+	ActorID      uuid.UUID       `json:"actor_id"`
+	ActorRole    string          `json:"actor_role"`
+	Action       string          `json:"action"`
+	TargetUserID uuid.NullUUID   `json:"target_user_id"`
+	Details      json.RawMessage `json:"details"`
+	Ip           string          `json:"ip"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+type ApiKey struct {
+	ID         uuid.UUID    `json:"id"`
+	UserID     uuid.UUID    `json:"user_id"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	KeyHash    string       `json:"key_hash"`
+	Scopes     []string     `json:"scopes"`
+	ExpiresAt  sql.NullTime `json:"expires_at"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+}
+
+type CashMovement struct {
+	ID            uuid.UUID       `json:"id"`
+	UserID        uuid.UUID       `json:"user_id"`
+	Type          string          `json:"type"`
+	Amount        decimal.Decimal `json:"amount"`
+	TransactionID uuid.NullUUID   `json:"transaction_id"`
+	Note          string          `json:"note"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+type Digest struct {
+	ID             uuid.UUID       `json:"id"`
+	UserID         uuid.UUID       `json:"user_id"`
+	Period         string          `json:"period"`
+	PeriodStart    time.Time       `json:"period_start"`
+	PeriodEnd      time.Time       `json:"period_end"`
+	PortfolioValue decimal.Decimal `json:"portfolio_value"`
+	ValueChange    decimal.Decimal `json:"value_change"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type EmailVerificationToken struct {
+	ID        uuid.UUID    `json:"id"`
+	UserID    uuid.UUID    `json:"user_id"`
+	TokenHash string       `json:"token_hash"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	CreatedAt time.Time    `json:"created_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
 type Holding struct {
-	ID            uuid.UUID `json:"id"`
-	UserID        uuid.UUID `json:"user_id"`
-	StockSymbol   string    `json:"stock_symbol"`
-	Quantity      int32     `json:"quantity"`
-	AveragePrice  float64   `json:"average_price"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
-	TotalInvested float64   `json:"total_invested"`
+	ID            uuid.UUID       `json:"id"`
+	UserID        uuid.UUID       `json:"user_id"`
+	StockSymbol   string          `json:"stock_symbol"`
+	Quantity      decimal.Decimal `json:"quantity"`
+	AveragePrice  decimal.Decimal `json:"average_price"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	TotalInvested decimal.Decimal `json:"total_invested"`
+}
+
+type ImportProfile struct {
+	ID        uuid.UUID       `json:"id"`
+	UserID    uuid.UUID       `json:"user_id"`
+	Name      string          `json:"name"`
+	Mapping   json.RawMessage `json:"mapping"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type Instrument struct {
+	Symbol     string    `json:"symbol"`
+	Name       string    `json:"name"`
+	Exchange   string    `json:"exchange"`
+	Isin       string    `json:"isin"`
+	Currency   string    `json:"currency"`
+	AssetClass string    `json:"asset_class"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type InvestmentPlan struct {
+	ID          uuid.UUID           `json:"id"`
+	UserID      uuid.UUID           `json:"user_id"`
+	StockSymbol string              `json:"stock_symbol"`
+	Amount      decimal.NullDecimal `json:"amount"`
+	Quantity    decimal.NullDecimal `json:"quantity"`
+	Frequency   string              `json:"frequency"`
+	StartDate   time.Time           `json:"start_date"`
+	EndDate     sql.NullTime        `json:"end_date"`
+	NextRunDate time.Time           `json:"next_run_date"`
+	Status      string              `json:"status"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+type ManualAsset struct {
+	ID           uuid.UUID           `json:"id"`
+	UserID       uuid.UUID           `json:"user_id"`
+	Name         string              `json:"name"`
+	AssetType    string              `json:"asset_type"`
+	CostBasis    decimal.Decimal     `json:"cost_basis"`
+	Value        decimal.Decimal     `json:"value"`
+	ValuedOn     time.Time           `json:"valued_on"`
+	InterestRate decimal.NullDecimal `json:"interest_rate"`
+	Compounding  sql.NullString      `json:"compounding"`
+	CreatedAt    time.Time           `json:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at"`
+}
+
+type ManualAssetValuation struct {
+	ID        uuid.UUID       `json:"id"`
+	AssetID   uuid.UUID       `json:"asset_id"`
+	Value     decimal.Decimal `json:"value"`
+	ValuedOn  time.Time       `json:"valued_on"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type MarginAccount struct {
+	UserID              uuid.UUID           `json:"user_id"`
+	ShortSellingEnabled bool                `json:"short_selling_enabled"`
+	MarginRequirement   decimal.Decimal     `json:"margin_requirement"`
+	MaxShortValue       decimal.NullDecimal `json:"max_short_value"`
+	UpdatedAt           time.Time           `json:"updated_at"`
+}
+
+type MarketHoliday struct {
+	Market      string    `json:"market"`
+	HolidayDate time.Time `json:"holiday_date"`
+	Description string    `json:"description"`
+}
+
+type NotificationLog struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Channel   string    `json:"channel"`
+	Kind      string    `json:"kind"`
+	Template  string    `json:"template"`
+	Subject   string    `json:"subject"`
+	SentAt    time.Time `json:"sent_at"`
+	MessageID string    `json:"message_id"`
+}
+
+type NotificationPreference struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Channel   string    `json:"channel"`
+	Kind      string    `json:"kind"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Order struct {
+	ID            uuid.UUID           `json:"id"`
+	UserID        uuid.UUID           `json:"user_id"`
+	StockSymbol   string              `json:"stock_symbol"`
+	Side          string              `json:"side"`
+	OrderType     string              `json:"order_type"`
+	Quantity      decimal.Decimal     `json:"quantity"`
+	LimitPrice    decimal.NullDecimal `json:"limit_price"`
+	StopPrice     decimal.NullDecimal `json:"stop_price"`
+	TimeInForce   string              `json:"time_in_force"`
+	Status        string              `json:"status"`
+	TransactionID uuid.NullUUID       `json:"transaction_id"`
+	ExpiresAt     sql.NullTime        `json:"expires_at"`
+	CreatedAt     time.Time           `json:"created_at"`
+	UpdatedAt     time.Time           `json:"updated_at"`
+	FilledAt      sql.NullTime        `json:"filled_at"`
+}
+
+type PasswordResetToken struct {
+	ID        uuid.UUID    `json:"id"`
+	UserID    uuid.UUID    `json:"user_id"`
+	TokenHash string       `json:"token_hash"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	CreatedAt time.Time    `json:"created_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type PlanExecution struct {
+	ID            uuid.UUID     `json:"id"`
+	PlanID        uuid.UUID     `json:"plan_id"`
+	ScheduledDate time.Time     `json:"scheduled_date"`
+	Status        string        `json:"status"`
+	TransactionID uuid.NullUUID `json:"transaction_id"`
+	Reason        string        `json:"reason"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID    `json:"id"`
+	UserID    uuid.UUID    `json:"user_id"`
+	CodeHash  string       `json:"code_hash"`
+	CreatedAt time.Time    `json:"created_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type RefreshToken struct {
+	ID        uuid.UUID    `json:"id"`
+	UserID    uuid.UUID    `json:"user_id"`
+	FamilyID  uuid.UUID    `json:"family_id"`
+	TokenHash string       `json:"token_hash"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	CreatedAt time.Time    `json:"created_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+	RevokedAt sql.NullTime `json:"revoked_at"`
+}
+
+type Session struct {
+	ID         uuid.UUID    `json:"id"`
+	UserID     uuid.UUID    `json:"user_id"`
+	UserAgent  string       `json:"user_agent"`
+	Ip         string       `json:"ip"`
+	CreatedAt  time.Time    `json:"created_at"`
+	LastUsedAt time.Time    `json:"last_used_at"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+}
+
+type Statement struct {
+	ID        uuid.UUID       `json:"id"`
+	UserID    uuid.UUID       `json:"user_id"`
+	Period    time.Time       `json:"period"`
+	Payload   json.RawMessage `json:"payload"`
+	Html      string          `json:"html"`
+	Pdf       []byte          `json:"pdf"`
+	CreatedAt time.Time       `json:"created_at"`
 }
 
 type Stock struct {
-	Symbol        string          `json:"symbol"`
-	CompanyName   string          `json:"company_name"`
-	CurrentPrice  float64         `json:"current_price"`
-	PreviousClose sql.NullFloat64 `json:"previous_close"`
-	UpdatedAt     time.Time       `json:"updated_at"`
+	Symbol            string              `json:"symbol"`
+	CompanyName       string              `json:"company_name"`
+	CurrentPrice      decimal.Decimal     `json:"current_price"`
+	PreviousClose     decimal.NullDecimal `json:"previous_close"`
+	UpdatedAt         time.Time           `json:"updated_at"`
+	QuantityPrecision int32               `json:"quantity_precision"`
+	AssetClass        string              `json:"asset_class"`
+	DayHigh           decimal.NullDecimal `json:"day_high"`
+	DayLow            decimal.NullDecimal `json:"day_low"`
+	Volume            int64               `json:"volume"`
+	FiftyTwoWeekHigh  decimal.NullDecimal `json:"fifty_two_week_high"`
+	FiftyTwoWeekLow   decimal.NullDecimal `json:"fifty_two_week_low"`
 }
 
 type Transaction struct {
+	ID          uuid.UUID       `json:"id"`
+	UserID      uuid.UUID       `json:"user_id"`
+	StockSymbol string          `json:"stock_symbol"`
+	Type        string          `json:"type"`
+	Quantity    decimal.Decimal `json:"quantity"`
+	Price       decimal.Decimal `json:"price"`
+	TotalAmount decimal.Decimal `json:"total_amount"`
+	CreatedAt   time.Time       `json:"created_at"`
+	ExternalID  string          `json:"external_id"`
+}
+
+type User struct {
+	ID             uuid.UUID    `json:"id"`
+	Email          string       `json:"email"`
+	Name           string       `json:"name"`
+	CreatedAt      time.Time    `json:"created_at"`
+	HashedPassword string       `json:"hashed_password"`
+	VerifiedAt     sql.NullTime `json:"verified_at"`
+	Role           string       `json:"role"`
+	DisabledAt     sql.NullTime `json:"disabled_at"`
+}
+
+type UserIdentity struct {
 	ID          uuid.UUID `json:"id"`
 	UserID      uuid.UUID `json:"user_id"`
-	StockSymbol string    `json:"stock_symbol"`
-	Type        string    `json:"type"`
-	Quantity    int32     `json:"quantity"`
-	Price       float64   `json:"price"`
-	TotalAmount float64   `json:"total_amount"`
+	Issuer      string    `json:"issuer"`
+	Subject     string    `json:"subject"`
+	Email       string    `json:"email"`
 	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
 }
 
-type User struct {
-	ID             uuid.UUID `json:"id"`
-	Email          string    `json:"email"`
-	Name           string    `json:"name"`
-	CreatedAt      time.Time `json:"created_at"`
-	HashedPassword string    `json:"hashed_password"`
+type UserTotp struct {
+	UserID       uuid.UUID    `json:"user_id"`
+	Secret       string       `json:"secret"`
+	ConfirmedAt  sql.NullTime `json:"confirmed_at"`
+	LastUsedStep int64        `json:"last_used_step"`
+	CreatedAt    time.Time    `json:"created_at"`
 }
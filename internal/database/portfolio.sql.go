@@ -9,12 +9,53 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
+const getMarketValueByAssetClass = `-- name: GetMarketValueByAssetClass :many
+SELECT
+    stocks.asset_class AS asset_class,
+    SUM(holdings.quantity * stocks.current_price)::NUMERIC AS value
+FROM holdings
+JOIN stocks
+ON holdings.stock_symbol = stocks.symbol
+WHERE holdings.user_id = $1
+GROUP BY stocks.asset_class
+ORDER BY stocks.asset_class
+`
+
+type GetMarketValueByAssetClassRow struct {
+	AssetClass string          `json:"asset_class"`
+	Value      decimal.Decimal `json:"value"`
+}
+
+func (q *Queries) GetMarketValueByAssetClass(ctx context.Context, userID uuid.UUID) ([]GetMarketValueByAssetClassRow, error) {
+	rows, err := q.db.QueryContext(ctx, getMarketValueByAssetClass, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetMarketValueByAssetClassRow
+	for rows.Next() {
+		var i GetMarketValueByAssetClassRow
+		if err := rows.Scan(&i.AssetClass, &i.Value); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPortfolioForUser = `-- name: GetPortfolioForUser :one
 SELECT 
-        SUM(holdings.total_invested)::DOUBLE PRECISION AS total_invested,
-        SUM(holdings.quantity * stocks.current_price)::DOUBLE PRECISION AS current_value,
+        SUM(holdings.total_invested)::NUMERIC AS total_invested,
+        SUM(holdings.quantity * stocks.current_price)::NUMERIC AS current_value,
         COUNT(holdings.user_id) AS holdings_count
 FROM holdings 
 JOIN users
@@ -26,9 +67,9 @@ GROUP BY holdings.user_id
 `
 
 type GetPortfolioForUserRow struct {
-	TotalInvested float64 `json:"total_invested"`
-	CurrentValue  float64 `json:"current_value"`
-	HoldingsCount int64   `json:"holdings_count"`
+	TotalInvested decimal.Decimal `json:"total_invested"`
+	CurrentValue  decimal.Decimal `json:"current_value"`
+	HoldingsCount int64           `json:"holdings_count"`
 }
 
 func (q *Queries) GetPortfolioForUser(ctx context.Context, id uuid.UUID) (GetPortfolioForUserRow, error) {
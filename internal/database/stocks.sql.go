@@ -7,32 +7,58 @@ package database
 
 import (
 	"context"
-	"database/sql"
+
+	"github.com/shopspring/decimal"
 )
 
 const createNewStockOrUpdateExisting = `-- name: CreateNewStockOrUpdateExisting :one
-INSERT INTO stocks(symbol, company_name, current_price, previous_close, updated_at)
+INSERT INTO stocks(symbol, company_name, current_price, previous_close, updated_at, quantity_precision, asset_class, day_high, day_low, volume, fifty_two_week_high, fifty_two_week_low)
 VALUES (
     $1,
     $2,
     $3,
     $4,
-    NOW()
+    NOW(),
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10,
+    $11
 )
 ON CONFLICT (symbol) DO UPDATE
 SET 
     company_name = EXCLUDED.company_name,
     current_price = EXCLUDED.current_price,
     previous_close = EXCLUDED.previous_close,
-    updated_at = NOW()
-RETURNING symbol, company_name, current_price, previous_close, updated_at
+    updated_at = NOW(),
+    -- a reclassified stock takes the new class's default precision, otherwise a tuned precision is kept
+    quantity_precision = CASE
+        WHEN stocks.asset_class <> EXCLUDED.asset_class THEN EXCLUDED.quantity_precision
+        ELSE stocks.quantity_precision
+    END,
+    asset_class = EXCLUDED.asset_class,
+    day_high = EXCLUDED.day_high,
+    day_low = EXCLUDED.day_low,
+    volume = EXCLUDED.volume,
+    fifty_two_week_high = EXCLUDED.fifty_two_week_high,
+    fifty_two_week_low = EXCLUDED.fifty_two_week_low
+RETURNING symbol, company_name, current_price, previous_close, updated_at, quantity_precision, asset_class, day_high, day_low, volume, fifty_two_week_high, fifty_two_week_low
 `
 
 type CreateNewStockOrUpdateExistingParams struct {
-	Symbol        string          `json:"symbol"`
-	CompanyName   string          `json:"company_name"`
-	CurrentPrice  float64         `json:"current_price"`
-	PreviousClose sql.NullFloat64 `json:"previous_close"`
+	Symbol            string              `json:"symbol"`
+	CompanyName       string              `json:"company_name"`
+	CurrentPrice      decimal.Decimal     `json:"current_price"`
+	PreviousClose     decimal.NullDecimal `json:"previous_close"`
+	QuantityPrecision int32               `json:"quantity_precision"`
+	AssetClass        string              `json:"asset_class"`
+	DayHigh           decimal.NullDecimal `json:"day_high"`
+	DayLow            decimal.NullDecimal `json:"day_low"`
+	Volume            int64               `json:"volume"`
+	FiftyTwoWeekHigh  decimal.NullDecimal `json:"fifty_two_week_high"`
+	FiftyTwoWeekLow   decimal.NullDecimal `json:"fifty_two_week_low"`
 }
 
 func (q *Queries) CreateNewStockOrUpdateExisting(ctx context.Context, arg CreateNewStockOrUpdateExistingParams) (Stock, error) {
@@ -41,6 +67,13 @@ func (q *Queries) CreateNewStockOrUpdateExisting(ctx context.Context, arg Create
 		arg.CompanyName,
 		arg.CurrentPrice,
 		arg.PreviousClose,
+		arg.QuantityPrecision,
+		arg.AssetClass,
+		arg.DayHigh,
+		arg.DayLow,
+		arg.Volume,
+		arg.FiftyTwoWeekHigh,
+		arg.FiftyTwoWeekLow,
 	)
 	var i Stock
 	err := row.Scan(
@@ -49,12 +82,19 @@ func (q *Queries) CreateNewStockOrUpdateExisting(ctx context.Context, arg Create
 		&i.CurrentPrice,
 		&i.PreviousClose,
 		&i.UpdatedAt,
+		&i.QuantityPrecision,
+		&i.AssetClass,
+		&i.DayHigh,
+		&i.DayLow,
+		&i.Volume,
+		&i.FiftyTwoWeekHigh,
+		&i.FiftyTwoWeekLow,
 	)
 	return i, err
 }
 
 const getAllStocks = `-- name: GetAllStocks :many
-SELECT symbol, company_name, current_price, previous_close, updated_at FROM stocks
+SELECT symbol, company_name, current_price, previous_close, updated_at, quantity_precision, asset_class, day_high, day_low, volume, fifty_two_week_high, fifty_two_week_low FROM stocks
 ORDER BY updated_at DESC
 LIMIT 10
 `
@@ -74,6 +114,56 @@ func (q *Queries) GetAllStocks(ctx context.Context) ([]Stock, error) {
 			&i.CurrentPrice,
 			&i.PreviousClose,
 			&i.UpdatedAt,
+			&i.QuantityPrecision,
+			&i.AssetClass,
+			&i.DayHigh,
+			&i.DayLow,
+			&i.Volume,
+			&i.FiftyTwoWeekHigh,
+			&i.FiftyTwoWeekLow,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllStocksByAssetClass = `-- name: GetAllStocksByAssetClass :many
+SELECT symbol, company_name, current_price, previous_close, updated_at, quantity_precision, asset_class, day_high, day_low, volume, fifty_two_week_high, fifty_two_week_low FROM stocks
+WHERE asset_class = $1
+ORDER BY updated_at DESC
+LIMIT 10
+`
+
+func (q *Queries) GetAllStocksByAssetClass(ctx context.Context, assetClass string) ([]Stock, error) {
+	rows, err := q.db.QueryContext(ctx, getAllStocksByAssetClass, assetClass)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Stock
+	for rows.Next() {
+		var i Stock
+		if err := rows.Scan(
+			&i.Symbol,
+			&i.CompanyName,
+			&i.CurrentPrice,
+			&i.PreviousClose,
+			&i.UpdatedAt,
+			&i.QuantityPrecision,
+			&i.AssetClass,
+			&i.DayHigh,
+			&i.DayLow,
+			&i.Volume,
+			&i.FiftyTwoWeekHigh,
+			&i.FiftyTwoWeekLow,
 		); err != nil {
 			return nil, err
 		}
@@ -89,7 +179,7 @@ func (q *Queries) GetAllStocks(ctx context.Context) ([]Stock, error) {
 }
 
 const getStockBySymbol = `-- name: GetStockBySymbol :one
-SELECT symbol, company_name, current_price, previous_close, updated_at FROM stocks
+SELECT symbol, company_name, current_price, previous_close, updated_at, quantity_precision, asset_class, day_high, day_low, volume, fifty_two_week_high, fifty_two_week_low FROM stocks
 WHERE symbol = $1
 `
 
@@ -102,32 +192,38 @@ func (q *Queries) GetStockBySymbol(ctx context.Context, symbol string) (Stock, e
 		&i.CurrentPrice,
 		&i.PreviousClose,
 		&i.UpdatedAt,
+		&i.QuantityPrecision,
+		&i.AssetClass,
+		&i.DayHigh,
+		&i.DayLow,
+		&i.Volume,
+		&i.FiftyTwoWeekHigh,
+		&i.FiftyTwoWeekLow,
 	)
 	return i, err
 }
 
-const searchStockByName = `-- name: SearchStockByName :many
-SELECT symbol, company_name, current_price, previous_close, updated_at
-FROM stocks
-WHERE company_name ILIKE '%' || $1 || '%' OR symbol ILIKE '%' || $1 || '%'
+const getTrackedStocks = `-- name: GetTrackedStocks :many
+SELECT symbol, asset_class FROM stocks
+WHERE symbol IN (SELECT stock_symbol FROM holdings)
+OR symbol IN (SELECT stock_symbol FROM orders WHERE status IN ('OPEN', 'TRIGGERED'))
 `
 
-func (q *Queries) SearchStockByName(ctx context.Context, dollar_1 sql.NullString) ([]Stock, error) {
-	rows, err := q.db.QueryContext(ctx, searchStockByName, dollar_1)
+type GetTrackedStocksRow struct {
+	Symbol     string `json:"symbol"`
+	AssetClass string `json:"asset_class"`
+}
+
+func (q *Queries) GetTrackedStocks(ctx context.Context) ([]GetTrackedStocksRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTrackedStocks)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Stock
+	var items []GetTrackedStocksRow
 	for rows.Next() {
-		var i Stock
-		if err := rows.Scan(
-			&i.Symbol,
-			&i.CompanyName,
-			&i.CurrentPrice,
-			&i.PreviousClose,
-			&i.UpdatedAt,
-		); err != nil {
+		var i GetTrackedStocksRow
+		if err := rows.Scan(&i.Symbol, &i.AssetClass); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -148,13 +244,13 @@ SET
     previous_close = $2,
     updated_at = NOW()
 WHERE symbol = $3
-RETURNING symbol, company_name, current_price, previous_close, updated_at
+RETURNING symbol, company_name, current_price, previous_close, updated_at, quantity_precision, asset_class, day_high, day_low, volume, fifty_two_week_high, fifty_two_week_low
 `
 
 type UpdateStockPriceParams struct {
-	CurrentPrice  float64         `json:"current_price"`
-	PreviousClose sql.NullFloat64 `json:"previous_close"`
-	Symbol        string          `json:"symbol"`
+	CurrentPrice  decimal.Decimal     `json:"current_price"`
+	PreviousClose decimal.NullDecimal `json:"previous_close"`
+	Symbol        string              `json:"symbol"`
 }
 
 func (q *Queries) UpdateStockPrice(ctx context.Context, arg UpdateStockPriceParams) (Stock, error) {
@@ -166,6 +262,13 @@ func (q *Queries) UpdateStockPrice(ctx context.Context, arg UpdateStockPricePara
 		&i.CurrentPrice,
 		&i.PreviousClose,
 		&i.UpdatedAt,
+		&i.QuantityPrecision,
+		&i.AssetClass,
+		&i.DayHigh,
+		&i.DayLow,
+		&i.Volume,
+		&i.FiftyTwoWeekHigh,
+		&i.FiftyTwoWeekLow,
 	)
 	return i, err
 }
@@ -7,12 +7,51 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
+const countTransactions = `-- name: CountTransactions :one
+SELECT COUNT(*) FROM transactions
+WHERE user_id = $1
+AND ($2::TEXT IS NULL OR stock_symbol = $2)
+AND ($3::TEXT IS NULL OR type = $3)
+AND ($4::TIMESTAMP IS NULL OR created_at >= $4)
+AND ($5::TIMESTAMP IS NULL OR created_at < $5)
+AND ($6::NUMERIC IS NULL OR total_amount >= $6)
+AND ($7::NUMERIC IS NULL OR total_amount <= $7)
+`
+
+type CountTransactionsParams struct {
+	UserID      uuid.UUID           `json:"user_id"`
+	StockSymbol sql.NullString      `json:"stock_symbol"`
+	Type        sql.NullString      `json:"type"`
+	FromTime    sql.NullTime        `json:"from_time"`
+	ToTime      sql.NullTime        `json:"to_time"`
+	MinAmount   decimal.NullDecimal `json:"min_amount"`
+	MaxAmount   decimal.NullDecimal `json:"max_amount"`
+}
+
+func (q *Queries) CountTransactions(ctx context.Context, arg CountTransactionsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countTransactions,
+		arg.UserID,
+		arg.StockSymbol,
+		arg.Type,
+		arg.FromTime,
+		arg.ToTime,
+		arg.MinAmount,
+		arg.MaxAmount,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createATransaction = `-- name: CreateATransaction :one
-INSERT INTO transactions(id, user_id, stock_symbol, type, quantity, price, total_amount, created_at)
+INSERT INTO transactions(id, user_id, stock_symbol, type, quantity, price, total_amount, external_id, created_at)
 VALUES (
     gen_random_uuid(),
     $1,
@@ -21,18 +60,22 @@ VALUES (
     $4,
     $5,
     $6,
-    NOW()
+    $7,
+    -- Imported trades keep the time they were made
+    COALESCE($8::TIMESTAMP, NOW())
 )
-RETURNING id, user_id, stock_symbol, type, quantity, price, total_amount, created_at
+RETURNING id, user_id, stock_symbol, type, quantity, price, total_amount, created_at, external_id
 `
 
 type CreateATransactionParams struct {
-	UserID      uuid.UUID `json:"user_id"`
-	StockSymbol string    `json:"stock_symbol"`
-	Type        string    `json:"type"`
-	Quantity    int32     `json:"quantity"`
-	Price       float64   `json:"price"`
-	TotalAmount float64   `json:"total_amount"`
+	UserID      uuid.UUID       `json:"user_id"`
+	StockSymbol string          `json:"stock_symbol"`
+	Type        string          `json:"type"`
+	Quantity    decimal.Decimal `json:"quantity"`
+	Price       decimal.Decimal `json:"price"`
+	TotalAmount decimal.Decimal `json:"total_amount"`
+	ExternalID  string          `json:"external_id"`
+	CreatedAt   sql.NullTime    `json:"created_at"`
 }
 
 func (q *Queries) CreateATransaction(ctx context.Context, arg CreateATransactionParams) (Transaction, error) {
@@ -43,6 +86,8 @@ func (q *Queries) CreateATransaction(ctx context.Context, arg CreateATransaction
 		arg.Quantity,
 		arg.Price,
 		arg.TotalAmount,
+		arg.ExternalID,
+		arg.CreatedAt,
 	)
 	var i Transaction
 	err := row.Scan(
@@ -54,19 +99,57 @@ func (q *Queries) CreateATransaction(ctx context.Context, arg CreateATransaction
 		&i.Price,
 		&i.TotalAmount,
 		&i.CreatedAt,
+		&i.ExternalID,
 	)
 	return i, err
 }
 
-const getAllTransactionsForUser = `-- name: GetAllTransactionsForUser :many
-SELECT id, user_id, stock_symbol, type, quantity, price, total_amount, created_at FROM transactions
+const getLatestTransactionTime = `-- name: GetLatestTransactionTime :one
+SELECT MAX(created_at)::TIMESTAMP AS latest
+FROM transactions
+WHERE user_id = $1 AND stock_symbol = $2
+`
+
+type GetLatestTransactionTimeParams struct {
+	UserID      uuid.UUID `json:"user_id"`
+	StockSymbol string    `json:"stock_symbol"`
+}
+
+func (q *Queries) GetLatestTransactionTime(ctx context.Context, arg GetLatestTransactionTimeParams) (sql.NullTime, error) {
+	row := q.db.QueryRowContext(ctx, getLatestTransactionTime, arg.UserID, arg.StockSymbol)
+	var latest sql.NullTime
+	err := row.Scan(&latest)
+	return latest, err
+}
+
+const getTransactionsForExport = `-- name: GetTransactionsForExport :many
+SELECT id, user_id, stock_symbol, type, quantity, price, total_amount, created_at, external_id FROM transactions
 WHERE user_id = $1
-ORDER BY created_at DESC 
-LIMIT 10
+AND created_at >= $2 AND created_at < $3
+    -- Keyset pages, pass the last row's created_at and id for the next one
+AND (created_at, id) > ($4::TIMESTAMP, $5::UUID)
+ORDER BY created_at, id
+LIMIT $6
 `
 
-func (q *Queries) GetAllTransactionsForUser(ctx context.Context, userID uuid.UUID) ([]Transaction, error) {
-	rows, err := q.db.QueryContext(ctx, getAllTransactionsForUser, userID)
+type GetTransactionsForExportParams struct {
+	UserID         uuid.UUID `json:"user_id"`
+	FromTime       time.Time `json:"from_time"`
+	ToTime         time.Time `json:"to_time"`
+	AfterCreatedAt time.Time `json:"after_created_at"`
+	AfterID        uuid.UUID `json:"after_id"`
+	PageSize       int32     `json:"page_size"`
+}
+
+func (q *Queries) GetTransactionsForExport(ctx context.Context, arg GetTransactionsForExportParams) ([]Transaction, error) {
+	rows, err := q.db.QueryContext(ctx, getTransactionsForExport,
+		arg.UserID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageSize,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -83,6 +166,7 @@ func (q *Queries) GetAllTransactionsForUser(ctx context.Context, userID uuid.UUI
 			&i.Price,
 			&i.TotalAmount,
 			&i.CreatedAt,
+			&i.ExternalID,
 		); err != nil {
 			return nil, err
 		}
@@ -97,18 +181,20 @@ func (q *Queries) GetAllTransactionsForUser(ctx context.Context, userID uuid.UUI
 	return items, nil
 }
 
-const getAllTransactionsForUserBySymbol = `-- name: GetAllTransactionsForUserBySymbol :many
-SELECT id, user_id, stock_symbol, type, quantity, price, total_amount, created_at FROM transactions
-WHERE user_id = $1 AND stock_symbol = $2
+const getTransactionsForUserBetween = `-- name: GetTransactionsForUserBetween :many
+SELECT id, user_id, stock_symbol, type, quantity, price, total_amount, created_at, external_id FROM transactions
+WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+ORDER BY created_at
 `
 
-type GetAllTransactionsForUserBySymbolParams struct {
+type GetTransactionsForUserBetweenParams struct {
 	UserID      uuid.UUID `json:"user_id"`
-	StockSymbol string    `json:"stock_symbol"`
+	CreatedAt   time.Time `json:"created_at"`
+	CreatedAt_2 time.Time `json:"created_at_2"`
 }
 
-func (q *Queries) GetAllTransactionsForUserBySymbol(ctx context.Context, arg GetAllTransactionsForUserBySymbolParams) ([]Transaction, error) {
-	rows, err := q.db.QueryContext(ctx, getAllTransactionsForUserBySymbol, arg.UserID, arg.StockSymbol)
+func (q *Queries) GetTransactionsForUserBetween(ctx context.Context, arg GetTransactionsForUserBetweenParams) ([]Transaction, error) {
+	rows, err := q.db.QueryContext(ctx, getTransactionsForUserBetween, arg.UserID, arg.CreatedAt, arg.CreatedAt_2)
 	if err != nil {
 		return nil, err
 	}
@@ -125,6 +211,7 @@ func (q *Queries) GetAllTransactionsForUserBySymbol(ctx context.Context, arg Get
 			&i.Price,
 			&i.TotalAmount,
 			&i.CreatedAt,
+			&i.ExternalID,
 		); err != nil {
 			return nil, err
 		}
@@ -138,3 +225,328 @@ func (q *Queries) GetAllTransactionsForUserBySymbol(ctx context.Context, arg Get
 	}
 	return items, nil
 }
+
+const listTransactionsLargestFirst = `-- name: ListTransactionsLargestFirst :many
+SELECT id, user_id, stock_symbol, type, quantity, price, total_amount, created_at, external_id FROM transactions
+WHERE user_id = $1
+AND ($2::TEXT IS NULL OR stock_symbol = $2)
+AND ($3::TEXT IS NULL OR type = $3)
+AND ($4::TIMESTAMP IS NULL OR created_at >= $4)
+AND ($5::TIMESTAMP IS NULL OR created_at < $5)
+AND ($6::NUMERIC IS NULL OR total_amount >= $6)
+AND ($7::NUMERIC IS NULL OR total_amount <= $7)
+    -- Keyset cursor, the sort value and id of the last row of the previous page
+AND ($8::NUMERIC IS NULL OR (total_amount, id) < ($8, $9::UUID))
+ORDER BY total_amount DESC, id DESC
+LIMIT $10
+`
+
+type ListTransactionsLargestFirstParams struct {
+	UserID      uuid.UUID           `json:"user_id"`
+	StockSymbol sql.NullString      `json:"stock_symbol"`
+	Type        sql.NullString      `json:"type"`
+	FromTime    sql.NullTime        `json:"from_time"`
+	ToTime      sql.NullTime        `json:"to_time"`
+	MinAmount   decimal.NullDecimal `json:"min_amount"`
+	MaxAmount   decimal.NullDecimal `json:"max_amount"`
+	AfterAmount decimal.NullDecimal `json:"after_amount"`
+	AfterID     uuid.NullUUID       `json:"after_id"`
+	PageSize    int32               `json:"page_size"`
+}
+
+func (q *Queries) ListTransactionsLargestFirst(ctx context.Context, arg ListTransactionsLargestFirstParams) ([]Transaction, error) {
+	rows, err := q.db.QueryContext(ctx, listTransactionsLargestFirst,
+		arg.UserID,
+		arg.StockSymbol,
+		arg.Type,
+		arg.FromTime,
+		arg.ToTime,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.AfterAmount,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Transaction
+	for rows.Next() {
+		var i Transaction
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.StockSymbol,
+			&i.Type,
+			&i.Quantity,
+			&i.Price,
+			&i.TotalAmount,
+			&i.CreatedAt,
+			&i.ExternalID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTransactionsNewestFirst = `-- name: ListTransactionsNewestFirst :many
+SELECT id, user_id, stock_symbol, type, quantity, price, total_amount, created_at, external_id FROM transactions
+WHERE user_id = $1
+AND ($2::TEXT IS NULL OR stock_symbol = $2)
+AND ($3::TEXT IS NULL OR type = $3)
+AND ($4::TIMESTAMP IS NULL OR created_at >= $4)
+AND ($5::TIMESTAMP IS NULL OR created_at < $5)
+AND ($6::NUMERIC IS NULL OR total_amount >= $6)
+AND ($7::NUMERIC IS NULL OR total_amount <= $7)
+    -- Keyset cursor, the sort value and id of the last row of the previous page
+AND ($8::TIMESTAMP IS NULL OR (created_at, id) < ($8, $9::UUID))
+ORDER BY created_at DESC, id DESC
+LIMIT $10
+`
+
+type ListTransactionsNewestFirstParams struct {
+	UserID         uuid.UUID           `json:"user_id"`
+	StockSymbol    sql.NullString      `json:"stock_symbol"`
+	Type           sql.NullString      `json:"type"`
+	FromTime       sql.NullTime        `json:"from_time"`
+	ToTime         sql.NullTime        `json:"to_time"`
+	MinAmount      decimal.NullDecimal `json:"min_amount"`
+	MaxAmount      decimal.NullDecimal `json:"max_amount"`
+	AfterCreatedAt sql.NullTime        `json:"after_created_at"`
+	AfterID        uuid.NullUUID       `json:"after_id"`
+	PageSize       int32               `json:"page_size"`
+}
+
+func (q *Queries) ListTransactionsNewestFirst(ctx context.Context, arg ListTransactionsNewestFirstParams) ([]Transaction, error) {
+	rows, err := q.db.QueryContext(ctx, listTransactionsNewestFirst,
+		arg.UserID,
+		arg.StockSymbol,
+		arg.Type,
+		arg.FromTime,
+		arg.ToTime,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Transaction
+	for rows.Next() {
+		var i Transaction
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.StockSymbol,
+			&i.Type,
+			&i.Quantity,
+			&i.Price,
+			&i.TotalAmount,
+			&i.CreatedAt,
+			&i.ExternalID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTransactionsOldestFirst = `-- name: ListTransactionsOldestFirst :many
+SELECT id, user_id, stock_symbol, type, quantity, price, total_amount, created_at, external_id FROM transactions
+WHERE user_id = $1
+AND ($2::TEXT IS NULL OR stock_symbol = $2)
+AND ($3::TEXT IS NULL OR type = $3)
+AND ($4::TIMESTAMP IS NULL OR created_at >= $4)
+AND ($5::TIMESTAMP IS NULL OR created_at < $5)
+AND ($6::NUMERIC IS NULL OR total_amount >= $6)
+AND ($7::NUMERIC IS NULL OR total_amount <= $7)
+    -- Keyset cursor, the sort value and id of the last row of the previous page
+AND ($8::TIMESTAMP IS NULL OR (created_at, id) > ($8, $9::UUID))
+ORDER BY created_at ASC, id ASC
+LIMIT $10
+`
+
+type ListTransactionsOldestFirstParams struct {
+	UserID         uuid.UUID           `json:"user_id"`
+	StockSymbol    sql.NullString      `json:"stock_symbol"`
+	Type           sql.NullString      `json:"type"`
+	FromTime       sql.NullTime        `json:"from_time"`
+	ToTime         sql.NullTime        `json:"to_time"`
+	MinAmount      decimal.NullDecimal `json:"min_amount"`
+	MaxAmount      decimal.NullDecimal `json:"max_amount"`
+	AfterCreatedAt sql.NullTime        `json:"after_created_at"`
+	AfterID        uuid.NullUUID       `json:"after_id"`
+	PageSize       int32               `json:"page_size"`
+}
+
+func (q *Queries) ListTransactionsOldestFirst(ctx context.Context, arg ListTransactionsOldestFirstParams) ([]Transaction, error) {
+	rows, err := q.db.QueryContext(ctx, listTransactionsOldestFirst,
+		arg.UserID,
+		arg.StockSymbol,
+		arg.Type,
+		arg.FromTime,
+		arg.ToTime,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Transaction
+	for rows.Next() {
+		var i Transaction
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.StockSymbol,
+			&i.Type,
+			&i.Quantity,
+			&i.Price,
+			&i.TotalAmount,
+			&i.CreatedAt,
+			&i.ExternalID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTransactionsSmallestFirst = `-- name: ListTransactionsSmallestFirst :many
+SELECT id, user_id, stock_symbol, type, quantity, price, total_amount, created_at, external_id FROM transactions
+WHERE user_id = $1
+AND ($2::TEXT IS NULL OR stock_symbol = $2)
+AND ($3::TEXT IS NULL OR type = $3)
+AND ($4::TIMESTAMP IS NULL OR created_at >= $4)
+AND ($5::TIMESTAMP IS NULL OR created_at < $5)
+AND ($6::NUMERIC IS NULL OR total_amount >= $6)
+AND ($7::NUMERIC IS NULL OR total_amount <= $7)
+    -- Keyset cursor, the sort value and id of the last row of the previous page
+AND ($8::NUMERIC IS NULL OR (total_amount, id) > ($8, $9::UUID))
+ORDER BY total_amount ASC, id ASC
+LIMIT $10
+`
+
+type ListTransactionsSmallestFirstParams struct {
+	UserID      uuid.UUID           `json:"user_id"`
+	StockSymbol sql.NullString      `json:"stock_symbol"`
+	Type        sql.NullString      `json:"type"`
+	FromTime    sql.NullTime        `json:"from_time"`
+	ToTime      sql.NullTime        `json:"to_time"`
+	MinAmount   decimal.NullDecimal `json:"min_amount"`
+	MaxAmount   decimal.NullDecimal `json:"max_amount"`
+	AfterAmount decimal.NullDecimal `json:"after_amount"`
+	AfterID     uuid.NullUUID       `json:"after_id"`
+	PageSize    int32               `json:"page_size"`
+}
+
+func (q *Queries) ListTransactionsSmallestFirst(ctx context.Context, arg ListTransactionsSmallestFirstParams) ([]Transaction, error) {
+	rows, err := q.db.QueryContext(ctx, listTransactionsSmallestFirst,
+		arg.UserID,
+		arg.StockSymbol,
+		arg.Type,
+		arg.FromTime,
+		arg.ToTime,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.AfterAmount,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Transaction
+	for rows.Next() {
+		var i Transaction
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.StockSymbol,
+			&i.Type,
+			&i.Quantity,
+			&i.Price,
+			&i.TotalAmount,
+			&i.CreatedAt,
+			&i.ExternalID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const transactionExists = `-- name: TransactionExists :one
+SELECT EXISTS (
+    SELECT 1 FROM transactions
+    WHERE user_id = $1
+    AND (
+        ($2::TEXT <> '' AND (external_id = $2 OR id::TEXT = $2))
+        OR (stock_symbol = $3 AND type = $4 AND quantity = $5
+            AND price = $6 AND created_at = $7)
+    )
+)
+`
+
+type TransactionExistsParams struct {
+	UserID      uuid.UUID       `json:"user_id"`
+	ExternalID  string          `json:"external_id"`
+	StockSymbol string          `json:"stock_symbol"`
+	Type        string          `json:"type"`
+	Quantity    decimal.Decimal `json:"quantity"`
+	Price       decimal.Decimal `json:"price"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+func (q *Queries) TransactionExists(ctx context.Context, arg TransactionExistsParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, transactionExists,
+		arg.UserID,
+		arg.ExternalID,
+		arg.StockSymbol,
+		arg.Type,
+		arg.Quantity,
+		arg.Price,
+		arg.CreatedAt,
+	)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
@@ -7,18 +7,39 @@ package database
 
 import (
 	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
 )
 
+const countUsers = `-- name: CountUsers :one
+SELECT COUNT(*) FROM users
+WHERE ($1::TEXT = '' OR email ILIKE '%' || $1::TEXT || '%' OR name ILIKE '%' || $1::TEXT || '%')
+AND ($2::TEXT IS NULL OR role = $2)
+`
+
+type CountUsersParams struct {
+	Pattern string         `json:"pattern"`
+	Role    sql.NullString `json:"role"`
+}
+
+func (q *Queries) CountUsers(ctx context.Context, arg CountUsersParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsers, arg.Pattern, arg.Role)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users(id, email, name, created_at, hashed_password)
 VALUES (
     gen_random_uuid(),
-    $1,
+    lower($1),
     $2,
     NOW(),
     $3
 )
-RETURNING id, email, name, created_at, hashed_password
+RETURNING id, email, name, created_at, hashed_password, verified_at, role, disabled_at
 `
 
 type CreateUserParams struct {
@@ -36,6 +57,9 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Name,
 		&i.CreatedAt,
 		&i.HashedPassword,
+		&i.VerifiedAt,
+		&i.Role,
+		&i.DisabledAt,
 	)
 	return i, err
 }
@@ -49,10 +73,65 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const disableUser = `-- name: DisableUser :execrows
+UPDATE users
+SET disabled_at = NOW()
+WHERE id = $1 AND disabled_at IS NULL
+`
+
+func (q *Queries) DisableUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, disableUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enableUser = `-- name: EnableUser :execrows
+UPDATE users
+SET disabled_at = NULL
+WHERE id = $1 AND disabled_at IS NOT NULL
+`
+
+func (q *Queries) EnableUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enableUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getAllUserIDs = `-- name: GetAllUserIDs :many
+SELECT id FROM users
+`
+
+func (q *Queries) GetAllUserIDs(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getAllUserIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, name, created_at, hashed_password
+SELECT id, email, name, created_at, hashed_password, verified_at, role, disabled_at
 FROM users
-WHERE email = $1
+WHERE lower(email) = lower($1)
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -64,6 +143,143 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Name,
 		&i.CreatedAt,
 		&i.HashedPassword,
+		&i.VerifiedAt,
+		&i.Role,
+		&i.DisabledAt,
 	)
 	return i, err
 }
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, email, name, created_at, hashed_password, verified_at, role, disabled_at
+FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.Name,
+		&i.CreatedAt,
+		&i.HashedPassword,
+		&i.VerifiedAt,
+		&i.Role,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const lockUserForTrade = `-- name: LockUserForTrade :exec
+-- held until the transaction ends so a user's trades and withdrawals go one at a time,
+    -- NO KEY leaves rows that only reference the user free to be inserted
+SELECT id FROM users
+WHERE id = $1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) LockUserForTrade(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockUserForTrade, id)
+	return err
+}
+
+const markUserVerified = `-- name: MarkUserVerified :exec
+UPDATE users
+SET verified_at = NOW()
+WHERE id = $1 AND verified_at IS NULL
+`
+
+func (q *Queries) MarkUserVerified(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markUserVerified, id)
+	return err
+}
+
+const searchUsers = `-- name: SearchUsers :many
+-- newest first, the term matches anywhere in the email or name
+SELECT id, email, name, created_at, hashed_password, verified_at, role, disabled_at FROM users
+WHERE ($1::TEXT = '' OR email ILIKE '%' || $1::TEXT || '%' OR name ILIKE '%' || $1::TEXT || '%')
+AND ($2::TEXT IS NULL OR role = $2)
+ORDER BY created_at DESC, id
+LIMIT $3 OFFSET $4
+`
+
+type SearchUsersParams struct {
+	Pattern    string         `json:"pattern"`
+	Role       sql.NullString `json:"role"`
+	PageSize   int32          `json:"page_size"`
+	PageOffset int32          `json:"page_offset"`
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsers,
+		arg.Pattern,
+		arg.Role,
+		arg.PageSize,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.Name,
+			&i.CreatedAt,
+			&i.HashedPassword,
+			&i.VerifiedAt,
+			&i.Role,
+			&i.DisabledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setUserRole = `-- name: SetUserRole :execrows
+UPDATE users
+SET role = $2
+WHERE id = $1
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID `json:"id"`
+	Role string    `json:"role"`
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setUserRole, arg.ID, arg.Role)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID `json:"id"`
+	HashedPassword string    `json:"hashed_password"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
+	return err
+}
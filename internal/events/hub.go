@@ -9,9 +9,17 @@ import (
 )
 
 type Client struct {
-	ID      uuid.UUID
-	Send    chan []byte
-	Symbols map[string]bool
+	ID uuid.UUID
+	// The login the connection was opened with, ending it closes the connection
+	SessionID uuid.UUID
+	Send      chan []byte
+	Symbols   map[string]bool
+}
+
+// Event is sent only to the given user's connection, unlike stock updates on Broadcast
+type Event struct {
+	UserID uuid.UUID
+	Data   []byte
 }
 
 type Hub struct {
@@ -19,6 +27,8 @@ type Hub struct {
 	Register   chan *Client
 	Unregister chan *Client
 	Broadcast  chan []byte
+	Direct     chan Event
+	EndSession chan uuid.UUID
 }
 
 var HubInstance = &Hub{
@@ -26,6 +36,8 @@ var HubInstance = &Hub{
 	Register:   make(chan *Client),
 	Unregister: make(chan *Client),
 	Broadcast:  make(chan []byte, 10*1024),
+	Direct:     make(chan Event, 1024),
+	EndSession: make(chan uuid.UUID, 1024),
 }
 
 func (h *Hub) Run() {
@@ -35,7 +47,8 @@ func (h *Hub) Run() {
 			h.Clients[client.ID] = client
 
 		case client := <-h.Unregister:
-			if _, ok := h.Clients[client.ID]; ok {
+			// a newer connection from the same user may have taken its place
+			if h.Clients[client.ID] == client {
 				delete(h.Clients, client.ID)
 				close(client.Send)
 			}
@@ -58,6 +71,27 @@ func (h *Hub) Run() {
 					}
 				}
 			}
+
+		case sessionID := <-h.EndSession:
+			for _, client := range h.Clients {
+				if client.SessionID == sessionID {
+					close(client.Send)
+					delete(h.Clients, client.ID)
+				}
+			}
+
+		case event := <-h.Direct:
+			// nothing to do if the user isn't connected right now
+			client, ok := h.Clients[event.UserID]
+			if !ok {
+				continue
+			}
+			select {
+			case client.Send <- event.Data:
+			default: // client too slow
+				close(client.Send)
+				delete(h.Clients, client.ID)
+			}
 		}
 	}
 }
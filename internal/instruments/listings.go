@@ -0,0 +1,189 @@
+package instruments
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+)
+
+// Formats Load reads, named after where the file comes from:
+//   - nse: EQUITY_L.csv from nseindia.com
+//   - bse: the list of scrips CSV from bseindia.com
+//   - nasdaq: nasdaqlisted.txt from the Nasdaq Trader symbol directory
+//   - otherlisted: otherlisted.txt from the same directory, NYSE and the other US exchanges
+//   - csv: symbol,name,exchange,isin,currency,asset_class with a header row, for anything else
+var Formats = []string{"nse", "bse", "nasdaq", "otherlisted", "csv"}
+
+// Nasdaq Trader's exchange codes in otherlisted.txt
+var otherListedExchanges = map[string]string{
+	"A": "NYSEAMERICAN",
+	"N": "NYSE",
+	"P": "NYSEARCA",
+	"Z": "BATS",
+	"V": "IEX",
+}
+
+// Parse reads a listing file into the instruments it lists, under the symbols Yahoo quotes them by
+func Parse(format string, r io.Reader) ([]database.UpsertInstrumentParams, error) {
+	switch format {
+	case "nse":
+		return parseCSV(r, ',', func(row map[string]string) (database.UpsertInstrumentParams, bool) {
+			// Only the regular equity series trades under the plain symbol
+			if series := row["series"]; series != "" && series != "EQ" && series != "BE" {
+				return database.UpsertInstrumentParams{}, false
+			}
+			return database.UpsertInstrumentParams{
+				Symbol:     row["symbol"] + ".NS",
+				Name:       row["name of company"],
+				Exchange:   "NSE",
+				Isin:       row["isin number"],
+				Currency:   "INR",
+				AssetClass: config.AssetEquity,
+			}, true
+		})
+	case "bse":
+		return parseCSV(r, ',', func(row map[string]string) (database.UpsertInstrumentParams, bool) {
+			if !strings.EqualFold(row["status"], "active") || row["security id"] == "" {
+				return database.UpsertInstrumentParams{}, false
+			}
+			return database.UpsertInstrumentParams{
+				Symbol:     row["security id"] + ".BO",
+				Name:       firstOf(row["issuer name"], row["security name"]),
+				Exchange:   "BSE",
+				Isin:       row["isin no"],
+				Currency:   "INR",
+				AssetClass: config.AssetEquity,
+			}, true
+		})
+	case "nasdaq":
+		return parseCSV(r, '|', func(row map[string]string) (database.UpsertInstrumentParams, bool) {
+			return usListing(row["symbol"], row["security name"], "NASDAQ", row["etf"], row["test issue"])
+		})
+	case "otherlisted":
+		return parseCSV(r, '|', func(row map[string]string) (database.UpsertInstrumentParams, bool) {
+			exchange, ok := otherListedExchanges[row["exchange"]]
+			if !ok {
+				return database.UpsertInstrumentParams{}, false
+			}
+			return usListing(row["act symbol"], row["security name"], exchange, row["etf"], row["test issue"])
+		})
+	case "csv":
+		return parseCSV(r, ',', func(row map[string]string) (database.UpsertInstrumentParams, bool) {
+			assetClass := strings.ToUpper(row["asset_class"])
+			if !slices.Contains(config.AssetClasses, assetClass) {
+				assetClass = config.AssetEquity
+			}
+			return database.UpsertInstrumentParams{
+				Symbol:     strings.ToUpper(row["symbol"]),
+				Name:       row["name"],
+				Exchange:   strings.ToUpper(row["exchange"]),
+				Isin:       strings.ToUpper(row["isin"]),
+				Currency:   strings.ToUpper(row["currency"]),
+				AssetClass: assetClass,
+			}, row["symbol"] != "" && row["name"] != ""
+		})
+	}
+	return nil, fmt.Errorf("unknown listing format %q, must be one of %s", format, strings.Join(Formats, "/"))
+}
+
+// Test issues are skipped, Yahoo writes share classes with a dash (BRK.B is BRK-B) and
+// has no plain symbol for preferreds and warrants ($ in the directory)
+func usListing(symbol, name, exchange, etf, testIssue string) (database.UpsertInstrumentParams, bool) {
+	if symbol == "" || testIssue == "Y" || strings.Contains(symbol, "$") {
+		return database.UpsertInstrumentParams{}, false
+	}
+	assetClass := config.AssetEquity
+	if etf == "Y" {
+		assetClass = config.AssetETF
+	}
+	return database.UpsertInstrumentParams{
+		Symbol:     strings.ReplaceAll(symbol, ".", "-"),
+		Name:       name,
+		Exchange:   exchange,
+		Currency:   "USD",
+		AssetClass: assetClass,
+	}, true
+}
+
+// Reads a delimited file with a header row, handing each row to fn keyed by its lowercased
+// column names. Rows fn rejects are left out.
+func parseCSV(r io.Reader, delimiter rune, fn func(row map[string]string) (database.UpsertInstrumentParams, bool)) ([]database.UpsertInstrumentParams, error) {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading header: %w", err)
+	}
+	for i, name := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+	}
+
+	var res []database.UpsertInstrumentParams
+	seen := make(map[string]bool)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// The Nasdaq Trader files end with a "File Creation Time" line
+		if len(record) < len(header) || strings.HasPrefix(record[0], "File Creation Time") {
+			continue
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			row[name] = strings.TrimSpace(record[i])
+		}
+		instrument, ok := fn(row)
+		if !ok || seen[instrument.Symbol] {
+			continue
+		}
+		seen[instrument.Symbol] = true
+		res = append(res, instrument)
+	}
+	return res, nil
+}
+
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Load upserts everything in a listing file in one transaction, a listing replaces what an
+// earlier one or Yahoo said about the same symbol
+func Load(ctx context.Context, conn *sql.DB, format string, r io.Reader) (int, error) {
+	listed, err := Parse(format, r)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	q := database.New(tx)
+	for _, instrument := range listed {
+		if err := q.UpsertInstrument(ctx, instrument); err != nil {
+			return 0, fmt.Errorf("error loading %s: %w", instrument.Symbol, err)
+		}
+	}
+	return len(listed), tx.Commit()
+}
@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	Stream = "events:notifications"
+	Group  = "notifications-group"
+
+	ChannelEmail = "EMAIL"
+	ChannelInApp = "IN_APP"
+
+	KindAlert    = "ALERT"
+	KindSummary  = "SUMMARY"
+	KindSecurity = "SECURITY"
+	// Account mail like password reset links, always sent and only by email. It
+	// isn't in Kinds since there's no preference for it.
+	KindAccount = "ACCOUNT"
+)
+
+var (
+	Channels = []string{ChannelEmail, ChannelInApp}
+	Kinds    = []string{KindAlert, KindSummary, KindSecurity}
+)
+
+// Message is what handlers put on the stream, rendering and sending happens in the worker
+type Message struct {
+	UserID   uuid.UUID      `json:"user_id"`
+	Kind     string         `json:"kind"`
+	Template string         `json:"template"`
+	Subject  string         `json:"subject"`
+	Data     map[string]any `json:"data"`
+}
+
+// Enqueue only does an XADD so it's safe to call from request handlers
+func Enqueue(ctx context.Context, rd *redis.Client, msg Message) error {
+	msgJSON, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return rd.XAdd(ctx, &redis.XAddArgs{
+		Stream: Stream,
+		Values: map[string]any{"message": string(msgJSON)},
+		MaxLen: 10000,
+		Approx: true,
+	}).Err()
+}
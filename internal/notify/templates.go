@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*
+var templateFS embed.FS
+
+type Rendered struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Render executes templates/<name>.txt.tmpl and templates/<name>.html.tmpl (wrapped in the shared layout)
+func Render(name, subject string, data map[string]any) (Rendered, error) {
+	if data == nil {
+		data = map[string]any{}
+	}
+	data["Subject"] = subject
+
+	textTmpl, err := texttemplate.ParseFS(templateFS, "templates/"+name+".txt.tmpl")
+	if err != nil {
+		return Rendered{}, err
+	}
+	var text bytes.Buffer
+	if err := textTmpl.Execute(&text, data); err != nil {
+		return Rendered{}, err
+	}
+
+	htmlTmpl, err := htmltemplate.ParseFS(templateFS, "templates/layout.html.tmpl", "templates/"+name+".html.tmpl")
+	if err != nil {
+		return Rendered{}, err
+	}
+	var html bytes.Buffer
+	if err := htmlTmpl.ExecuteTemplate(&html, "layout", data); err != nil {
+		return Rendered{}, err
+	}
+
+	return Rendered{
+		Subject: subject,
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,234 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID = "portfolio-api"
+	testKid      = "key-1"
+	testNonce    = "n-0S6_WzA2Mj"
+)
+
+// A provider serving its discovery document and one RSA signing key
+func testProvider(t *testing.T) (*Provider, *discovery, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discovery{
+			Issuer:                srv.URL,
+			AuthorizationEndpoint: srv.URL + "/authorize",
+			TokenEndpoint:         srv.URL + "/token",
+			JWKSURI:               srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []jsonWebKey{{
+			Kty: "RSA",
+			Kid: testKid,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+
+	p := New(Config{Issuer: srv.URL, ClientID: testClientID})
+	doc, err := p.discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, doc, key
+}
+
+// Claims of a token that verifies, for the cases below to break one at a time
+func validClaims(issuer string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            issuer,
+		"sub":            "248289761001",
+		"aud":            testClientID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          testNonce,
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"name":           "Jane Doe",
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, claims jwt.MapClaims, kid string, key any) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestVerify(t *testing.T) {
+	p, doc, key := testProvider(t)
+
+	raw := sign(t, jwt.SigningMethodRS256, validClaims(doc.Issuer), testKid, key)
+	identity, err := p.verify(context.Background(), doc, raw, testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Identity{Issuer: doc.Issuer, Subject: "248289761001", Email: "jane@example.com", EmailVerified: true, Name: "Jane Doe"}
+	if identity != want {
+		t.Errorf("got %+v, want %+v", identity, want)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	p, doc, key := testProvider(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		token func() string
+		nonce string
+	}{
+		{"signed by another key", func() string {
+			return sign(t, jwt.SigningMethodRS256, validClaims(doc.Issuer), testKid, otherKey)
+		}, testNonce},
+		{"payload changed after signing", func() string {
+			parts := strings.Split(sign(t, jwt.SigningMethodRS256, validClaims(doc.Issuer), testKid, key), ".")
+			claims := validClaims(doc.Issuer)
+			claims["sub"] = "someone-else"
+			payload, _ := json.Marshal(claims)
+			parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+			return strings.Join(parts, ".")
+		}, testNonce},
+		{"unknown kid", func() string {
+			return sign(t, jwt.SigningMethodRS256, validClaims(doc.Issuer), "key-2", key)
+		}, testNonce},
+		{"alg none", func() string {
+			return sign(t, jwt.SigningMethodNone, validClaims(doc.Issuer), testKid, jwt.UnsafeAllowNoneSignatureType)
+		}, testNonce},
+		{"HS256 with the client id as secret", func() string {
+			return sign(t, jwt.SigningMethodHS256, validClaims(doc.Issuer), testKid, []byte(testClientID))
+		}, testNonce},
+		{"wrong issuer", func() string {
+			claims := validClaims(doc.Issuer)
+			claims["iss"] = "https://evil.example.com"
+			return sign(t, jwt.SigningMethodRS256, claims, testKid, key)
+		}, testNonce},
+		{"wrong audience", func() string {
+			claims := validClaims(doc.Issuer)
+			claims["aud"] = "another-client"
+			return sign(t, jwt.SigningMethodRS256, claims, testKid, key)
+		}, testNonce},
+		{"several audiences, issued to another party", func() string {
+			claims := validClaims(doc.Issuer)
+			claims["aud"] = []string{testClientID, "another-client"}
+			claims["azp"] = "another-client"
+			return sign(t, jwt.SigningMethodRS256, claims, testKid, key)
+		}, testNonce},
+		{"expired past the skew", func() string {
+			claims := validClaims(doc.Issuer)
+			claims["iat"] = now.Add(-time.Hour).Unix()
+			claims["exp"] = now.Add(-clockSkew - time.Minute).Unix()
+			return sign(t, jwt.SigningMethodRS256, claims, testKid, key)
+		}, testNonce},
+		{"no expiry", func() string {
+			claims := validClaims(doc.Issuer)
+			delete(claims, "exp")
+			return sign(t, jwt.SigningMethodRS256, claims, testKid, key)
+		}, testNonce},
+		{"issued in the future", func() string {
+			claims := validClaims(doc.Issuer)
+			claims["iat"] = now.Add(clockSkew + time.Minute).Unix()
+			return sign(t, jwt.SigningMethodRS256, claims, testKid, key)
+		}, testNonce},
+		{"no subject", func() string {
+			claims := validClaims(doc.Issuer)
+			delete(claims, "sub")
+			return sign(t, jwt.SigningMethodRS256, claims, testKid, key)
+		}, testNonce},
+		{"wrong nonce", func() string {
+			return sign(t, jwt.SigningMethodRS256, validClaims(doc.Issuer), testKid, key)
+		}, "another-nonce"},
+		{"no nonce", func() string {
+			claims := validClaims(doc.Issuer)
+			delete(claims, "nonce")
+			return sign(t, jwt.SigningMethodRS256, claims, testKid, key)
+		}, testNonce},
+		{"email_verified that isn't a boolean", func() string {
+			claims := validClaims(doc.Issuer)
+			claims["email_verified"] = "yes"
+			return sign(t, jwt.SigningMethodRS256, claims, testKid, key)
+		}, testNonce},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := p.verify(context.Background(), doc, tt.token(), tt.nonce)
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Errorf("got %+v, %v, want ErrInvalidIDToken", identity, err)
+			}
+		})
+	}
+}
+
+func TestVerifyAccepts(t *testing.T) {
+	p, doc, key := testProvider(t)
+
+	tests := []struct {
+		name         string
+		change       func(jwt.MapClaims)
+		wantVerified bool
+	}{
+		{"email_verified true", func(c jwt.MapClaims) {}, true},
+		{"email_verified as a string", func(c jwt.MapClaims) { c["email_verified"] = "true" }, true},
+		{"email_verified false", func(c jwt.MapClaims) { c["email_verified"] = false }, false},
+		{"email_verified false as a string", func(c jwt.MapClaims) { c["email_verified"] = "false" }, false},
+		{"no email_verified", func(c jwt.MapClaims) { delete(c, "email_verified") }, false},
+		{"several audiences, issued to us", func(c jwt.MapClaims) {
+			c["aud"] = []string{testClientID, "another-client"}
+			c["azp"] = testClientID
+		}, true},
+		{"expired within the skew", func(c jwt.MapClaims) {
+			c["exp"] = time.Now().Add(-clockSkew / 2).Unix()
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims(doc.Issuer)
+			tt.change(claims)
+			identity, err := p.verify(context.Background(), doc, sign(t, jwt.SigningMethodRS256, claims, testKid, key), testNonce)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if identity.EmailVerified != tt.wantVerified {
+				t.Errorf("email verified %v, want %v", identity.EmailVerified, tt.wantVerified)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/auth"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+// Support can look at users, only admins can change anything
+func AdminRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	admin := router.Group("/admin", controllers.RequireRole(cfg, auth.RoleSupport, auth.RoleAdmin))
+	admin.GET("/users", controllers.AdminListUsers(cfg))
+	admin.GET("/users/:id", controllers.AdminGetUser(cfg))
+	admin.GET("/users/:id/portfolio", controllers.AdminGetUserPortfolio(cfg))
+
+	adminOnly := admin.Group("", controllers.RequireRole(cfg, auth.RoleAdmin))
+	adminOnly.POST("/users/:id/disable", controllers.AdminDisableUser(cfg))
+	adminOnly.POST("/users/:id/enable", controllers.AdminEnableUser(cfg))
+	adminOnly.PUT("/users/:id/role", controllers.AdminSetUserRole(cfg))
+	adminOnly.PUT("/users/:id/margin", controllers.AdminSetUserMargin(cfg))
+	adminOnly.GET("/audit", controllers.AdminGetAuditLog(cfg))
+	adminOnly.POST("/reset", controllers.DeleteUsers(cfg))
+}
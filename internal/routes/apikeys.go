@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func APIKeyRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	router.POST("/api/keys", controllers.CreateAPIKey(cfg))
+	router.GET("/api/keys", controllers.GetAPIKeys(cfg))
+	router.DELETE("/api/keys/:id", controllers.DeleteAPIKey(cfg))
+}
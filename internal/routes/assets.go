@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func ManualAssetRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	router.POST("/api/assets", controllers.CreateManualAsset(cfg))
+	router.GET("/api/assets", controllers.GetManualAssets(cfg))
+	router.GET("/api/assets/:id/valuations", controllers.GetManualAssetValuations(cfg))
+	router.POST("/api/assets/:id/valuations", controllers.AddManualAssetValuation(cfg))
+	router.DELETE("/api/assets/:id", controllers.DeleteManualAsset(cfg))
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func CashRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	router.GET("/api/cash", controllers.GetCash(cfg))
+	router.POST("/api/cash", controllers.CreateCashMovement(cfg))
+}
@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func DigestRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	router.GET("/api/digests/latest", controllers.GetLatestDigest(cfg))
+}
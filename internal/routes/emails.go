@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func EmailRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	router.POST("/api/email/verify", controllers.VerifyEmail(cfg))
+	router.POST("/api/email/verify/resend", controllers.ResendVerification(cfg))
+}
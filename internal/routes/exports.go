@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func ExportRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	router.GET("/api/exports/transactions", controllers.ExportTransactions(cfg))
+	router.GET("/api/exports/holdings", controllers.ExportHoldings(cfg))
+}
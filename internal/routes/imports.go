@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func ImportRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	router.POST("/api/imports", controllers.ImportTransactions(cfg))
+	router.GET("/api/imports/profiles", controllers.GetImportProfiles(cfg))
+	router.PUT("/api/imports/profiles/:name", controllers.SaveImportProfile(cfg))
+	router.DELETE("/api/imports/profiles/:name", controllers.DeleteImportProfile(cfg))
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func MarginRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	router.GET("/api/margin", controllers.GetMargin(cfg))
+	router.PUT("/api/margin", controllers.UpdateMargin(cfg))
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func NotificationRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	router.GET("/api/notifications/preferences", controllers.GetNotificationPreferences(cfg))
+	router.PUT("/api/notifications/preferences", controllers.UpdateNotificationPreferences(cfg))
+}
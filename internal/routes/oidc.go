@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func OIDCRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	router.GET("/api/oidc/login", controllers.StartOIDCLogin(cfg))
+	router.GET("/api/oidc/callback", controllers.OIDCCallback(cfg))
+	router.POST("/api/oidc/callback", controllers.OIDCCallback(cfg))
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func OrderRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	router.POST("/api/orders", controllers.CreateOrder(cfg))
+	router.GET("/api/orders", controllers.GetOrders(cfg))
+	router.DELETE("/api/orders/:id", controllers.CancelOrder(cfg))
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func PasswordRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	router.POST("/api/password/forgot", controllers.ForgotPassword(cfg))
+	router.POST("/api/password/reset", controllers.ResetPassword(cfg))
+	router.POST("/api/password/change", controllers.ChangePassword(cfg))
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func PlanRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	router.POST("/api/plans", controllers.CreatePlan(cfg))
+	router.GET("/api/plans", controllers.GetPlans(cfg))
+	router.GET("/api/plans/:id/executions", controllers.GetPlanExecutions(cfg))
+	router.POST("/api/plans/:id/pause", controllers.PausePlan(cfg))
+	router.POST("/api/plans/:id/resume", controllers.ResumePlan(cfg))
+	router.DELETE("/api/plans/:id", controllers.CancelPlan(cfg))
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func SessionRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	router.GET("/api/sessions", controllers.GetSessions(cfg))
+	router.DELETE("/api/sessions", controllers.DeleteSessions(cfg))
+	router.DELETE("/api/sessions/:id", controllers.DeleteSession(cfg))
+}
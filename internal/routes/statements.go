@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func StatementRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	router.GET("/api/statements", controllers.GetStatements(cfg))
+	router.GET("/api/statements/:month", controllers.GetStatement(cfg))
+}
@@ -9,4 +9,5 @@ import (
 func StockRoutes(router *gin.Engine, cfg *config.APIConfig) {
 	router.GET("/api/stocks", controllers.GetStocks(cfg))
 	router.GET("/api/stocks/search", controllers.SearchStocks(cfg))
+	router.GET("/api/stocks/:symbol", controllers.GetStock(cfg))
 }
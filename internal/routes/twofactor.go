@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func TwoFactorRoutes(router *gin.Engine, cfg *config.APIConfig) {
+	router.POST("/api/login/2fa", controllers.CompleteTwoFactorLogin(cfg))
+	router.GET("/api/2fa", controllers.GetTwoFactor(cfg))
+	router.POST("/api/2fa/totp", controllers.SetupTOTP(cfg))
+	router.POST("/api/2fa/totp/confirm", controllers.ConfirmTOTP(cfg))
+	router.DELETE("/api/2fa/totp", controllers.DisableTOTP(cfg))
+	router.POST("/api/2fa/recovery-codes", controllers.RegenerateRecoveryCodes(cfg))
+}
@@ -8,6 +8,7 @@ import (
 
 func UserRoutes(router *gin.Engine, cfg *config.APIConfig) {
 	router.POST("/api/users", controllers.CreateUser(cfg))
-	router.POST("/admin/reset", controllers.DeleteUsers(cfg))
 	router.POST("/api/login", controllers.LoginUser(cfg))
+	router.POST("/api/refresh", controllers.RefreshToken(cfg))
+	router.POST("/api/logout", controllers.LogoutUser(cfg))
 }
@@ -0,0 +1,145 @@
+package statements
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+//go:embed templates/*
+var templateFS embed.FS
+
+type Position struct {
+	StockSymbol string          `json:"stock_symbol"`
+	Quantity    decimal.Decimal `json:"quantity"`
+	Price       decimal.Decimal `json:"price"`
+	Value       decimal.Decimal `json:"value"`
+	CostBasis   decimal.Decimal `json:"cost_basis"`
+}
+
+type Summary struct {
+	OpeningHoldingsValue decimal.Decimal `json:"opening_holdings_value"`
+	ClosingHoldingsValue decimal.Decimal `json:"closing_holdings_value"`
+	OpeningCash          decimal.Decimal `json:"opening_cash"`
+	ClosingCash          decimal.Decimal `json:"closing_cash"`
+	OpeningValue         decimal.Decimal `json:"opening_value"`
+	ClosingValue         decimal.Decimal `json:"closing_value"`
+	// Deposits less withdrawals, money that came in from outside rather than from performance
+	NetDeposits           decimal.Decimal `json:"net_deposits"`
+	Dividends             decimal.Decimal `json:"dividends"`
+	Fees                  decimal.Decimal `json:"fees"`
+	Performance           decimal.Decimal `json:"performance"`
+	PerformancePercentage decimal.Decimal `json:"performance_percentage"`
+}
+
+// Statement is everything a monthly statement shows, stored as its payload
+type Statement struct {
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Month    time.Time `json:"month"`
+	IssuedAt time.Time `json:"issued_at"`
+	// Set when there was no statement for the month before, so opening holdings
+	// are shown at their cost instead of a month end price
+	OpeningValuedAtCost bool                    `json:"opening_valued_at_cost"`
+	OpeningHoldings     []Position              `json:"opening_holdings"`
+	ClosingHoldings     []Position              `json:"closing_holdings"`
+	Transactions        []database.Transaction  `json:"transactions"`
+	CashMovements       []database.CashMovement `json:"cash_movements"`
+	Summary             Summary                 `json:"summary"`
+}
+
+// Reports whether there's nothing to put on the statement
+func (s Statement) Empty() bool {
+	return len(s.OpeningHoldings) == 0 && len(s.ClosingHoldings) == 0 &&
+		len(s.Transactions) == 0 && len(s.CashMovements) == 0 && s.Summary.ClosingCash.IsZero()
+}
+
+var funcs = template.FuncMap{
+	"money": func(d decimal.Decimal) string { return d.StringFixed(2) },
+	"date":  func(t time.Time) string { return t.Format(time.DateOnly) },
+	"month": func(t time.Time) string { return t.Format("January 2006") },
+}
+
+// Render produces the HTML and PDF documents of a statement
+func Render(s Statement) (string, []byte, error) {
+	tmpl, err := template.New("statement.html.tmpl").Funcs(funcs).ParseFS(templateFS, "templates/statement.html.tmpl")
+	if err != nil {
+		return "", nil, err
+	}
+	var html bytes.Buffer
+	if err := tmpl.Execute(&html, s); err != nil {
+		return "", nil, err
+	}
+	return html.String(), renderPDF(s), nil
+}
+
+// Same sections as the HTML, as plain text lines
+func renderPDF(s Statement) []byte {
+	d := newPDF()
+	d.line(16, true, pdfCell{0, "Stock Portfolio Tracker - Statement for " + s.Month.Format("January 2006")})
+	d.line(10, false, pdfCell{0, s.Name + " <" + s.Email + ">"})
+	d.line(10, false, pdfCell{0, "Issued " + s.IssuedAt.Format(time.DateTime)})
+	d.gap(10)
+
+	d.line(12, true, pdfCell{0, "Summary"})
+	sum := s.Summary
+	for _, row := range [][2]string{
+		{"Opening value", sum.OpeningValue.StringFixed(2)},
+		{"Net deposits", sum.NetDeposits.StringFixed(2)},
+		{"Dividends", sum.Dividends.StringFixed(2)},
+		{"Fees", sum.Fees.StringFixed(2)},
+		{"Closing value", sum.ClosingValue.StringFixed(2)},
+		{"Performance", sum.Performance.StringFixed(2) + " (" + sum.PerformancePercentage.StringFixed(2) + "%)"},
+		{"Opening / closing cash", sum.OpeningCash.StringFixed(2) + " / " + sum.ClosingCash.StringFixed(2)},
+	} {
+		d.line(10, false, pdfCell{0, row[0]}, pdfCell{200, row[1]})
+	}
+
+	holdings := func(title string, positions []Position) {
+		d.gap(10)
+		d.line(12, true, pdfCell{0, title})
+		if len(positions) == 0 {
+			d.line(10, false, pdfCell{0, "None"})
+			return
+		}
+		d.line(9, true, pdfCell{0, "Symbol"}, pdfCell{120, "Quantity"}, pdfCell{220, "Price"}, pdfCell{310, "Value"}, pdfCell{410, "Cost basis"})
+		for _, p := range positions {
+			d.line(9, false, pdfCell{0, p.StockSymbol}, pdfCell{120, p.Quantity.String()}, pdfCell{220, p.Price.StringFixed(2)},
+				pdfCell{310, p.Value.StringFixed(2)}, pdfCell{410, p.CostBasis.StringFixed(2)})
+		}
+	}
+	opening := "Opening holdings"
+	if s.OpeningValuedAtCost {
+		opening += " (valued at cost)"
+	}
+	holdings(opening, s.OpeningHoldings)
+	holdings("Closing holdings", s.ClosingHoldings)
+
+	d.gap(10)
+	d.line(12, true, pdfCell{0, "Transactions"})
+	if len(s.Transactions) == 0 {
+		d.line(10, false, pdfCell{0, "None"})
+	} else {
+		d.line(9, true, pdfCell{0, "Date"}, pdfCell{80, "Type"}, pdfCell{170, "Symbol"}, pdfCell{270, "Quantity"}, pdfCell{350, "Price"}, pdfCell{420, "Amount"})
+		for _, txn := range s.Transactions {
+			d.line(9, false, pdfCell{0, txn.CreatedAt.Format(time.DateOnly)}, pdfCell{80, txn.Type}, pdfCell{170, txn.StockSymbol},
+				pdfCell{270, txn.Quantity.String()}, pdfCell{350, txn.Price.StringFixed(2)}, pdfCell{420, txn.TotalAmount.StringFixed(2)})
+		}
+	}
+
+	d.gap(10)
+	d.line(12, true, pdfCell{0, "Cash movements"})
+	if len(s.CashMovements) == 0 {
+		d.line(10, false, pdfCell{0, "None"})
+	} else {
+		d.line(9, true, pdfCell{0, "Date"}, pdfCell{80, "Type"}, pdfCell{170, "Amount"}, pdfCell{270, "Note"})
+		for _, m := range s.CashMovements {
+			d.line(9, false, pdfCell{0, m.CreatedAt.Format(time.DateOnly)}, pdfCell{80, m.Type}, pdfCell{170, m.Amount.StringFixed(2)}, pdfCell{270, m.Note})
+		}
+	}
+	return d.bytes()
+}
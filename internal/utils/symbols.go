@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidSymbol = errors.New("invalid symbol")
+
+// Yahoo's suffix for each exchange prefix a symbol can be written with, as in NSE:TCS.
+// US exchanges have none.
+var exchangeSuffixes = map[string]string{
+	"NSE":          ".NS",
+	"BSE":          ".BO",
+	"NASDAQ":       "",
+	"NYSE":         "",
+	"NYSEARCA":     "",
+	"NYSEAMERICAN": "",
+	"AMEX":         "",
+	"BATS":         "",
+	"LSE":          ".L",
+	"TSX":          ".TO",
+	"ASX":          ".AX",
+	"HKEX":         ".HK",
+	"XETRA":        ".DE",
+}
+
+// Suffixes people write that Yahoo spells differently
+var suffixAliases = map[string]string{
+	".NSE": ".NS",
+	".BSE": ".BO",
+	".BOM": ".BO",
+}
+
+// Letters, digits and the punctuation Yahoo symbols use: ^NSEI, BTC-USD, EURUSD=X, M&M.NS
+var symbolPattern = regexp.MustCompile(`^\^?[A-Z0-9][A-Z0-9&=.\-]{0,23}$`)
+
+// NormalizeSymbol turns the ways a symbol gets written into the one Yahoo quotes it by, so
+// " nse:tcs", "TCS.NSE" and "tcs.ns" are all TCS.NS. Share classes take a dash, BRK.B is BRK-B.
+func NormalizeSymbol(raw string) (string, error) {
+	symbol := strings.ToUpper(strings.Join(strings.Fields(raw), ""))
+
+	if exchange, rest, ok := strings.Cut(symbol, ":"); ok {
+		suffix, known := exchangeSuffixes[exchange]
+		if !known {
+			return "", fmt.Errorf("%w: unknown exchange %q", ErrInvalidSymbol, exchange)
+		}
+		symbol = rest + suffix
+	}
+	if i := strings.LastIndex(symbol, "."); i > 0 {
+		switch suffix := symbol[i:]; {
+		case suffixAliases[suffix] != "":
+			symbol = symbol[:i] + suffixAliases[suffix]
+		case suffix == ".A" || suffix == ".B":
+			symbol = symbol[:i] + "-" + suffix[1:]
+		}
+	}
+
+	if !symbolPattern.MatchString(symbol) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidSymbol, raw)
+	}
+	return symbol, nil
+}
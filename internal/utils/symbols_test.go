@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeSymbol(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{"AAPL", "AAPL"},
+		{"aapl", "AAPL"},
+		{"  aapl\t", "AAPL"},
+		{"tcs.ns", "TCS.NS"},
+		{"TCS.NSE", "TCS.NS"},
+		{" nse:tcs", "TCS.NS"},
+		{"NSE: TCS", "TCS.NS"},
+		{"reliance.bse", "RELIANCE.BO"},
+		{"500325.BOM", "500325.BO"},
+		{"bse:500325", "500325.BO"},
+		{"500325.bo", "500325.BO"},
+		{"m&m.ns", "M&M.NS"},
+		{"nasdaq:aapl", "AAPL"},
+		{"NYSE:brk.b", "BRK-B"},
+		{"BRK.B", "BRK-B"},
+		{"brk.a", "BRK-A"},
+		{"BRK-B", "BRK-B"},
+		{"lse:vod", "VOD.L"},
+		{"VOD.L", "VOD.L"},
+		{"^nsei", "^NSEI"},
+		{"btc-usd", "BTC-USD"},
+		{"eurusd=x", "EURUSD=X"},
+		{"0p0000xvaa.bo", "0P0000XVAA.BO"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := NormalizeSymbol(tt.raw)
+			if err != nil || got != tt.want {
+				t.Errorf("got %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeSymbolRejects(t *testing.T) {
+	tests := []string{
+		"",
+		"   ",
+		"foo:tcs",
+		"NSE:",
+		".NS",
+		"^",
+		"$AAPL",
+		"TCS;DROP",
+		"AA PL/",
+		strings.Repeat("A", 25),
+	}
+	for _, raw := range tests {
+		t.Run(raw, func(t *testing.T) {
+			got, err := NormalizeSymbol(raw)
+			if !errors.Is(err, ErrInvalidSymbol) {
+				t.Errorf("got %q, %v, want ErrInvalidSymbol", got, err)
+			}
+		})
+	}
+}
@@ -1,35 +1,105 @@
 package utils
 
-import "log"
-
-func HandleBuyTransaction(buyQuant, oldQuant int, oldAvgPrice, currPrice float64) (newQuant int, totalInvested, newAvg, pnl, pnlPercentage, totalAmount float64) {
-	newQuant = oldQuant + buyQuant
-	totalInvested = float64(oldQuant)*oldAvgPrice + float64(buyQuant)*currPrice
-	newAvg = totalInvested / float64(newQuant)
-	pnl = float64(newQuant)*currPrice - totalInvested
-	pnlPercentage = (pnl / totalInvested) * 100
-	totalAmount = float64(buyQuant) * currPrice
+import (
+	"log"
+
+	"github.com/shopspring/decimal"
+)
+
+// Money is kept at the scale of the NUMERIC(28, 8) columns
+const MoneyScale = 8
+
+var hundred = decimal.NewFromInt(100)
+
+func HandleBuyTransaction(buyQuant, oldQuant, oldInvested, currPrice decimal.Decimal) (newQuant, totalInvested, newAvg, pnl, pnlPercentage, totalAmount decimal.Decimal) {
+	totalAmount = Amount(buyQuant, currPrice)
+	newQuant = oldQuant.Add(buyQuant)
+	totalInvested = oldInvested.Add(totalAmount)
+	newAvg = totalInvested.DivRound(newQuant, MoneyScale)
+	pnl = Amount(newQuant, currPrice).Sub(totalInvested)
+	pnlPercentage = Percentage(pnl, totalInvested)
 	return
 }
 
-func HandleSellTransaction(sellQuant, oldQuant int, oldAvgPrice, currPrice float64) (newQuant int, totalInvested, newAvg, pnl, pnlPercentage, totalAmount float64) {
-	if sellQuant > oldQuant {
+func HandleSellTransaction(sellQuant, oldQuant, oldInvested, currPrice decimal.Decimal) (newQuant, totalInvested, newAvg, pnl, pnlPercentage, totalAmount decimal.Decimal) {
+	if sellQuant.GreaterThan(oldQuant) {
 		log.Panic("Trying to sell a stock you don't own niga")
 	}
-	if sellQuant == oldQuant {
-		newQuant = 0
-		totalInvested = 0
-		newAvg = 0
-		pnl = 0
-		pnlPercentage = 0
-		totalAmount = 0
+	totalAmount = Amount(sellQuant, currPrice)
+	if sellQuant.Equal(oldQuant) {
+		return
+	}
+	// The sold part takes its share of the cost basis so invested always adds up to what's left
+	newQuant = oldQuant.Sub(sellQuant)
+	totalInvested = oldInvested.Sub(oldInvested.Mul(sellQuant).DivRound(oldQuant, MoneyScale))
+	newAvg = totalInvested.DivRound(newQuant, MoneyScale)
+	pnl = Amount(newQuant, currPrice).Sub(totalInvested)
+	pnlPercentage = Percentage(pnl, totalInvested)
+	return
+}
+
+// Shorts hold a negative quantity against a negative invested, the proceeds received,
+// so the signed value and pnl math is the same as for longs
+func HandleShortTransaction(shortQuant, oldQuant, oldInvested, currPrice decimal.Decimal) (newQuant, totalInvested, newAvg, pnl, pnlPercentage, totalAmount decimal.Decimal) {
+	totalAmount = Amount(shortQuant, currPrice)
+	newQuant = oldQuant.Sub(shortQuant)
+	totalInvested = oldInvested.Sub(totalAmount)
+	newAvg = totalInvested.DivRound(newQuant, MoneyScale)
+	pnl = Amount(newQuant, currPrice).Sub(totalInvested)
+	pnlPercentage = Percentage(pnl, totalInvested.Abs())
+	return
+}
+
+func HandleCoverTransaction(coverQuant, oldQuant, oldInvested, currPrice decimal.Decimal) (newQuant, totalInvested, newAvg, pnl, pnlPercentage, totalAmount decimal.Decimal) {
+	shortQuant := oldQuant.Neg()
+	if coverQuant.GreaterThan(shortQuant) {
+		log.Panic("Trying to cover more than is shorted")
+	}
+	totalAmount = Amount(coverQuant, currPrice)
+	if coverQuant.Equal(shortQuant) {
 		return
 	}
-	newQuant = oldQuant - sellQuant
-	totalInvested = float64(newQuant) * oldAvgPrice
-	newAvg = oldAvgPrice
-	pnl = float64(newQuant)*currPrice - totalInvested
-	pnlPercentage = (pnl / totalInvested) * 100
-	totalAmount = float64(sellQuant) * currPrice
+	newQuant = oldQuant.Add(coverQuant)
+	totalInvested = oldInvested.Sub(oldInvested.Mul(coverQuant).DivRound(shortQuant, MoneyScale))
+	newAvg = totalInvested.DivRound(newQuant, MoneyScale)
+	pnl = Amount(newQuant, currPrice).Sub(totalInvested)
+	pnlPercentage = Percentage(pnl, totalInvested.Abs())
 	return
 }
+
+// Value of quantity at price, rounded to the money scale
+func Amount(quantity, price decimal.Decimal) decimal.Decimal {
+	return quantity.Mul(price).Round(MoneyScale)
+}
+
+// part as a percentage of whole to 2 places, 0 when whole is 0
+func Percentage(part, whole decimal.Decimal) decimal.Decimal {
+	if whole.IsZero() {
+		return decimal.Zero
+	}
+	return part.Mul(hundred).DivRound(whole, 2)
+}
+
+// Grows value at annualRate percent compounded over whole periods, the remaining days earn simple interest
+func Accrue(value, annualRate decimal.Decimal, periodsPerYear, periods int32, days int) decimal.Decimal {
+	rate := annualRate.Div(hundred)
+	factor := decimal.NewFromInt(1).Add(rate.Div(decimal.NewFromInt32(periodsPerYear)))
+	for range periods {
+		value = value.Mul(factor).Round(2 * MoneyScale)
+	}
+	simple := rate.Mul(decimal.NewFromInt(int64(days))).Div(decimal.NewFromInt(365))
+	return value.Mul(decimal.NewFromInt(1).Add(simple)).Round(MoneyScale)
+}
+
+// Largest quantity an amount buys at price, rounded down to precision decimal places
+func QuantityForAmount(amount, price decimal.Decimal, precision int32) decimal.Decimal {
+	if price.Sign() <= 0 {
+		return decimal.Zero
+	}
+	return amount.Div(price).RoundDown(precision)
+}
+
+// Reports whether quantity has no more than precision decimal places
+func FitsPrecision(quantity decimal.Decimal, precision int32) bool {
+	return quantity.Equal(quantity.Truncate(precision))
+}
@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"math/big"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// What the transactions table keeps of a trade
+type recordedTxn struct {
+	kind        string
+	quantity    decimal.Decimal
+	totalAmount decimal.Decimal
+}
+
+// Half of the smallest amount the money scale has, the most one rounding can be off by
+var halfUnit = big.NewRat(1, 2*100_000_000)
+
+// Up to 8 decimals like the NUMERIC(28, 8) columns, from 0.00000001 to 1000
+func randomDecimal(r *rand.Rand) decimal.Decimal {
+	places := r.Int32N(MoneyScale + 1)
+	scale := int64(1)
+	for range places {
+		scale *= 10
+	}
+	return decimal.New(r.Int64N(1000*scale)+1, -places)
+}
+
+func withinRounding(got decimal.Decimal, want *big.Rat, roundings int64) bool {
+	diff := new(big.Rat).Sub(got.Rat(), want)
+	limit := new(big.Rat).Mul(halfUnit, big.NewRat(roundings, 1))
+	return diff.Abs(diff).Cmp(limit) <= 0
+}
+
+// Reconciles holdings with the transactions that made them. The reference is worked out with
+// exact fractions from the recorded quantities and totals only, so none of the rounding in the
+// Handle* functions is repeated here.
+func TestTradesReconcile(t *testing.T) {
+	r := rand.New(rand.NewPCG(31, 2025))
+
+	for run := range 200 {
+		var (
+			quantity, invested decimal.Decimal
+			history            []recordedTxn
+		)
+
+		for trade := range 50 {
+			price := randomDecimal(r)
+			q := randomDecimal(r)
+
+			var kind string
+			switch {
+			case quantity.IsZero() && r.IntN(2) == 0, quantity.IsPositive() && r.IntN(2) == 0:
+				kind = "BUY"
+			case quantity.IsZero(), quantity.IsNegative() && r.IntN(2) == 0:
+				kind = "SELL_SHORT"
+			case quantity.IsPositive():
+				// Closing the whole position a quarter of the time
+				q = decimal.Min(q, quantity)
+				if r.IntN(4) == 0 {
+					q = quantity
+				}
+				kind = "SELL"
+			default:
+				q = decimal.Min(q, quantity.Neg())
+				if r.IntN(4) == 0 {
+					q = quantity.Neg()
+				}
+				kind = "BUY_TO_COVER"
+			}
+
+			var newQuant, newInvested, pnl, totalAmount decimal.Decimal
+			switch kind {
+			case "BUY":
+				newQuant, newInvested, _, pnl, _, totalAmount = HandleBuyTransaction(q, quantity, invested, price)
+			case "SELL_SHORT":
+				newQuant, newInvested, _, pnl, _, totalAmount = HandleShortTransaction(q, quantity, invested, price)
+			case "SELL":
+				newQuant, newInvested, _, pnl, _, totalAmount = HandleSellTransaction(q, quantity, invested, price)
+			default:
+				newQuant, newInvested, _, pnl, _, totalAmount = HandleCoverTransaction(q, quantity, invested, price)
+			}
+
+			// The recorded total is the trade's value, off by at most one rounding
+			if !withinRounding(totalAmount, new(big.Rat).Mul(q.Rat(), price.Rat()), 1) || totalAmount.Exponent() < -MoneyScale {
+				t.Fatalf("run %d trade %d: total_amount %s for %s at %s", run, trade, totalAmount, q, price)
+			}
+			// A reduction takes its exact share of the cost, rounded once
+			if kind == "SELL" || kind == "BUY_TO_COVER" {
+				share := new(big.Rat).Mul(invested.Rat(), new(big.Rat).Quo(q.Rat(), quantity.Abs().Rat()))
+				taken := invested.Sub(newInvested)
+				if !withinRounding(taken, share, 1) {
+					t.Fatalf("run %d trade %d: %s of %s took %s of the cost, want %s", run, trade, q, quantity, taken, share.FloatString(10))
+				}
+			}
+			history = append(history, recordedTxn{kind: kind, quantity: q, totalAmount: totalAmount})
+			quantity, invested = newQuant, newInvested
+
+			wantQuant, wantInvested, roundings, exact := replay(history)
+			if !quantity.Equal(wantQuant) {
+				t.Fatalf("run %d trade %d: quantity %s, transactions add up to %s", run, trade, quantity, wantQuant)
+			}
+			if (exact && invested.Rat().Cmp(wantInvested) != 0) || !withinRounding(invested, wantInvested, roundings) {
+				t.Fatalf("run %d trade %d: total_invested %s, transactions add up to %s", run, trade, invested, wantInvested.FloatString(10))
+			}
+			if invested.Exponent() < -MoneyScale {
+				t.Fatalf("run %d trade %d: total_invested %s has more than %d places", run, trade, invested, MoneyScale)
+			}
+			if !quantity.IsZero() && !pnl.Equal(Amount(quantity, price).Sub(invested)) {
+				t.Fatalf("run %d trade %d: pnl %s, want %s", run, trade, pnl, Amount(quantity, price).Sub(invested))
+			}
+		}
+	}
+}
+
+// Works out the holding from the recorded transactions at average cost, with exact fractions.
+// Until something is sold or covered, total_invested is the plain sum of the amounts paid
+// (exact), after that each reduction may have rounded once.
+func replay(history []recordedTxn) (quantity decimal.Decimal, invested *big.Rat, roundings int64, exact bool) {
+	invested = new(big.Rat)
+	exact = true
+	for _, txn := range history {
+		switch txn.kind {
+		case "BUY":
+			quantity = quantity.Add(txn.quantity)
+			invested.Add(invested, txn.totalAmount.Rat())
+		case "SELL_SHORT":
+			quantity = quantity.Sub(txn.quantity)
+			invested.Sub(invested, txn.totalAmount.Rat())
+		default:
+			held := quantity.Abs()
+			if txn.kind == "SELL" {
+				quantity = quantity.Sub(txn.quantity)
+			} else {
+				quantity = quantity.Add(txn.quantity)
+			}
+			invested.Mul(invested, new(big.Rat).Quo(quantity.Abs().Rat(), held.Rat()))
+			roundings++
+			exact = false
+		}
+		// Flat again, nothing of what came before is left
+		if quantity.IsZero() {
+			invested, roundings, exact = new(big.Rat), 0, true
+		}
+	}
+	return quantity, invested, roundings, exact
+}
+
+// Quantities down to the 8th decimal, where amounts and the cost a sale takes round at the money scale
+func TestFractionalTrades(t *testing.T) {
+	d := decimal.RequireFromString
+
+	tests := []struct {
+		name         string
+		handle       func(q, oldQuant, oldInvested, price decimal.Decimal) (newQuant, totalInvested, newAvg, pnl, pnlPercentage, totalAmount decimal.Decimal)
+		q, oldQuant  string
+		oldInvested  string
+		price        string
+		wantQuant    string
+		wantInvested string
+		wantAmount   string
+	}{
+		{"smallest quantity", HandleBuyTransaction, "0.00000001", "0", "0", "1", "0.00000001", "0.00000001", "0.00000001"},
+		{"amount under the scale rounds to nothing", HandleBuyTransaction, "0.00000001", "0", "0", "0.00000001", "0.00000001", "0", "0"},
+		{"amount at half the scale rounds up", HandleBuyTransaction, "0.00000001", "0", "0", "0.5", "0.00000001", "0.00000001", "0.00000001"},
+		{"amount rounds to the scale", HandleBuyTransaction, "0.00000003", "0", "0", "0.33333333", "0.00000003", "0.00000001", "0.00000001"},
+		{"sold share of the cost rounds to nothing", HandleSellTransaction, "0.00000001", "0.00000003", "0.00000001", "1", "0.00000002", "0.00000001", "0.00000001"},
+		{"sold share of the cost rounds up", HandleSellTransaction, "0.00000002", "0.00000003", "0.00000001", "1", "0.00000001", "0.00000000", "0.00000002"},
+		{"selling all of a fraction", HandleSellTransaction, "0.12345678", "0.12345678", "12.34567891", "100", "0", "0", "12.345678"},
+		{"third of a position", HandleSellTransaction, "1", "3", "100", "50", "2", "66.66666667", "50"},
+		{"smallest short", HandleShortTransaction, "0.00000001", "0", "0", "1", "-0.00000001", "-0.00000001", "0.00000001"},
+		{"covered share of the proceeds rounds", HandleCoverTransaction, "1", "-3", "-100", "50", "-2", "-66.66666667", "50"},
+		{"covering all of a fraction", HandleCoverTransaction, "0.00000007", "-0.00000007", "-0.00000001", "3", "0", "0", "0.00000021"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newQuant, invested, _, _, _, amount := tt.handle(d(tt.q), d(tt.oldQuant), d(tt.oldInvested), d(tt.price))
+			if !newQuant.Equal(d(tt.wantQuant)) {
+				t.Errorf("quantity %s, want %s", newQuant, tt.wantQuant)
+			}
+			if !invested.Equal(d(tt.wantInvested)) {
+				t.Errorf("total_invested %s, want %s", invested, tt.wantInvested)
+			}
+			if !amount.Equal(d(tt.wantAmount)) {
+				t.Errorf("amount %s, want %s", amount, tt.wantAmount)
+			}
+		})
+	}
+}
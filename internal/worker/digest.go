@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/notify"
+)
+
+func Digester(cfg *config.APIConfig) {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+
+		// Stocker stops polling at 16:00 so prices have settled by 17:00
+		if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday || now.Hour() < 17 {
+			continue
+		}
+
+		dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		generateDigests(cfg, controllers.DailyDigest, dayStart, dayStart.AddDate(0, 0, 1))
+
+		// Weekly digest goes out after the last trading day of the week
+		if now.Weekday() == time.Friday {
+			weekStart := dayStart.AddDate(0, 0, -int(time.Friday-time.Monday))
+			generateDigests(cfg, controllers.WeeklyDigest, weekStart, weekStart.AddDate(0, 0, 7))
+		}
+	}
+}
+
+func generateDigests(cfg *config.APIConfig, period string, start, end time.Time) {
+	ctx := context.Background()
+
+	// SetNX so restarts don't send the same digest twice
+	key := fmt.Sprintf("digest:%s:%s", period, start.Format(time.DateOnly))
+	first, err := cfg.RD.SetNX(ctx, key, 1, 8*24*time.Hour).Result()
+	if err != nil {
+		log.Printf("Error claiming %s digest run: %v\n", period, err)
+		return
+	}
+	if !first {
+		return
+	}
+
+	userIds, err := cfg.DB.GetAllUserIDs(ctx)
+	if err != nil {
+		log.Printf("Error getting users for digest: %v\n", err)
+		return
+	}
+
+	for _, userId := range userIds {
+		digest, err := controllers.BuildDigest(ctx, cfg, userId, period, start, end)
+		if err != nil {
+			log.Printf("Error building %s digest for %s: %v\n", period, userId, err)
+			continue
+		}
+
+		// nothing to report for users without holdings or activity
+		if len(digest.Holdings) == 0 && len(digest.Transactions) == 0 {
+			continue
+		}
+
+		payload, err := json.Marshal(digest)
+		if err != nil {
+			log.Printf("Error marshalling digest: %v\n", err)
+			continue
+		}
+		_, err = cfg.DB.CreateDigest(ctx, database.CreateDigestParams{
+			UserID:         userId,
+			Period:         period,
+			PeriodStart:    start,
+			PeriodEnd:      end,
+			PortfolioValue: digest.PortfolioValue,
+			ValueChange:    digest.ValueChange,
+			Payload:        payload,
+		})
+		if err != nil {
+			log.Printf("Error storing digest: %v\n", err)
+			continue
+		}
+
+		// Templates read the digest with its json field names
+		var digestData map[string]any
+		if err := json.Unmarshal(payload, &digestData); err != nil {
+			log.Printf("Error preparing digest notification: %v\n", err)
+			continue
+		}
+		err = notify.Enqueue(ctx, cfg.RD, notify.Message{
+			UserID:   userId,
+			Kind:     notify.KindSummary,
+			Template: "digest",
+			Subject:  fmt.Sprintf("Your %s portfolio digest", strings.ToLower(period)),
+			Data:     map[string]any{"Digest": digestData},
+		})
+		if err != nil {
+			log.Printf("Error queueing digest notification: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/events"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/notify"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	notificationConsumer = "consumer-mailer"
+	// A failed message stays pending on the stream and is picked up again once it has sat this long
+	notificationRetryAfter = time.Minute
+	// Deliveries before a message is given up on, the first one included
+	maxNotificationDeliveries = 5
+)
+
+func ProcessNotifications(cfg *config.APIConfig) {
+	for {
+		// Reading from notifications stream, waking up now and then to retry failed ones
+		result, err := cfg.RD.XReadGroup(context.Background(), &redis.XReadGroupArgs{
+			Group:    notify.Group,
+			Consumer: notificationConsumer,
+			Streams:  []string{notify.Stream, ">"},
+			Block:    notificationRetryAfter / 2,
+		}).Result()
+
+		if err != nil && !errors.Is(err, redis.Nil) {
+			log.Printf("Error reading from notifications stream: %v\n", err)
+			time.Sleep(15 * time.Second)
+			continue
+		}
+
+		for _, stream := range result {
+			for _, message := range stream.Messages {
+				handleNotification(cfg, message)
+			}
+		}
+
+		retryNotifications(cfg)
+	}
+}
+
+// Delivers a stream message and acks it. A failed one is left pending for retryNotifications,
+// so one bad send doesn't hold up the messages behind it.
+func handleNotification(cfg *config.APIConfig, message redis.XMessage) {
+	msgJSON, _ := message.Values["message"].(string)
+	var msg notify.Message
+	if err := json.Unmarshal([]byte(msgJSON), &msg); err != nil {
+		// Won't get any better on a retry
+		log.Printf("Error unmarshaling notification: %v\n", err)
+	} else if err := deliver(cfg, message.ID, msg); err != nil {
+		log.Printf("Error delivering %s notification to %s, will retry: %v\n", msg.Template, msg.UserID, err)
+		return
+	}
+
+	ackNotification(cfg, message.ID)
+}
+
+func ackNotification(cfg *config.APIConfig, messageID string) {
+	err := cfg.RD.XAck(context.Background(), notify.Stream, notify.Group, messageID).Err()
+	if err != nil {
+		log.Printf("Error acknowledging notification: %v\n", err)
+	}
+}
+
+// Claims back the messages that failed at least notificationRetryAfter ago and tries them
+// again, giving up on those delivered maxNotificationDeliveries times already
+func retryNotifications(cfg *config.APIConfig) {
+	ctx := context.Background()
+	pending, err := cfg.RD.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: notify.Stream,
+		Group:  notify.Group,
+		Idle:   notificationRetryAfter,
+		Start:  "-",
+		End:    "+",
+		Count:  50,
+	}).Result()
+	if err != nil {
+		log.Printf("Error reading pending notifications: %v\n", err)
+		return
+	}
+
+	var retry []string
+	for _, p := range pending {
+		if p.RetryCount >= maxNotificationDeliveries {
+			log.Printf("Giving up on notification %s after %d attempts\n", p.ID, p.RetryCount)
+			ackNotification(cfg, p.ID)
+			continue
+		}
+		retry = append(retry, p.ID)
+	}
+	if len(retry) == 0 {
+		return
+	}
+
+	// Claiming resets the idle time and counts as another delivery
+	messages, err := cfg.RD.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   notify.Stream,
+		Group:    notify.Group,
+		Consumer: notificationConsumer,
+		MinIdle:  notificationRetryAfter,
+		Messages: retry,
+	}).Result()
+	if err != nil {
+		log.Printf("Error claiming pending notifications: %v\n", err)
+		return
+	}
+	for _, message := range messages {
+		handleNotification(cfg, message)
+	}
+}
+
+// Sends msg on every channel the user has on. A channel it already went out on in an earlier
+// attempt is skipped, so only the ones that failed are tried again.
+func deliver(cfg *config.APIConfig, messageID string, msg notify.Message) error {
+	ctx := context.Background()
+
+	user, err := cfg.DB.GetUserByID(ctx, msg.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Account mail skips the preferences, a reset link has to arrive and shouldn't show up in-app
+	channels := notify.Channels
+	if msg.Kind == notify.KindAccount {
+		channels = []string{notify.ChannelEmail}
+	}
+	var failed error
+	for _, channel := range channels {
+		delivered, err := cfg.DB.NotificationDelivered(ctx, database.NotificationDeliveredParams{
+			MessageID: messageID,
+			Channel:   channel,
+		})
+		if err != nil {
+			return err
+		}
+		if delivered {
+			continue
+		}
+
+		// Everything is on by default, a preference row only exists once the user changed it
+		if msg.Kind != notify.KindAccount {
+			pref, err := cfg.DB.GetNotificationPreference(ctx, database.GetNotificationPreferenceParams{
+				UserID:  msg.UserID,
+				Channel: channel,
+				Kind:    msg.Kind,
+			})
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			if err == nil && !pref.Enabled {
+				continue
+			}
+		}
+
+		switch channel {
+		case notify.ChannelEmail:
+			if err := sendEmail(cfg, user, msg); err != nil {
+				failed = err
+				continue
+			}
+		case notify.ChannelInApp:
+			// Goes out on the user's SSE connection, dropped by the hub if they aren't connected
+			eventJSON, err := json.Marshal(map[string]any{
+				"type":    msg.Template,
+				"subject": msg.Subject,
+				"data":    msg.Data,
+			})
+			if err != nil {
+				return err
+			}
+			select {
+			case events.HubInstance.Direct <- events.Event{UserID: msg.UserID, Data: eventJSON}:
+			default:
+				log.Println("No notification sent on Direct")
+			}
+		}
+
+		_, err = cfg.DB.LogNotification(ctx, database.LogNotificationParams{
+			UserID:    msg.UserID,
+			Channel:   channel,
+			Kind:      msg.Kind,
+			Template:  msg.Template,
+			Subject:   msg.Subject,
+			MessageID: messageID,
+		})
+		if err != nil {
+			log.Printf("Error logging notification: %v\n", err)
+		}
+	}
+	return failed
+}
+
+func sendEmail(cfg *config.APIConfig, user database.User, msg notify.Message) error {
+	data := map[string]any{"Name": user.Name}
+	for k, v := range msg.Data {
+		data[k] = v
+	}
+
+	rendered, err := notify.Render(msg.Template, msg.Subject, data)
+	if err != nil {
+		return err
+	}
+
+	return cfg.Notifier.Send(user.Email, rendered)
+}
@@ -0,0 +1,22 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+)
+
+// OrderExpirer marks DAY orders past their close EXPIRED once a minute. Matching already skips
+// them, this is what moves them out of OPEN.
+func OrderExpirer(cfg *config.APIConfig) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := cfg.DB.ExpireOrders(context.Background()); err != nil {
+			log.Printf("Error expiring orders: %v\n", err)
+		}
+	}
+}
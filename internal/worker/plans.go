@@ -0,0 +1,19 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+)
+
+func PlanScheduler(cfg *config.APIConfig) {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	// Each plan only runs while its own exchange is open, RunDuePlans checks that on the exchange's clock
+	for range ticker.C {
+		controllers.RunDuePlans(context.Background(), cfg, time.Now())
+	}
+}
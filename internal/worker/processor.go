@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/events"
 	"github.com/redis/go-redis/v9"
@@ -47,10 +48,17 @@ func ProcessStocks(cfg *config.APIConfig) {
 
 				// store in Postgres DB
 				_, err = cfg.DB.CreateNewStockOrUpdateExisting(context.Background(), database.CreateNewStockOrUpdateExistingParams{
-					Symbol:        stockRes.Symbol,
-					CompanyName:   stockRes.CompanyName,
-					CurrentPrice:  stockRes.CurrentPrice,
-					PreviousClose: stockRes.PreviousClose,
+					Symbol:            stockRes.Symbol,
+					CompanyName:       stockRes.CompanyName,
+					CurrentPrice:      stockRes.CurrentPrice,
+					PreviousClose:     stockRes.PreviousClose,
+					QuantityPrecision: stockRes.QuantityPrecision,
+					AssetClass:        stockRes.AssetClass,
+					DayHigh:           stockRes.DayHigh,
+					DayLow:            stockRes.DayLow,
+					Volume:            stockRes.Volume,
+					FiftyTwoWeekHigh:  stockRes.FiftyTwoWeekHigh,
+					FiftyTwoWeekLow:   stockRes.FiftyTwoWeekLow,
 				})
 
 				if err != nil {
@@ -58,6 +66,9 @@ func ProcessStocks(cfg *config.APIConfig) {
 					continue
 				}
 
+				// Match open orders against the new price
+				controllers.MatchOrders(context.Background(), cfg, stockRes.Symbol, stockRes.CurrentPrice)
+
 				// Put that stockJSON ([]byte) on the broadcast channel of websocket
 				// Since channels are inherently Thread-Safe I think this will work as expected and also its on-blocking send.
 				select {
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+)
+
+// StatementIssuer issues last month's statements on the first of the month,
+// after the Stocker has stopped so closing prices are the month end ones
+func StatementIssuer(cfg *config.APIConfig) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		if now.Day() != 1 {
+			continue
+		}
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+		issueStatements(cfg, month)
+	}
+}
+
+func issueStatements(cfg *config.APIConfig, month time.Time) {
+	ctx := context.Background()
+
+	// SetNX so restarts and other instances don't redo the month
+	key := fmt.Sprintf("statements:%s", month.Format("2006-01"))
+	first, err := cfg.RD.SetNX(ctx, key, 1, 40*24*time.Hour).Result()
+	if err != nil {
+		log.Printf("Error claiming statements run: %v\n", err)
+		return
+	}
+	if !first {
+		return
+	}
+
+	userIds, err := cfg.DB.GetAllUserIDs(ctx)
+	if err != nil {
+		log.Printf("Error getting users for statements: %v\n", err)
+		cfg.RD.Del(ctx, key)
+		return
+	}
+
+	issued := 0
+	for _, userId := range userIds {
+		_, err := controllers.IssueStatement(ctx, cfg, userId, month, false)
+		if errors.Is(err, controllers.ErrEmptyStatement) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error issuing %s statement for %s: %v\n", month.Format("2006-01"), userId, err)
+			continue
+		}
+		issued++
+	}
+	log.Printf("Issued %d statements for %s\n", issued, month.Format("2006-01"))
+}
@@ -5,60 +5,103 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/database"
 	"github.com/redis/go-redis/v9"
 )
 
-func Stocker(cfg *config.APIConfig) {
-	// DB call to get all symbol for current user
-	// symbols is list of symbols for holdings owned all userbase
-	symbols, err := cfg.DB.GetStockSymbolsOfHoldings(context.Background())
-	if err != nil {
-		log.Printf("Error getting symbols from DB: %v\n", err)
-	}
-
-	if len(symbols) < 5 {
-		symbols = append(symbols, []string{"AAPL", "MSFT", "RELIANCE.NS", "TCS.NS", "HDFCBANK.NS", "^NSEI"}...)
-	}
+// Mutual funds publish one NAV a day after the close, it's fetched once from this hour on
+const navHour = 20
 
+func Stocker(cfg *config.APIConfig) {
 	thirtySecTicker := time.NewTicker(30 * time.Second)
 	defer thirtySecTicker.Stop()
 
 	for range thirtySecTicker.C {
 		now := time.Now()
 
-		if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
-			continue
-		}
-
+		weekday := now.Weekday() != time.Saturday && now.Weekday() != time.Sunday
 		hour := now.Hour()
-		if hour < 9 || hour > 16 {
-			continue
-		}
+		marketOpen := weekday && hour >= 9 && hour <= 16
+		navDue := weekday && hour >= navHour
+
+		// Reloaded every tick so new holdings and open orders get prices without a restart
+		stocks := trackedStocks(cfg)
+
 		client := &http.Client{Timeout: 5 * time.Second}
-		for _, symbol := range symbols {
-			// Fetching stock from Yahoo API
-			stockRes, err := controllers.FetchFromYahoo(symbol, client)
-			if err != nil {
-				log.Printf("error fetching from YahooAPI: %v\n", err)
-				continue
+		for _, stock := range stocks {
+			switch stock.AssetClass {
+			case config.AssetCrypto:
+				// Crypto trades around the clock
+			case config.AssetMutualFund:
+				if !navDue || !claimNAV(cfg, stock.Symbol, now) {
+					continue
+				}
+			default:
+				if !marketOpen {
+					continue
+				}
 			}
 
-			// Pushing stockJSON ([]byte) in redis Stream
-			stockJSON, _ := json.Marshal(stockRes)
-			err = cfg.RD.XAdd(context.Background(), &redis.XAddArgs{
-				Stream: "events:liveStocks",
-				Values: map[string]any{"stock": string(stockJSON)},
-				MaxLen: 100,
-				Approx: true,
-			}).Err()
-			if err != nil {
-				log.Printf("error adding stock in redis pipeline: %v\n", err)
-				continue
+			if err := publishStock(cfg, client, stock.Symbol); err != nil {
+				log.Printf("error publishing %s: %v\n", stock.Symbol, err)
+				// let the next tick retry today's NAV
+				if stock.AssetClass == config.AssetMutualFund {
+					cfg.RD.Del(context.Background(), navKey(stock.Symbol, now))
+				}
+			}
+		}
+	}
+}
+
+func publishStock(cfg *config.APIConfig, client *http.Client, symbol string) error {
+	// Fetching stock from Yahoo API
+	stockRes, err := controllers.FetchFromYahoo(symbol, client)
+	if err != nil {
+		return err
+	}
+
+	// Pushing stockJSON ([]byte) in redis Stream
+	stockJSON, _ := json.Marshal(stockRes)
+	return cfg.RD.XAdd(context.Background(), &redis.XAddArgs{
+		Stream: "events:liveStocks",
+		Values: map[string]any{"stock": string(stockJSON)},
+		MaxLen: 100,
+		Approx: true,
+	}).Err()
+}
+
+// Only the first tick of the day after navHour fetches a fund's NAV
+func claimNAV(cfg *config.APIConfig, symbol string, now time.Time) bool {
+	claimed, err := cfg.RD.SetNX(context.Background(), navKey(symbol, now), 1, 24*time.Hour).Result()
+	if err != nil {
+		log.Printf("Error claiming NAV update for %s: %v\n", symbol, err)
+		return false
+	}
+	return claimed
+}
+
+func navKey(symbol string, now time.Time) string {
+	return "nav:" + symbol + ":" + now.Format(time.DateOnly)
+}
+
+func trackedStocks(cfg *config.APIConfig) []database.GetTrackedStocksRow {
+	// Stocks held or with open orders across the userbase, open orders need prices to match
+	stocks, err := cfg.DB.GetTrackedStocks(context.Background())
+	if err != nil {
+		log.Printf("Error getting tracked stocks from DB: %v\n", err)
+	}
+
+	if len(stocks) < 5 {
+		for _, symbol := range []string{"AAPL", "MSFT", "RELIANCE.NS", "TCS.NS", "HDFCBANK.NS", "^NSEI"} {
+			if !slices.ContainsFunc(stocks, func(s database.GetTrackedStocksRow) bool { return s.Symbol == symbol }) {
+				stocks = append(stocks, database.GetTrackedStocksRow{Symbol: symbol, AssetClass: config.AssetEquity})
 			}
 		}
 	}
+	return stocks
 }
@@ -5,12 +5,14 @@ import (
 	"time"
 
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/config"
+	"github.com/Cheemx/stock-portfolio-tacker-api/internal/controllers"
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/events"
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/routes"
 	"github.com/Cheemx/stock-portfolio-tacker-api/internal/worker"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq"
+	"github.com/shopspring/decimal"
 )
 
 const port = "8080"
@@ -20,6 +22,9 @@ func main() {
 
 	gin.SetMode(gin.DebugMode)
 
+	// Quantities stay JSON numbers, written with their exact digits
+	decimal.MarshalJSONWithoutQuotes = true
+
 	cfg := config.Load()
 
 	r.GET("/", func(c *gin.Context) {
@@ -34,6 +39,8 @@ func main() {
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
+	// Keeps API keys to the routes their scopes allow, before any route runs
+	r.Use(controllers.APIKeyScopes(cfg))
 
 	go func() {
 		defer func() {
@@ -44,14 +51,37 @@ func main() {
 		worker.Stocker(cfg)
 	}()
 	go worker.ProcessStocks(cfg)
+	go worker.ProcessNotifications(cfg)
+	go worker.Digester(cfg)
+	go worker.PlanScheduler(cfg)
+	go worker.OrderExpirer(cfg)
+	go worker.StatementIssuer(cfg)
 	go events.HubInstance.Run()
 
 	routes.UserRoutes(r, cfg)
+	routes.OIDCRoutes(r, cfg)
+	routes.SessionRoutes(r, cfg)
+	routes.PasswordRoutes(r, cfg)
+	routes.EmailRoutes(r, cfg)
+	routes.TwoFactorRoutes(r, cfg)
+	routes.APIKeyRoutes(r, cfg)
 	routes.TransactionRoutes(r, cfg)
+	routes.ImportRoutes(r, cfg)
+	routes.ExportRoutes(r, cfg)
+	routes.ReportRoutes(r, cfg)
+	routes.StatementRoutes(r, cfg)
+	routes.OrderRoutes(r, cfg)
+	routes.PlanRoutes(r, cfg)
+	routes.CashRoutes(r, cfg)
+	routes.MarginRoutes(r, cfg)
+	routes.ManualAssetRoutes(r, cfg)
 	routes.HoldingRoutes(r, cfg)
 	routes.PortfolioRoutes(r, cfg)
 	routes.StockRoutes(r, cfg)
 	routes.SSERoutes(r, cfg)
+	routes.NotificationRoutes(r, cfg)
+	routes.DigestRoutes(r, cfg)
+	routes.AdminRoutes(r, cfg)
 	log.Printf("Serving Stock tracker API on port: %s\n", port)
 	log.Fatal(r.Run(":" + port))
 }
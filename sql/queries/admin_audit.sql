@@ -0,0 +1,10 @@
+-- name: CreateAdminAuditEntry :exec
+INSERT INTO admin_audit_log(id, actor_id, actor_role, action, target_user_id, details, ip, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, NOW());
+
+-- name: GetAdminAuditEntries :many
+    -- newest first, only the entries about one user when target_user_id is set
+SELECT * FROM admin_audit_log
+WHERE (sqlc.narg('target_user_id')::UUID IS NULL OR target_user_id = sqlc.narg('target_user_id'))
+ORDER BY created_at DESC, id
+LIMIT sqlc.arg('page_size') OFFSET sqlc.arg('page_offset');
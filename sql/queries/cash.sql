@@ -0,0 +1,39 @@
+-- name: CreateCashMovement :one
+INSERT INTO cash_movements(id, user_id, type, amount, transaction_id, note, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    COALESCE(sqlc.narg('created_at')::TIMESTAMP, NOW())
+)
+RETURNING *;
+
+-- name: GetCashBalance :one
+SELECT COALESCE(SUM(amount), 0)::NUMERIC AS balance
+FROM cash_movements
+WHERE user_id = $1;
+
+-- name: GetCashMovementsForUser :many
+SELECT * FROM cash_movements
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 50;
+
+-- name: IsMarketHoliday :one
+SELECT EXISTS (
+    SELECT 1 FROM market_holidays
+    WHERE market = $1 AND holiday_date = $2
+);
+
+-- name: GetCashBalanceBefore :one
+SELECT COALESCE(SUM(amount), 0)::NUMERIC AS balance
+FROM cash_movements
+WHERE user_id = $1 AND created_at < $2;
+
+-- name: GetCashMovementsForUserBetween :many
+SELECT * FROM cash_movements
+WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+ORDER BY created_at;
@@ -0,0 +1,33 @@
+-- name: CreateDigest :one
+INSERT INTO digests(id, user_id, period, period_start, period_end, portfolio_value, value_change, payload, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    NOW()
+)
+ON CONFLICT (user_id, period, period_start) DO UPDATE
+SET
+    period_end = EXCLUDED.period_end,
+    portfolio_value = EXCLUDED.portfolio_value,
+    value_change = EXCLUDED.value_change,
+    payload = EXCLUDED.payload,
+    created_at = NOW()
+RETURNING *;
+
+-- name: GetLatestDigestForUser :one
+SELECT * FROM digests
+WHERE user_id = $1 AND period = $2
+ORDER BY period_end DESC
+LIMIT 1;
+
+-- name: GetLatestDigestBefore :one
+SELECT * FROM digests
+WHERE user_id = $1 AND period = $2 AND period_end <= $3
+ORDER BY period_end DESC
+LIMIT 1;
@@ -0,0 +1,22 @@
+-- name: CreateEmailVerificationToken :one
+INSERT INTO email_verification_tokens(id, user_id, token_hash, expires_at, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+RETURNING *;
+
+-- name: UseEmailVerificationToken :one
+    -- no rows when the token is unknown, expired or was used already
+UPDATE email_verification_tokens
+SET used_at = NOW()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+RETURNING user_id;
+
+-- name: ExpireEmailVerificationTokens :exec
+UPDATE email_verification_tokens
+SET used_at = NOW()
+WHERE user_id = $1 AND used_at IS NULL;
@@ -5,7 +5,9 @@ SELECT
     holdings.quantity AS quantity,
     holdings.average_price AS average_price,
     stocks.current_price AS current_price,
-    holdings.total_invested AS total_invested
+    holdings.total_invested AS total_invested,
+    stocks.previous_close AS previous_close,
+    stocks.asset_class AS asset_class
 FROM holdings
 JOIN stocks
 ON holdings.stock_symbol = stocks.symbol
@@ -39,6 +41,12 @@ RETURNING *;
 SELECT * FROM holdings
 WHERE user_id = $1 AND stock_symbol = $2;
 
+-- name: GetHoldingByStockSymbolForUpdate :one
+    -- a trade's read, the row stays locked until its transaction ends
+SELECT * FROM holdings
+WHERE user_id = $1 AND stock_symbol = $2
+FOR UPDATE;
+
 -- name: GetStockSymbolsOfHoldings :many
 SELECT DISTINCT stock_symbol
 FROM holdings;
@@ -0,0 +1,28 @@
+-- name: UpsertImportProfile :one
+INSERT INTO import_profiles(id, user_id, name, mapping, created_at, updated_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    NOW(),
+    NOW()
+)
+ON CONFLICT (user_id, name) DO UPDATE
+SET
+    mapping = EXCLUDED.mapping,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: GetImportProfilesForUser :many
+SELECT * FROM import_profiles
+WHERE user_id = $1
+ORDER BY name;
+
+-- name: GetImportProfile :one
+SELECT * FROM import_profiles
+WHERE user_id = $1 AND name = $2;
+
+-- name: DeleteImportProfile :execrows
+DELETE FROM import_profiles
+WHERE user_id = $1 AND name = $2;
\ No newline at end of file
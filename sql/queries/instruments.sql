@@ -0,0 +1,65 @@
+-- name: UpsertInstrument :exec
+INSERT INTO instruments(symbol, name, exchange, isin, currency, asset_class, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, NOW())
+ON CONFLICT (symbol) DO UPDATE
+SET
+    name = EXCLUDED.name,
+    exchange = EXCLUDED.exchange,
+    isin = EXCLUDED.isin,
+    currency = EXCLUDED.currency,
+    asset_class = EXCLUDED.asset_class,
+    updated_at = NOW();
+
+-- name: AddInstrument :exec
+    -- symbols first seen through Yahoo, a listing file that has them wins
+INSERT INTO instruments(symbol, name, exchange, currency, asset_class)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (symbol) DO NOTHING;
+
+-- name: GetInstrument :one
+SELECT * FROM instruments
+WHERE symbol = $1;
+
+-- name: SearchInstruments :many
+    -- exact symbol or ISIN first, then symbol prefixes, then names starting with the term,
+    -- then names containing it or close to it, closest first
+SELECT
+    instruments.symbol,
+    instruments.name,
+    instruments.exchange,
+    instruments.isin,
+    instruments.currency,
+    instruments.asset_class,
+    stocks.current_price,
+    (CASE
+        WHEN instruments.symbol = sqlc.arg('term') OR instruments.isin = sqlc.arg('term') THEN 3
+        WHEN instruments.symbol LIKE sqlc.arg('pattern')::TEXT || '%' THEN 2
+        WHEN instruments.name ILIKE sqlc.arg('pattern')::TEXT || '%' THEN 1
+        ELSE 0
+    END)::INT AS match_rank,
+    word_similarity(sqlc.arg('term'), instruments.name)::REAL AS similarity
+FROM instruments
+LEFT JOIN stocks ON stocks.symbol = instruments.symbol
+WHERE (
+    instruments.symbol = sqlc.arg('term')
+    OR instruments.isin = sqlc.arg('term')
+    OR instruments.symbol LIKE sqlc.arg('pattern')::TEXT || '%'
+    OR instruments.name ILIKE '%' || sqlc.arg('pattern')::TEXT || '%'
+    OR sqlc.arg('term') <% instruments.name
+)
+AND (sqlc.narg('exchanges')::TEXT[] IS NULL OR instruments.exchange = ANY(sqlc.narg('exchanges')::TEXT[]))
+AND (sqlc.narg('asset_class')::TEXT IS NULL OR instruments.asset_class = sqlc.narg('asset_class'))
+ORDER BY match_rank DESC, similarity DESC, length(instruments.symbol), instruments.symbol
+LIMIT sqlc.arg('page_size') OFFSET sqlc.arg('page_offset');
+
+-- name: CountInstrumentMatches :one
+SELECT COUNT(*) FROM instruments
+WHERE (
+    symbol = sqlc.arg('term')
+    OR isin = sqlc.arg('term')
+    OR symbol LIKE sqlc.arg('pattern')::TEXT || '%'
+    OR name ILIKE '%' || sqlc.arg('pattern')::TEXT || '%'
+    OR sqlc.arg('term') <% name
+)
+AND (sqlc.narg('exchanges')::TEXT[] IS NULL OR exchange = ANY(sqlc.narg('exchanges')::TEXT[]))
+AND (sqlc.narg('asset_class')::TEXT IS NULL OR asset_class = sqlc.narg('asset_class'));
@@ -0,0 +1,55 @@
+-- name: CreateManualAsset :one
+INSERT INTO manual_assets(id, user_id, name, asset_type, cost_basis, value, valued_on, interest_rate, compounding, created_at, updated_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    NOW(),
+    NOW()
+)
+RETURNING *;
+
+-- name: GetManualAssetsForUser :many
+SELECT * FROM manual_assets
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: GetManualAssetForUser :one
+SELECT * FROM manual_assets
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteManualAsset :execrows
+DELETE FROM manual_assets
+WHERE id = $1 AND user_id = $2;
+
+-- name: CreateManualAssetValuation :one
+INSERT INTO manual_asset_valuations(id, asset_id, value, valued_on, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+ON CONFLICT (asset_id, valued_on) DO UPDATE
+SET value = EXCLUDED.value
+RETURNING *;
+
+-- name: SetManualAssetLatestValue :exec
+UPDATE manual_assets
+SET
+    value = $2,
+    valued_on = $3,
+    updated_at = NOW()
+WHERE id = $1 AND valued_on <= $3;
+
+-- name: GetManualAssetValuations :many
+SELECT * FROM manual_asset_valuations
+WHERE asset_id = $1
+ORDER BY valued_on DESC;
@@ -0,0 +1,40 @@
+-- name: GetMarginAccount :one
+SELECT * FROM margin_accounts
+WHERE user_id = $1;
+
+-- name: SetShortSellingEnabled :one
+    -- the user's opt in, the limits keep their value or the column defaults
+INSERT INTO margin_accounts(user_id, short_selling_enabled, updated_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (user_id) DO UPDATE
+SET
+    short_selling_enabled = EXCLUDED.short_selling_enabled,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: SetMarginLimits :one
+    -- set by an admin, whether the user opted in is left alone
+INSERT INTO margin_accounts(user_id, margin_requirement, max_short_value, updated_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+ON CONFLICT (user_id) DO UPDATE
+SET
+    margin_requirement = EXCLUDED.margin_requirement,
+    max_short_value = EXCLUDED.max_short_value,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: GetShortExposure :one
+SELECT COALESCE(SUM(-holdings.quantity * stocks.current_price), 0)::NUMERIC AS exposure
+FROM holdings
+JOIN stocks
+ON holdings.stock_symbol = stocks.symbol
+WHERE holdings.user_id = $1 AND holdings.quantity < 0;
@@ -0,0 +1,49 @@
+-- name: GetNotificationPreferencesForUser :many
+SELECT * FROM notification_preferences
+WHERE user_id = $1;
+
+-- name: GetNotificationPreference :one
+SELECT * FROM notification_preferences
+WHERE user_id = $1 AND channel = $2 AND kind = $3;
+
+-- name: UpsertNotificationPreference :one
+INSERT INTO notification_preferences(user_id, channel, kind, enabled, updated_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW()
+)
+ON CONFLICT (user_id, channel, kind) DO UPDATE
+SET
+    enabled = EXCLUDED.enabled,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: LogNotification :one
+INSERT INTO notification_log(id, user_id, channel, kind, template, subject, sent_at, message_id)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    NOW(),
+    $6
+)
+RETURNING *;
+
+-- name: GetNotificationsForUserBetween :many
+SELECT DISTINCT ON (message_id) *
+FROM notification_log
+WHERE user_id = $1 AND kind = $2 AND sent_at >= $3 AND sent_at < $4
+ORDER BY message_id, sent_at;
+
+-- name: NotificationDelivered :one
+    -- whether a stream message already went out on a channel, so a retry doesn't send it twice
+SELECT EXISTS(
+    SELECT 1 FROM notification_log
+    WHERE message_id = $1 AND channel = $2
+);
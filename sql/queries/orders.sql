@@ -0,0 +1,74 @@
+-- name: CreateOrder :one
+INSERT INTO orders(id, user_id, stock_symbol, side, order_type, quantity, limit_price, stop_price, time_in_force, status, expires_at, created_at, updated_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    'OPEN',
+    $9,
+    NOW(),
+    NOW()
+)
+RETURNING *;
+
+-- name: GetOrdersForUser :many
+SELECT * FROM orders
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetOrdersForUserByStatus :many
+SELECT * FROM orders
+WHERE user_id = $1 AND status = $2
+ORDER BY created_at DESC;
+
+-- name: GetOpenOrdersForSymbol :many
+SELECT * FROM orders
+WHERE stock_symbol = $1
+    AND status IN ('OPEN', 'TRIGGERED')
+    AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY created_at;
+
+-- name: CancelOrder :one
+UPDATE orders
+SET
+    status = 'CANCELLED',
+    updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND status IN ('OPEN', 'TRIGGERED')
+RETURNING *;
+
+-- name: TriggerOrder :exec
+UPDATE orders
+SET
+    status = 'TRIGGERED',
+    updated_at = NOW()
+WHERE id = $1 AND status = 'OPEN';
+
+-- name: FillOrder :one
+UPDATE orders
+SET
+    status = 'FILLED',
+    transaction_id = $2,
+    filled_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND status IN ('OPEN', 'TRIGGERED')
+RETURNING *;
+
+-- name: RejectOrder :exec
+UPDATE orders
+SET
+    status = 'REJECTED',
+    updated_at = NOW()
+WHERE id = $1 AND status IN ('OPEN', 'TRIGGERED');
+
+-- name: ExpireOrders :execrows
+UPDATE orders
+SET
+    status = 'EXPIRED',
+    updated_at = NOW()
+WHERE status IN ('OPEN', 'TRIGGERED') AND expires_at <= NOW();
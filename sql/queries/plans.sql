@@ -0,0 +1,66 @@
+-- name: CreateInvestmentPlan :one
+INSERT INTO investment_plans(id, user_id, stock_symbol, amount, quantity, frequency, start_date, end_date, next_run_date, status, created_at, updated_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $6,
+    'ACTIVE',
+    NOW(),
+    NOW()
+)
+RETURNING *;
+
+-- name: GetInvestmentPlansForUser :many
+SELECT * FROM investment_plans
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetInvestmentPlanForUser :one
+SELECT * FROM investment_plans
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetDueInvestmentPlans :many
+SELECT * FROM investment_plans
+WHERE status = 'ACTIVE' AND next_run_date <= $1
+ORDER BY next_run_date;
+
+-- name: UpdateInvestmentPlanStatus :one
+UPDATE investment_plans
+SET
+    status = $3,
+    updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: ScheduleInvestmentPlan :one
+UPDATE investment_plans
+SET
+    next_run_date = $2,
+    status = $3,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CreatePlanExecution :execrows
+INSERT INTO plan_executions(id, plan_id, scheduled_date, status, transaction_id, reason, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    NOW()
+)
+ON CONFLICT (plan_id, scheduled_date) DO NOTHING;
+
+-- name: GetPlanExecutions :many
+SELECT * FROM plan_executions
+WHERE plan_id = $1
+ORDER BY scheduled_date DESC;
@@ -1,7 +1,7 @@
 -- name: GetPortfolioForUser :one
 SELECT 
-        SUM(holdings.total_invested)::DOUBLE PRECISION AS total_invested,
-        SUM(holdings.quantity * stocks.current_price)::DOUBLE PRECISION AS current_value,
+        SUM(holdings.total_invested)::NUMERIC AS total_invested,
+        SUM(holdings.quantity * stocks.current_price)::NUMERIC AS current_value,
         COUNT(holdings.user_id) AS holdings_count
 FROM holdings 
 JOIN users
@@ -9,4 +9,15 @@ ON holdings.user_id = users.id
 JOIN stocks 
 ON holdings.stock_symbol = stocks.symbol
 WHERE users.id = $1
-GROUP BY holdings.user_id;
\ No newline at end of file
+GROUP BY holdings.user_id;
+
+-- name: GetMarketValueByAssetClass :many
+SELECT
+    stocks.asset_class AS asset_class,
+    SUM(holdings.quantity * stocks.current_price)::NUMERIC AS value
+FROM holdings
+JOIN stocks
+ON holdings.stock_symbol = stocks.symbol
+WHERE holdings.user_id = $1
+GROUP BY stocks.asset_class
+ORDER BY stocks.asset_class;
@@ -0,0 +1,38 @@
+-- name: CreateSession :one
+INSERT INTO sessions(id, user_id, user_agent, ip, created_at, last_used_at, expires_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    NOW(),
+    NOW(),
+    $4
+)
+RETURNING *;
+
+-- name: TouchSession :exec
+UPDATE sessions
+SET
+    user_agent = $2,
+    ip = $3,
+    last_used_at = NOW(),
+    expires_at = $4
+WHERE id = $1;
+
+-- name: GetActiveSessionsForUser :many
+SELECT * FROM sessions
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+ORDER BY last_used_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :many
+    -- every session but keep_id, pass the zero UUID to end them all
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+RETURNING id;
@@ -0,0 +1,29 @@
+-- name: CreateStatement :one
+INSERT INTO statements(id, user_id, period, payload, html, pdf, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    NOW()
+)
+    -- Whoever issued it first wins, it's never replaced
+ON CONFLICT (user_id, period) DO NOTHING
+RETURNING *;
+
+-- name: GetStatement :one
+SELECT * FROM statements
+WHERE user_id = $1 AND period = $2;
+
+-- name: GetStatementsForUser :many
+SELECT id, period, created_at FROM statements
+WHERE user_id = $1
+ORDER BY period DESC;
+
+-- name: GetLatestStatementBefore :one
+SELECT * FROM statements
+WHERE user_id = $1 AND period < $2
+ORDER BY period DESC
+LIMIT 1;
\ No newline at end of file
@@ -1,18 +1,36 @@
 -- name: CreateNewStockOrUpdateExisting :one
-INSERT INTO stocks(symbol, company_name, current_price, previous_close, updated_at)
+INSERT INTO stocks(symbol, company_name, current_price, previous_close, updated_at, quantity_precision, asset_class, day_high, day_low, volume, fifty_two_week_high, fifty_two_week_low)
 VALUES (
     $1,
     $2,
     $3,
     $4,
-    NOW()
+    NOW(),
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10,
+    $11
 )
 ON CONFLICT (symbol) DO UPDATE
 SET 
     company_name = EXCLUDED.company_name,
     current_price = EXCLUDED.current_price,
     previous_close = EXCLUDED.previous_close,
-    updated_at = NOW()
+    updated_at = NOW(),
+    -- a reclassified stock takes the new class's default precision, otherwise a tuned precision is kept
+    quantity_precision = CASE
+        WHEN stocks.asset_class <> EXCLUDED.asset_class THEN EXCLUDED.quantity_precision
+        ELSE stocks.quantity_precision
+    END,
+    asset_class = EXCLUDED.asset_class,
+    day_high = EXCLUDED.day_high,
+    day_low = EXCLUDED.day_low,
+    volume = EXCLUDED.volume,
+    fifty_two_week_high = EXCLUDED.fifty_two_week_high,
+    fifty_two_week_low = EXCLUDED.fifty_two_week_low
 RETURNING *;
 
 -- name: GetStockBySymbol :one
@@ -28,12 +46,18 @@ SET
 WHERE symbol = $3
 RETURNING *;
 
--- name: SearchStockByName :many
-SELECT *
-FROM stocks
-WHERE company_name ILIKE '%' || $1 || '%' OR symbol ILIKE '%' || $1 || '%';
-
 -- name: GetAllStocks :many
 SELECT * FROM stocks
 ORDER BY updated_at DESC
-LIMIT 10;
\ No newline at end of file
+LIMIT 10;
+
+-- name: GetAllStocksByAssetClass :many
+SELECT * FROM stocks
+WHERE asset_class = $1
+ORDER BY updated_at DESC
+LIMIT 10;
+
+-- name: GetTrackedStocks :many
+SELECT symbol, asset_class FROM stocks
+WHERE symbol IN (SELECT stock_symbol FROM holdings)
+OR symbol IN (SELECT stock_symbol FROM orders WHERE status IN ('OPEN', 'TRIGGERED'));
\ No newline at end of file
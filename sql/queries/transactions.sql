@@ -1,11 +1,71 @@
--- name: GetAllTransactionsForUser :many
+-- name: ListTransactionsNewestFirst :many
 SELECT * FROM transactions
-WHERE user_id = $1
-ORDER BY created_at DESC 
-LIMIT 10;
+WHERE user_id = sqlc.arg('user_id')
+AND (sqlc.narg('stock_symbol')::TEXT IS NULL OR stock_symbol = sqlc.narg('stock_symbol'))
+AND (sqlc.narg('type')::TEXT IS NULL OR type = sqlc.narg('type'))
+AND (sqlc.narg('from_time')::TIMESTAMP IS NULL OR created_at >= sqlc.narg('from_time'))
+AND (sqlc.narg('to_time')::TIMESTAMP IS NULL OR created_at < sqlc.narg('to_time'))
+AND (sqlc.narg('min_amount')::NUMERIC IS NULL OR total_amount >= sqlc.narg('min_amount'))
+AND (sqlc.narg('max_amount')::NUMERIC IS NULL OR total_amount <= sqlc.narg('max_amount'))
+    -- Keyset cursor, the sort value and id of the last row of the previous page
+AND (sqlc.narg('after_created_at')::TIMESTAMP IS NULL OR (created_at, id) < (sqlc.narg('after_created_at'), sqlc.narg('after_id')::UUID))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_size');
+
+-- name: ListTransactionsOldestFirst :many
+SELECT * FROM transactions
+WHERE user_id = sqlc.arg('user_id')
+AND (sqlc.narg('stock_symbol')::TEXT IS NULL OR stock_symbol = sqlc.narg('stock_symbol'))
+AND (sqlc.narg('type')::TEXT IS NULL OR type = sqlc.narg('type'))
+AND (sqlc.narg('from_time')::TIMESTAMP IS NULL OR created_at >= sqlc.narg('from_time'))
+AND (sqlc.narg('to_time')::TIMESTAMP IS NULL OR created_at < sqlc.narg('to_time'))
+AND (sqlc.narg('min_amount')::NUMERIC IS NULL OR total_amount >= sqlc.narg('min_amount'))
+AND (sqlc.narg('max_amount')::NUMERIC IS NULL OR total_amount <= sqlc.narg('max_amount'))
+    -- Keyset cursor, the sort value and id of the last row of the previous page
+AND (sqlc.narg('after_created_at')::TIMESTAMP IS NULL OR (created_at, id) > (sqlc.narg('after_created_at'), sqlc.narg('after_id')::UUID))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_size');
+
+-- name: ListTransactionsLargestFirst :many
+SELECT * FROM transactions
+WHERE user_id = sqlc.arg('user_id')
+AND (sqlc.narg('stock_symbol')::TEXT IS NULL OR stock_symbol = sqlc.narg('stock_symbol'))
+AND (sqlc.narg('type')::TEXT IS NULL OR type = sqlc.narg('type'))
+AND (sqlc.narg('from_time')::TIMESTAMP IS NULL OR created_at >= sqlc.narg('from_time'))
+AND (sqlc.narg('to_time')::TIMESTAMP IS NULL OR created_at < sqlc.narg('to_time'))
+AND (sqlc.narg('min_amount')::NUMERIC IS NULL OR total_amount >= sqlc.narg('min_amount'))
+AND (sqlc.narg('max_amount')::NUMERIC IS NULL OR total_amount <= sqlc.narg('max_amount'))
+    -- Keyset cursor, the sort value and id of the last row of the previous page
+AND (sqlc.narg('after_amount')::NUMERIC IS NULL OR (total_amount, id) < (sqlc.narg('after_amount'), sqlc.narg('after_id')::UUID))
+ORDER BY total_amount DESC, id DESC
+LIMIT sqlc.arg('page_size');
+
+-- name: ListTransactionsSmallestFirst :many
+SELECT * FROM transactions
+WHERE user_id = sqlc.arg('user_id')
+AND (sqlc.narg('stock_symbol')::TEXT IS NULL OR stock_symbol = sqlc.narg('stock_symbol'))
+AND (sqlc.narg('type')::TEXT IS NULL OR type = sqlc.narg('type'))
+AND (sqlc.narg('from_time')::TIMESTAMP IS NULL OR created_at >= sqlc.narg('from_time'))
+AND (sqlc.narg('to_time')::TIMESTAMP IS NULL OR created_at < sqlc.narg('to_time'))
+AND (sqlc.narg('min_amount')::NUMERIC IS NULL OR total_amount >= sqlc.narg('min_amount'))
+AND (sqlc.narg('max_amount')::NUMERIC IS NULL OR total_amount <= sqlc.narg('max_amount'))
+    -- Keyset cursor, the sort value and id of the last row of the previous page
+AND (sqlc.narg('after_amount')::NUMERIC IS NULL OR (total_amount, id) > (sqlc.narg('after_amount'), sqlc.narg('after_id')::UUID))
+ORDER BY total_amount ASC, id ASC
+LIMIT sqlc.arg('page_size');
+
+-- name: CountTransactions :one
+SELECT COUNT(*) FROM transactions
+WHERE user_id = sqlc.arg('user_id')
+AND (sqlc.narg('stock_symbol')::TEXT IS NULL OR stock_symbol = sqlc.narg('stock_symbol'))
+AND (sqlc.narg('type')::TEXT IS NULL OR type = sqlc.narg('type'))
+AND (sqlc.narg('from_time')::TIMESTAMP IS NULL OR created_at >= sqlc.narg('from_time'))
+AND (sqlc.narg('to_time')::TIMESTAMP IS NULL OR created_at < sqlc.narg('to_time'))
+AND (sqlc.narg('min_amount')::NUMERIC IS NULL OR total_amount >= sqlc.narg('min_amount'))
+AND (sqlc.narg('max_amount')::NUMERIC IS NULL OR total_amount <= sqlc.narg('max_amount'));
 
 -- name: CreateATransaction :one
-INSERT INTO transactions(id, user_id, stock_symbol, type, quantity, price, total_amount, created_at)
+INSERT INTO transactions(id, user_id, stock_symbol, type, quantity, price, total_amount, external_id, created_at)
 VALUES (
     gen_random_uuid(),
     $1,
@@ -14,10 +74,38 @@ VALUES (
     $4,
     $5,
     $6,
-    NOW()
+    $7,
+    -- Imported trades keep the time they were made
+    COALESCE(sqlc.narg('created_at')::TIMESTAMP, NOW())
 )
 RETURNING *;
 
--- name: GetAllTransactionsForUserBySymbol :many
+-- name: GetTransactionsForUserBetween :many
+SELECT * FROM transactions
+WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+ORDER BY created_at;
+
+-- name: TransactionExists :one
+SELECT EXISTS (
+    SELECT 1 FROM transactions
+    WHERE user_id = sqlc.arg('user_id')
+    AND (
+        (sqlc.arg('external_id')::TEXT <> '' AND (external_id = sqlc.arg('external_id') OR id::TEXT = sqlc.arg('external_id')))
+        OR (stock_symbol = sqlc.arg('stock_symbol') AND type = sqlc.arg('type') AND quantity = sqlc.arg('quantity')
+            AND price = sqlc.arg('price') AND created_at = sqlc.arg('created_at'))
+    )
+);
+
+-- name: GetLatestTransactionTime :one
+SELECT MAX(created_at)::TIMESTAMP AS latest
+FROM transactions
+WHERE user_id = $1 AND stock_symbol = $2;
+
+-- name: GetTransactionsForExport :many
 SELECT * FROM transactions
-WHERE user_id = $1 AND stock_symbol = $2;
\ No newline at end of file
+WHERE user_id = sqlc.arg('user_id')
+AND created_at >= sqlc.arg('from_time') AND created_at < sqlc.arg('to_time')
+    -- Keyset pages, pass the last row's created_at and id for the next one
+AND (created_at, id) > (sqlc.arg('after_created_at')::TIMESTAMP, sqlc.arg('after_id')::UUID)
+ORDER BY created_at, id
+LIMIT sqlc.arg('page_size');
\ No newline at end of file
@@ -2,7 +2,7 @@
 INSERT INTO users(id, email, name, created_at, hashed_password)
 VALUES (
     gen_random_uuid(),
-    $1,
+    lower($1),
     $2,
     NOW(),
     $3
@@ -15,4 +15,57 @@ TRUNCATE TABLE users CASCADE;
 -- name: GetUserByEmail :one
 SELECT *
 FROM users
-WHERE email = $1;
\ No newline at end of file
+WHERE lower(email) = lower($1);
+
+-- name: GetUserByID :one
+SELECT *
+FROM users
+WHERE id = $1;
+
+-- name: LockUserForTrade :exec
+    -- held until the transaction ends so a user's trades and withdrawals go one at a time,
+    -- NO KEY leaves rows that only reference the user free to be inserted
+SELECT id FROM users
+WHERE id = $1
+FOR NO KEY UPDATE;
+
+-- name: GetAllUserIDs :many
+SELECT id FROM users;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2
+WHERE id = $1;
+
+-- name: MarkUserVerified :exec
+UPDATE users
+SET verified_at = NOW()
+WHERE id = $1 AND verified_at IS NULL;
+
+-- name: SearchUsers :many
+    -- newest first, the term matches anywhere in the email or name
+SELECT * FROM users
+WHERE (sqlc.arg('pattern')::TEXT = '' OR email ILIKE '%' || sqlc.arg('pattern')::TEXT || '%' OR name ILIKE '%' || sqlc.arg('pattern')::TEXT || '%')
+AND (sqlc.narg('role')::TEXT IS NULL OR role = sqlc.narg('role'))
+ORDER BY created_at DESC, id
+LIMIT sqlc.arg('page_size') OFFSET sqlc.arg('page_offset');
+
+-- name: CountUsers :one
+SELECT COUNT(*) FROM users
+WHERE (sqlc.arg('pattern')::TEXT = '' OR email ILIKE '%' || sqlc.arg('pattern')::TEXT || '%' OR name ILIKE '%' || sqlc.arg('pattern')::TEXT || '%')
+AND (sqlc.narg('role')::TEXT IS NULL OR role = sqlc.narg('role'));
+
+-- name: SetUserRole :execrows
+UPDATE users
+SET role = $2
+WHERE id = $1;
+
+-- name: DisableUser :execrows
+UPDATE users
+SET disabled_at = NOW()
+WHERE id = $1 AND disabled_at IS NULL;
+
+-- name: EnableUser :execrows
+UPDATE users
+SET disabled_at = NULL
+WHERE id = $1 AND disabled_at IS NOT NULL;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE notification_preferences(
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    channel TEXT CHECK (channel IN ('EMAIL')) NOT NULL,
+    kind TEXT CHECK (kind IN ('ALERT', 'SUMMARY', 'SECURITY')) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, channel, kind)
+);
+
+CREATE TABLE notification_log(
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    channel TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    template TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    sent_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE notification_log;
+DROP TABLE notification_preferences;
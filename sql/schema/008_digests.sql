@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE digests(
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    period TEXT CHECK (period IN ('DAILY', 'WEEKLY')) NOT NULL,
+    period_start TIMESTAMP NOT NULL,
+    period_end TIMESTAMP NOT NULL,
+    UNIQUE (user_id, period, period_start),
+    portfolio_value DOUBLE PRECISION NOT NULL,
+    value_change DOUBLE PRECISION NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+ALTER TABLE notification_preferences
+DROP CONSTRAINT notification_preferences_channel_check,
+ADD CONSTRAINT notification_preferences_channel_check CHECK (channel IN ('EMAIL', 'IN_APP'));
+
+ALTER TABLE notification_log
+ADD COLUMN message_id TEXT NOT NULL
+DEFAULT '';
+
+-- +goose Down
+ALTER TABLE notification_log
+DROP COLUMN message_id;
+
+DELETE FROM notification_preferences WHERE channel = 'IN_APP';
+ALTER TABLE notification_preferences
+DROP CONSTRAINT notification_preferences_channel_check,
+ADD CONSTRAINT notification_preferences_channel_check CHECK (channel IN ('EMAIL'));
+
+DROP TABLE digests;
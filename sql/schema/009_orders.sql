@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE orders(
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    stock_symbol TEXT REFERENCES stocks(symbol) ON DELETE CASCADE NOT NULL,
+    side TEXT CHECK (side IN ('BUY', 'SELL')) NOT NULL,
+    order_type TEXT CHECK (order_type IN ('LIMIT', 'STOP', 'STOP_LIMIT')) NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    limit_price DOUBLE PRECISION,
+    stop_price DOUBLE PRECISION,
+    time_in_force TEXT CHECK (time_in_force IN ('GTC', 'DAY')) NOT NULL,
+    status TEXT CHECK (status IN ('OPEN', 'TRIGGERED', 'FILLED', 'CANCELLED', 'EXPIRED', 'REJECTED')) NOT NULL,
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    filled_at TIMESTAMP
+);
+
+CREATE INDEX orders_user_id_idx ON orders(user_id, created_at);
+CREATE INDEX orders_open_symbol_idx ON orders(stock_symbol) WHERE status IN ('OPEN', 'TRIGGERED');
+
+-- +goose Down
+DROP TABLE orders;
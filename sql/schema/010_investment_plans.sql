@@ -0,0 +1,54 @@
+-- +goose Up
+CREATE TABLE cash_movements(
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    type TEXT CHECK (type IN ('DEPOSIT', 'WITHDRAWAL', 'BUY', 'SELL', 'DIVIDEND', 'FEE')) NOT NULL,
+    amount DOUBLE PRECISION NOT NULL,
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX cash_movements_user_id_idx ON cash_movements(user_id, created_at);
+
+CREATE TABLE market_holidays(
+    market TEXT CHECK (market IN ('US', 'IN')) NOT NULL,
+    holiday_date DATE NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (market, holiday_date)
+);
+
+CREATE TABLE investment_plans(
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    stock_symbol TEXT REFERENCES stocks(symbol) ON DELETE CASCADE NOT NULL,
+    amount DOUBLE PRECISION CHECK (amount > 0),
+    quantity INTEGER CHECK (quantity > 0),
+    CHECK ((amount IS NULL) <> (quantity IS NULL)),
+    frequency TEXT CHECK (frequency IN ('DAILY', 'WEEKLY', 'MONTHLY')) NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE,
+    next_run_date DATE NOT NULL,
+    status TEXT CHECK (status IN ('ACTIVE', 'PAUSED', 'COMPLETED', 'CANCELLED')) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX investment_plans_due_idx ON investment_plans(next_run_date) WHERE status = 'ACTIVE';
+
+CREATE TABLE plan_executions(
+    id UUID PRIMARY KEY,
+    plan_id UUID REFERENCES investment_plans(id) ON DELETE CASCADE NOT NULL,
+    scheduled_date DATE NOT NULL,
+    UNIQUE (plan_id, scheduled_date),
+    status TEXT CHECK (status IN ('EXECUTED', 'SKIPPED')) NOT NULL,
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE plan_executions;
+DROP TABLE investment_plans;
+DROP TABLE market_holidays;
+DROP TABLE cash_movements;
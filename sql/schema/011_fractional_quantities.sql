@@ -0,0 +1,33 @@
+-- +goose Up
+ALTER TABLE holdings
+ALTER COLUMN quantity TYPE NUMERIC(28, 8);
+
+ALTER TABLE transactions
+ALTER COLUMN quantity TYPE NUMERIC(28, 8);
+
+ALTER TABLE orders
+ALTER COLUMN quantity TYPE NUMERIC(28, 8);
+
+ALTER TABLE investment_plans
+ALTER COLUMN quantity TYPE NUMERIC(28, 8);
+
+-- Decimal places a quantity of this instrument may have, 0 means whole shares only
+ALTER TABLE stocks
+ADD COLUMN quantity_precision INTEGER NOT NULL
+DEFAULT 0 CHECK (quantity_precision BETWEEN 0 AND 8);
+
+-- +goose Down
+ALTER TABLE stocks
+DROP COLUMN quantity_precision;
+
+ALTER TABLE investment_plans
+ALTER COLUMN quantity TYPE INTEGER USING ROUND(quantity);
+
+ALTER TABLE orders
+ALTER COLUMN quantity TYPE INTEGER USING ROUND(quantity);
+
+ALTER TABLE transactions
+ALTER COLUMN quantity TYPE INTEGER USING ROUND(quantity);
+
+ALTER TABLE holdings
+ALTER COLUMN quantity TYPE INTEGER USING ROUND(quantity);
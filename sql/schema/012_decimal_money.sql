@@ -0,0 +1,54 @@
+-- +goose Up
+-- Money is stored exactly, floats drift after many trades
+ALTER TABLE stocks
+ALTER COLUMN current_price TYPE NUMERIC(28, 8),
+ALTER COLUMN previous_close TYPE NUMERIC(28, 8);
+
+ALTER TABLE holdings
+ALTER COLUMN average_price TYPE NUMERIC(28, 8),
+ALTER COLUMN total_invested TYPE NUMERIC(28, 8);
+
+ALTER TABLE transactions
+ALTER COLUMN price TYPE NUMERIC(28, 8),
+ALTER COLUMN total_amount TYPE NUMERIC(28, 8);
+
+ALTER TABLE digests
+ALTER COLUMN portfolio_value TYPE NUMERIC(28, 8),
+ALTER COLUMN value_change TYPE NUMERIC(28, 8);
+
+ALTER TABLE orders
+ALTER COLUMN limit_price TYPE NUMERIC(28, 8),
+ALTER COLUMN stop_price TYPE NUMERIC(28, 8);
+
+ALTER TABLE cash_movements
+ALTER COLUMN amount TYPE NUMERIC(28, 8);
+
+ALTER TABLE investment_plans
+ALTER COLUMN amount TYPE NUMERIC(28, 8);
+
+-- +goose Down
+ALTER TABLE investment_plans
+ALTER COLUMN amount TYPE DOUBLE PRECISION;
+
+ALTER TABLE cash_movements
+ALTER COLUMN amount TYPE DOUBLE PRECISION;
+
+ALTER TABLE orders
+ALTER COLUMN limit_price TYPE DOUBLE PRECISION,
+ALTER COLUMN stop_price TYPE DOUBLE PRECISION;
+
+ALTER TABLE digests
+ALTER COLUMN portfolio_value TYPE DOUBLE PRECISION,
+ALTER COLUMN value_change TYPE DOUBLE PRECISION;
+
+ALTER TABLE transactions
+ALTER COLUMN price TYPE DOUBLE PRECISION,
+ALTER COLUMN total_amount TYPE DOUBLE PRECISION;
+
+ALTER TABLE holdings
+ALTER COLUMN average_price TYPE DOUBLE PRECISION,
+ALTER COLUMN total_invested TYPE DOUBLE PRECISION;
+
+ALTER TABLE stocks
+ALTER COLUMN current_price TYPE DOUBLE PRECISION,
+ALTER COLUMN previous_close TYPE DOUBLE PRECISION;
@@ -0,0 +1,41 @@
+-- +goose Up
+-- Short positions are held as negative quantities
+ALTER TABLE holdings
+DROP CONSTRAINT holdings_quantity_check,
+ADD CONSTRAINT holdings_quantity_check CHECK (quantity <> 0);
+
+ALTER TABLE transactions
+DROP CONSTRAINT transactions_type_check,
+ADD CONSTRAINT transactions_type_check CHECK (type IN ('BUY', 'SELL', 'SELL_SHORT', 'BUY_TO_COVER'));
+
+ALTER TABLE cash_movements
+DROP CONSTRAINT cash_movements_type_check,
+ADD CONSTRAINT cash_movements_type_check CHECK (type IN ('DEPOSIT', 'WITHDRAWAL', 'BUY', 'SELL', 'SELL_SHORT', 'BUY_TO_COVER', 'DIVIDEND', 'FEE'));
+
+CREATE TABLE margin_accounts(
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    short_selling_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Cash needed per unit of short exposure, 1.5 means 150%
+    margin_requirement NUMERIC(10, 4) NOT NULL DEFAULT 1.5 CHECK (margin_requirement >= 1),
+    -- NULL means no cap besides the collateral
+    max_short_value NUMERIC(28, 8) CHECK (max_short_value > 0),
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE margin_accounts;
+
+DELETE FROM cash_movements WHERE type IN ('SELL_SHORT', 'BUY_TO_COVER');
+ALTER TABLE cash_movements
+DROP CONSTRAINT cash_movements_type_check,
+ADD CONSTRAINT cash_movements_type_check CHECK (type IN ('DEPOSIT', 'WITHDRAWAL', 'BUY', 'SELL', 'DIVIDEND', 'FEE'));
+
+DELETE FROM transactions WHERE type IN ('SELL_SHORT', 'BUY_TO_COVER');
+ALTER TABLE transactions
+DROP CONSTRAINT transactions_type_check,
+ADD CONSTRAINT transactions_type_check CHECK (type IN ('BUY', 'SELL'));
+
+DELETE FROM holdings WHERE quantity < 0;
+ALTER TABLE holdings
+DROP CONSTRAINT holdings_quantity_check,
+ADD CONSTRAINT holdings_quantity_check CHECK (quantity > 0);
@@ -0,0 +1,13 @@
+-- +goose Up
+-- From Yahoo's instrumentType, anything unrecognised counts as EQUITY
+ALTER TABLE stocks
+ADD COLUMN asset_class TEXT NOT NULL
+DEFAULT 'EQUITY' CHECK (asset_class IN ('EQUITY', 'ETF', 'MUTUALFUND', 'CRYPTOCURRENCY', 'INDEX'));
+
+CREATE INDEX stocks_asset_class_idx ON stocks(asset_class, updated_at);
+
+-- +goose Down
+DROP INDEX stocks_asset_class_idx;
+
+ALTER TABLE stocks
+DROP COLUMN asset_class;
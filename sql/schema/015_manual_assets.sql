@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE manual_assets(
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    name TEXT NOT NULL,
+    asset_type TEXT CHECK (asset_type IN ('FIXED_DEPOSIT', 'BOND', 'PRIVATE_EQUITY', 'REAL_ESTATE', 'OTHER')) NOT NULL,
+    cost_basis NUMERIC(28, 8) NOT NULL CHECK (cost_basis >= 0),
+    -- Latest valuation, copied here so listing assets doesn't need the history
+    value NUMERIC(28, 8) NOT NULL CHECK (value >= 0),
+    valued_on DATE NOT NULL,
+    -- Optional accrual, an annual percentage compounded from the latest valuation
+    interest_rate NUMERIC(10, 6),
+    compounding TEXT CHECK (compounding IN ('ANNUAL', 'QUARTERLY', 'MONTHLY', 'DAILY')),
+    CHECK ((interest_rate IS NULL) = (compounding IS NULL)),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX manual_assets_user_id_idx ON manual_assets(user_id);
+
+CREATE TABLE manual_asset_valuations(
+    id UUID PRIMARY KEY,
+    asset_id UUID REFERENCES manual_assets(id) ON DELETE CASCADE NOT NULL,
+    value NUMERIC(28, 8) NOT NULL CHECK (value >= 0),
+    valued_on DATE NOT NULL,
+    UNIQUE (asset_id, valued_on),
+    created_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE manual_asset_valuations;
+DROP TABLE manual_assets;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- The broker's trade id for imported trades, empty for trades made here
+ALTER TABLE transactions
+ADD COLUMN external_id TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX transactions_user_external_id_idx ON transactions(user_id, external_id) WHERE external_id <> '';
+
+-- User-defined CSV column mappings, saved by name
+CREATE TABLE import_profiles(
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    name TEXT NOT NULL,
+    mapping JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+-- +goose Down
+DROP TABLE import_profiles;
+
+DROP INDEX transactions_user_external_id_idx;
+ALTER TABLE transactions
+DROP COLUMN external_id;
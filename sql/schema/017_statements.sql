@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE statements(
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    -- First day of the month the statement covers
+    period DATE NOT NULL,
+    payload JSONB NOT NULL,
+    html TEXT NOT NULL,
+    pdf BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, period)
+);
+
+-- Issued statements never change, only deleting the user removes them
+-- +goose StatementBegin
+CREATE FUNCTION reject_statement_update() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'statements are immutable once issued';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER statements_immutable
+BEFORE UPDATE ON statements
+FOR EACH ROW EXECUTE FUNCTION reject_statement_update();
+
+-- +goose Down
+DROP TRIGGER statements_immutable ON statements;
+DROP FUNCTION reject_statement_update();
+DROP TABLE statements;
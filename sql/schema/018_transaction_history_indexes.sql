@@ -0,0 +1,8 @@
+-- +goose Up
+-- Transaction history pages through a user's rows by time, optionally for one symbol
+CREATE INDEX transactions_user_created_at_idx ON transactions(user_id, created_at);
+CREATE INDEX transactions_user_symbol_created_at_idx ON transactions(user_id, stock_symbol, created_at);
+
+-- +goose Down
+DROP INDEX transactions_user_symbol_created_at_idx;
+DROP INDEX transactions_user_created_at_idx;
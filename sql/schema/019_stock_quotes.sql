@@ -0,0 +1,16 @@
+-- +goose Up
+-- The rest of Yahoo's quote for the stock detail page, empty until the next update
+ALTER TABLE stocks
+ADD COLUMN day_high NUMERIC(28, 8),
+ADD COLUMN day_low NUMERIC(28, 8),
+ADD COLUMN volume BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN fifty_two_week_high NUMERIC(28, 8),
+ADD COLUMN fifty_two_week_low NUMERIC(28, 8);
+
+-- +goose Down
+ALTER TABLE stocks
+DROP COLUMN fifty_two_week_low,
+DROP COLUMN fifty_two_week_high,
+DROP COLUMN volume,
+DROP COLUMN day_low,
+DROP COLUMN day_high;
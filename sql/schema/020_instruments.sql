@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Every symbol that can be searched for, loaded from exchange listing files.
+-- The symbol is Yahoo's, with the exchange suffix where Yahoo uses one
+CREATE TABLE instruments(
+    symbol TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    exchange TEXT NOT NULL DEFAULT '',
+    isin TEXT NOT NULL DEFAULT '',
+    currency TEXT NOT NULL DEFAULT '',
+    asset_class TEXT NOT NULL DEFAULT 'EQUITY'
+    CHECK (asset_class IN ('EQUITY', 'ETF', 'MUTUALFUND', 'CRYPTOCURRENCY', 'INDEX')),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Prefix matches on the symbol, substring and fuzzy matches on the name
+CREATE INDEX instruments_symbol_prefix_idx ON instruments(symbol text_pattern_ops);
+CREATE INDEX instruments_name_trgm_idx ON instruments USING GIN (name gin_trgm_ops);
+CREATE INDEX instruments_isin_idx ON instruments(isin) WHERE isin <> '';
+CREATE INDEX instruments_exchange_idx ON instruments(exchange);
+
+-- Stocks already traded stay findable
+INSERT INTO instruments(symbol, name, asset_class)
+SELECT symbol, company_name, asset_class FROM stocks;
+
+-- +goose Down
+DROP TABLE instruments;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Refresh tokens are swapped for a new one on every use. A login starts a family that all of
+-- its rotations share, so a stolen token being replayed can take the whole family down.
+-- Only the SHA-256 of a token is kept.
+CREATE TABLE refresh_tokens(
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    family_id UUID NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX refresh_tokens_family_idx ON refresh_tokens(family_id);
+CREATE INDEX refresh_tokens_user_idx ON refresh_tokens(user_id);
+
+-- +goose Down
+DROP TABLE refresh_tokens;
@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN verified_at TIMESTAMP;
+
+-- Accounts from before verification existed count as verified so turning the policy on
+-- doesn't stop them trading
+UPDATE users SET verified_at = created_at;
+
+-- Same shape as password_reset_tokens, only the SHA-256 of a token is kept
+CREATE TABLE email_verification_tokens(
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX email_verification_tokens_user_idx ON email_verification_tokens(user_id);
+
+-- +goose Down
+DROP TABLE email_verification_tokens;
+ALTER TABLE users DROP COLUMN verified_at;
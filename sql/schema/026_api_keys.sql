@@ -0,0 +1,20 @@
+-- +goose Up
+-- Personal API keys for scripts. prefix is the start of the key, kept in the clear so a user
+-- can tell their keys apart, only the SHA-256 of the whole key is kept.
+CREATE TABLE api_keys(
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    name TEXT NOT NULL,
+    prefix TEXT UNIQUE NOT NULL,
+    key_hash TEXT UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL CHECK (cardinality(scopes) > 0 AND scopes <@ ARRAY['portfolio:read', 'trade', 'export']),
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX api_keys_user_idx ON api_keys(user_id);
+
+-- +goose Down
+DROP TABLE api_keys;
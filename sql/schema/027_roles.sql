@@ -0,0 +1,28 @@
+-- +goose Up
+-- What a user may do beyond their own account. support can look at users, admin can also
+-- change them. A disabled user can't log in or use their API keys.
+ALTER TABLE users
+    ADD COLUMN role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'support', 'admin')),
+    ADD COLUMN disabled_at TIMESTAMP;
+
+-- Every admin action, who did it and to whom. No foreign keys so the trail outlives the
+-- users in it, a TRUNCATE of users included.
+CREATE TABLE admin_audit_log(
+    id UUID PRIMARY KEY,
+    actor_id UUID NOT NULL,
+    actor_role TEXT NOT NULL,
+    action TEXT NOT NULL,
+    target_user_id UUID,
+    details JSONB NOT NULL DEFAULT '{}',
+    ip TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX admin_audit_log_created_idx ON admin_audit_log(created_at DESC);
+CREATE INDEX admin_audit_log_target_idx ON admin_audit_log(target_user_id);
+
+-- +goose Down
+DROP TABLE admin_audit_log;
+ALTER TABLE users
+    DROP COLUMN disabled_at,
+    DROP COLUMN role;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Logins through an OpenID Connect provider, the issuer and subject name the user there.
+-- email is what the provider said last, the account itself keeps its own.
+CREATE TABLE user_identities(
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    email TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_login_at TIMESTAMP NOT NULL,
+    UNIQUE(issuer, subject)
+);
+
+CREATE INDEX user_identities_user_idx ON user_identities(user_id);
+
+-- +goose Down
+DROP TABLE user_identities;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Users could set their own margin_requirement before limits became an admin's to set, nobody
+-- keeps less than the default that way. Caps users set on themselves only ever made them safer.
+UPDATE margin_accounts
+SET margin_requirement = 1.5, updated_at = NOW()
+WHERE margin_requirement < 1.5;
+
+-- +goose Down
+-- The requirements users had set themselves aren't kept
+SELECT 1;
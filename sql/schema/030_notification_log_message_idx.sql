@@ -0,0 +1,6 @@
+-- +goose Up
+-- A retried stream message looks up which channels it already went out on
+CREATE INDEX notification_log_message_idx ON notification_log(message_id, channel);
+
+-- +goose Down
+DROP INDEX notification_log_message_idx;
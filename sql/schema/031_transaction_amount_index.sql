@@ -0,0 +1,11 @@
+-- +goose Up
+-- The history's keyset sorts compare (created_at, id) and (total_amount, id), each needs an
+-- index ending in id so a page is an index range rather than a sort of every row the user has
+CREATE INDEX transactions_user_amount_id_idx ON transactions(user_id, total_amount, id);
+CREATE INDEX transactions_user_created_at_id_idx ON transactions(user_id, created_at, id);
+DROP INDEX transactions_user_created_at_idx;
+
+-- +goose Down
+CREATE INDEX transactions_user_created_at_idx ON transactions(user_id, created_at);
+DROP INDEX transactions_user_created_at_id_idx;
+DROP INDEX transactions_user_amount_id_idx;
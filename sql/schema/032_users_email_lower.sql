@@ -0,0 +1,20 @@
+-- +goose Up
+-- Emails are compared without case. Two accounts whose addresses only differ in case share one
+-- inbox, picking which of them keeps it is left to whoever runs the migration.
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users GROUP BY lower(btrim(email)) HAVING COUNT(*) > 1) THEN
+        RAISE EXCEPTION 'users has emails that only differ in case or spaces, merge or rename them first';
+    END IF;
+END;
+$$;
+-- +goose StatementEnd
+
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+UPDATE users SET email = lower(btrim(email)) WHERE email <> lower(btrim(email));
+CREATE UNIQUE INDEX users_email_lower_key ON users(lower(email));
+
+-- +goose Down
+DROP INDEX users_email_lower_key;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);